@@ -15,11 +15,15 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/server"
 )
 
@@ -29,6 +33,12 @@ var (
 )
 
 func main() {
+	replay := flag.Bool("replay", false, "re-parse archived raw responses without network and exit")
+	types := flag.String("types", "", "comma separated source types to replay, e.g. TwseDailyClose,TpexDailyClose")
+	rewind := flag.Int("rewind", 0, "rewind offset of the replayed date")
+	output := flag.String("output", "", "write replayed messages into the json lines file instead of kafka")
+	flag.Parse()
+
 	logger := log.With().Str("app", appName).Logger()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -45,7 +55,22 @@ func main() {
 		}
 	}()
 
-	if err := server.Serve(ctx, &logger); err != nil {
+	if *replay {
+		req := &dto.StartCronjobRequest{Rewind: *rewind}
+
+		for _, name := range strings.Split(*types, ",") {
+			source, err := convert.ParseSource(strings.TrimSpace(name))
+			if err != nil {
+				logger.Fatal().Err(err).Msg("replay: failed, reason: invalid types")
+			}
+
+			req.Types = append(req.Types, source)
+		}
+
+		if err := server.Replay(ctx, &logger, req, *output); err != nil {
+			logger.Error().Err(err).Msg("server.Replay: failed")
+		}
+	} else if err := server.Serve(ctx, &logger); err != nil {
 		logger.Error().Err(err).Msg("server.Serve: failed")
 	}
 
@@ -23,6 +23,7 @@ import (
 
 const (
 	RedisPassword = "REDIS_PASSWD"
+	AdminToken    = "ADMIN_TOKEN"
 )
 
 type SystemConfig struct {
@@ -45,11 +46,94 @@ type SystemConfig struct {
 		Version      string `yaml:"version"`
 		MaxGoroutine int    `yaml:"maxGoroutine"`
 		DNSLatency   int64  `yaml:"dnsLatency"`
+		// AdminToken is the bearer token of the mutating admin api routes, refused if not set.
+		AdminToken string `yaml:"adminToken"`
 	} `yaml:"server"`
 	Crawler struct {
-		FetchWorkers int   `yaml:"fetchWorkers"`
-		RateLimit    int64 `yaml:"rateLimit"`
+		Sources      map[string]SourceConfig `yaml:"sources"`
+		Circuit      CircuitConfig           `yaml:"circuit"`
+		PublishRetry PublishRetryConfig      `yaml:"publishRetry"`
+		WorkQueue    WorkQueueConfig         `yaml:"workQueue"`
+		Aggregation  AggregationConfig       `yaml:"aggregation"`
+		FetchWorkers int                     `yaml:"fetchWorkers"`
+		RateLimit    int64                   `yaml:"rateLimit"`
 	} `yaml:"crawler"`
+	Archive struct {
+		Path string `yaml:"path"`
+	} `yaml:"archive"`
+	Proxy struct {
+		Selection    string        `yaml:"selection"`
+		Proxies      []ProxyConfig `yaml:"proxies"`
+		MinRequests  int           `yaml:"minRequests"`
+		MaxErrorRate float64       `yaml:"maxErrorRate"`
+		EvictPeriod  int64         `yaml:"evictPeriod"`
+	} `yaml:"proxy"`
+	Schedules []ScheduleConfig `yaml:"schedules"`
+}
+
+// SourceConfig tunes the crawling of a source, keyed by the source name e.g. StakeConcentration.
+type SourceConfig struct {
+	// Rate is the steady number of requests per second to the host of the source.
+	Rate float64 `yaml:"rate"`
+	// Burst is the number of requests allowed at once after the host idled.
+	Burst int `yaml:"burst"`
+	// Proxy routes the requests of the source through the proxy pool.
+	Proxy bool `yaml:"proxy"`
+	// Deadline is the time of the day (15:04) by which a daily source is published, an empty
+	// download of the day is retried until then.
+	Deadline string `yaml:"deadline"`
+}
+
+// CircuitConfig of the per host circuit breaker, disabled if the threshold is not set.
+type CircuitConfig struct {
+	// Threshold of consecutive failures opening the circuit of a host.
+	Threshold int `yaml:"threshold"`
+	// CoolDown in seconds, the host fails fast before being probed again.
+	CoolDown int64 `yaml:"coolDown"`
+}
+
+// PublishRetryConfig paces the downloads of the sources not yet published, in seconds.
+type PublishRetryConfig struct {
+	// Backoff before the first retry, doubled on every retry.
+	Backoff int64 `yaml:"backoff"`
+	// MaxBackoff caps the doubled backoff.
+	MaxBackoff int64 `yaml:"maxBackoff"`
+}
+
+// WorkQueueConfig shares the per-stock downloads between the instances, disabled if batch is not set.
+type WorkQueueConfig struct {
+	// Batch is the count of stocks claimed by each crawl of an instance.
+	Batch int `yaml:"batch"`
+	// Visibility in seconds of a claim, the stocks not done within it are claimed again, 600 if not set.
+	Visibility int64 `yaml:"visibility"`
+}
+
+// AggregationConfig of the period pages of the stake concentrations.
+type AggregationConfig struct {
+	// Store keeping the pages, redis or memory.
+	Store string `yaml:"store"`
+	// Timeout in seconds after which the incomplete stake concentrations are published partially.
+	Timeout int64 `yaml:"timeout"`
+	// RetryMissingPages downloads the missing pages again before publishing partially.
+	RetryMissingPages bool `yaml:"retryMissingPages"`
+}
+
+// ProxyConfig declares a proxy of the pool, Type is either a scraping api provider
+// (WEB_SCRAPING, PROXY_CRAWL) whose token is read from the env var of the same name and
+// required, or HTTP for a plain proxy at Address.
+type ProxyConfig struct {
+	Type    string `yaml:"type"`
+	Address string `yaml:"address"`
+	Weight  int    `yaml:"weight"`
+}
+
+// ScheduleConfig is a cronjob downloading the sources, Spec is a cron spec in the Taipei time zone
+// and Types the source names e.g. TwseDailyClose. Rewind offsets the downloaded date as in a
+// download request.
+type ScheduleConfig struct {
+	Spec   string   `yaml:"spec"`
+	Types  []string `yaml:"types"`
+	Rewind int      `yaml:"rewind"`
 }
 
 //nolint:nolintlint, gochecknoglobals
@@ -80,6 +164,10 @@ func Load(loc ...string) {
 	if redisPasswd := os.Getenv(RedisPassword); len(redisPasswd) > 0 {
 		instance.RedisCache.Password = redisPasswd
 	}
+
+	if adminToken := os.Getenv(AdminToken); len(adminToken) > 0 {
+		instance.Server.AdminToken = adminToken
+	}
 }
 
 func GetCurrentConfig() *SystemConfig {
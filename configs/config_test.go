@@ -77,6 +77,7 @@ func Test_ConfigLoad(t *testing.T) {
 					Version      string "yaml:\"version\""
 					MaxGoroutine int    "yaml:\"maxGoroutine\""
 					DNSLatency   int64  "yaml:\"dnsLatency\""
+					AdminToken   string "yaml:\"adminToken\""
 				}{
 					Name:         "stock-crawler",
 					Host:         "0.0.0.0",
@@ -86,12 +87,74 @@ func Test_ConfigLoad(t *testing.T) {
 					DNSLatency:   200,
 				},
 				Crawler: struct {
-					FetchWorkers int   "yaml:\"fetchWorkers\""
-					RateLimit    int64 "yaml:\"rateLimit\""
+					Sources      map[string]SourceConfig "yaml:\"sources\""
+					Circuit      CircuitConfig           "yaml:\"circuit\""
+					PublishRetry PublishRetryConfig      "yaml:\"publishRetry\""
+					WorkQueue    WorkQueueConfig         "yaml:\"workQueue\""
+					Aggregation  AggregationConfig       "yaml:\"aggregation\""
+					FetchWorkers int                     "yaml:\"fetchWorkers\""
+					RateLimit    int64                   "yaml:\"rateLimit\""
 				}{
+					Sources: map[string]SourceConfig{
+						"StakeConcentration": {Rate: 0.5, Burst: 1, Proxy: true},
+						"TwseDailyClose":     {Rate: 1, Burst: 3, Deadline: "18:00"},
+						"TwseThreePrimary":   {Deadline: "20:00"},
+						"TpexThreePrimary":   {Deadline: "20:00"},
+						"TwseMarginTrade":    {Deadline: "23:30"},
+						"TpexMarginTrade":    {Deadline: "23:30"},
+					},
+					Circuit: CircuitConfig{
+						Threshold: 5,
+						CoolDown:  60,
+					},
+					PublishRetry: PublishRetryConfig{
+						Backoff:    300,
+						MaxBackoff: 1800,
+					},
+					WorkQueue: WorkQueueConfig{
+						Batch:      10,
+						Visibility: 600,
+					},
+					Aggregation: AggregationConfig{
+						Store:             "redis",
+						Timeout:           600,
+						RetryMissingPages: true,
+					},
 					FetchWorkers: 10,
 					RateLimit:    3000,
 				},
+				Archive: struct {
+					Path string "yaml:\"path\""
+				}{
+					Path: "./archive",
+				},
+				Proxy: struct {
+					Selection    string        "yaml:\"selection\""
+					Proxies      []ProxyConfig "yaml:\"proxies\""
+					MinRequests  int           "yaml:\"minRequests\""
+					MaxErrorRate float64       "yaml:\"maxErrorRate\""
+					EvictPeriod  int64         "yaml:\"evictPeriod\""
+				}{
+					Selection: "weighted",
+					Proxies: []ProxyConfig{
+						{Type: "PROXY_CRAWL", Weight: 2},
+						{Type: "WEB_SCRAPING", Weight: 1},
+						{Type: "HTTP", Address: "http://127.0.0.1:3128", Weight: 1},
+					},
+					MinRequests:  10,
+					MaxErrorRate: 0.5,
+					EvictPeriod:  600,
+				},
+				Schedules: []ScheduleConfig{
+					{Spec: "30 08 * * 1-5", Types: []string{"TwseStockList", "TpexStockList"}},
+					{Spec: "00 15 * * 1-6", Types: []string{"TwseDailyClose", "TpexDailyClose"}},
+					{Spec: "30 16 * * 1-6", Types: []string{"TwseThreePrimary", "TpexThreePrimary"}},
+					{Spec: "00 20 * * 1-6", Types: []string{"StakeConcentration"}},
+					{Spec: "30 21 * * 1-6", Types: []string{"TwseMarginTrade", "TpexMarginTrade"}},
+					{Spec: "00 18 10 * *", Types: []string{"TwseMonthlyRevenue", "TpexMonthlyRevenue"}},
+					{Spec: "00 07 1 * *", Types: []string{"TwseHolidaySchedule"}},
+					{Spec: "00 07 15,31 12 *", Types: []string{"TwseHolidaySchedule"}, Rewind: 1},
+				},
 			},
 		},
 	}
@@ -0,0 +1,261 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"golang.org/x/xerrors"
+)
+
+const (
+	aggregationKeyPrefix = "concentration:"
+	aggregationIndexKey  = "concentrations"
+	// incomplete aggregations nobody expired are dropped after a day
+	aggregationExpire = 24 * time.Hour
+)
+
+//nolint:nolintlint, gochecknoglobals
+var jsoni = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// AggregationStore keeps the period pages of the stake concentration of each stock and
+// date until all of them arrived, a page downloaded again replaces the former one.
+type AggregationStore interface {
+	// Add records the page and returns the pages of the stock once all periods arrived.
+	Add(ctx context.Context, page *entity.StakeConcentration) ([]*entity.StakeConcentration, error)
+	// Expire removes and returns the pages of the aggregations started before the time.
+	Expire(ctx context.Context, before time.Time) ([][]*entity.StakeConcentration, error)
+}
+
+func aggregationKey(page *entity.StakeConcentration) string {
+	return page.Date + ":" + page.StockID
+}
+
+type aggregation struct {
+	started time.Time
+	pages   map[string]*entity.StakeConcentration
+}
+
+type memoryAggregation struct {
+	entries map[string]*aggregation
+	mu      sync.Mutex
+}
+
+// NewMemoryAggregation keeps the aggregations in process, lost when the instance stops.
+func NewMemoryAggregation() AggregationStore {
+	return &memoryAggregation{entries: make(map[string]*aggregation)}
+}
+
+func (m *memoryAggregation) Add(_ context.Context, page *entity.StakeConcentration) ([]*entity.StakeConcentration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := aggregationKey(page)
+
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &aggregation{started: time.Now(), pages: make(map[string]*entity.StakeConcentration)}
+		m.entries[key] = entry
+	}
+
+	entry.pages[page.HiddenField] = page
+
+	if len(entry.pages) < stakeConcentrationTotalCount {
+		return nil, nil
+	}
+
+	delete(m.entries, key)
+
+	return entry.list(), nil
+}
+
+func (m *memoryAggregation) Expire(_ context.Context, before time.Time) ([][]*entity.StakeConcentration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired [][]*entity.StakeConcentration
+
+	for key, entry := range m.entries {
+		if entry.started.After(before) {
+			continue
+		}
+
+		expired = append(expired, entry.list())
+		delete(m.entries, key)
+	}
+
+	return expired, nil
+}
+
+func (a *aggregation) list() []*entity.StakeConcentration {
+	pages := make([]*entity.StakeConcentration, 0, len(a.pages))
+	for _, page := range a.pages {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+type redisAggregation struct {
+	cache  cache.Redis
+	logger *zerolog.Logger
+}
+
+// NewRedisAggregation keeps the aggregations in Redis, shared by the instances and
+// kept over restarts.
+func NewRedisAggregation(redis cache.Redis, logger *zerolog.Logger) AggregationStore {
+	if logger == nil {
+		nop := zerolog.Nop()
+		logger = &nop
+	}
+
+	return &redisAggregation{cache: redis, logger: logger}
+}
+
+func (r *redisAggregation) Add(ctx context.Context, page *entity.StakeConcentration) ([]*entity.StakeConcentration, error) {
+	value, err := jsoni.MarshalToString(page)
+	if err != nil {
+		return nil, xerrors.Errorf("redisAggregation.Add: failed, stock_id=%s; err=%w;", page.StockID, err)
+	}
+
+	fields, err := r.cache.Collect(
+		ctx,
+		aggregationIndexKey,
+		aggregationKeyPrefix+aggregationKey(page),
+		page.HiddenField,
+		value,
+		stakeConcentrationTotalCount,
+		aggregationExpire,
+	)
+	if err != nil {
+		return nil, xerrors.Errorf("redisAggregation.Add: failed, stock_id=%s; err=%w;", page.StockID, err)
+	}
+
+	if fields == nil {
+		return nil, nil
+	}
+
+	return decodePages(fields)
+}
+
+func (r *redisAggregation) Expire(ctx context.Context, before time.Time) ([][]*entity.StakeConcentration, error) {
+	hashes, err := r.cache.TakeExpired(ctx, aggregationIndexKey, before)
+	if err != nil {
+		return nil, xerrors.Errorf("redisAggregation.Expire: failed, err=%w;", err)
+	}
+
+	expired := make([][]*entity.StakeConcentration, 0, len(hashes))
+
+	// the hashes are taken out of redis already, a corrupted one must not drop the others
+	for key, fields := range hashes {
+		pages, err := decodePages(fields)
+		if err != nil {
+			r.logger.Error().Err(err).Msgf("redisAggregation.Expire: skipped, key=%s;", key)
+
+			continue
+		}
+
+		expired = append(expired, pages)
+	}
+
+	return expired, nil
+}
+
+// decodePages restores the pages by their period, which is not part of the json.
+func decodePages(fields map[string]string) ([]*entity.StakeConcentration, error) {
+	pages := make([]*entity.StakeConcentration, 0, len(fields))
+
+	for period, value := range fields {
+		page := &entity.StakeConcentration{}
+		if err := jsoni.UnmarshalFromString(value, page); err != nil {
+			return nil, xerrors.Errorf("crawler.decodePages: failed, period=%s; err=%w;", period, err)
+		}
+
+		page.HiddenField = period
+		pages = append(pages, page)
+	}
+
+	return pages, nil
+}
+
+// missingLinks returns the links of the periods missing from the pages of a stock.
+func missingLinks(pages []*entity.StakeConcentration) []*graph.Link {
+	def, err := convert.Lookup(convert.StakeConcentration)
+	if err != nil || len(pages) == 0 {
+		return nil
+	}
+
+	present := make(map[string]bool, len(pages))
+	for _, page := range pages {
+		present[page.HiddenField] = true
+	}
+
+	// the pages keep the date unified as 20220107
+	date := pages[0].Date
+	if day, err := time.Parse(helper.TwseDateFormat, date); err == nil {
+		date = def.DateOf(day)
+	}
+
+	var links []*graph.Link
+
+	for idx, page := range def.Pages {
+		if present[strconv.Itoa(idx)] {
+			continue
+		}
+
+		links = append(links, &graph.Link{
+			URL:      def.StockLink(pages[0].StockID, page),
+			Date:     date,
+			Strategy: convert.StakeConcentration,
+		})
+	}
+
+	return links
+}
+
+// sliceIterator iterates the given links.
+type sliceIterator struct {
+	links    []*graph.Link
+	curIndex int
+}
+
+func (i *sliceIterator) Next() bool {
+	if i.curIndex >= len(i.links) {
+		return false
+	}
+	i.curIndex++
+
+	return true
+}
+
+func (i *sliceIterator) Error() error {
+	return nil
+}
+
+func (i *sliceIterator) Link() *graph.Link {
+	link := new(graph.Link)
+	*link = *i.links[i.curIndex-1]
+
+	return link
+}
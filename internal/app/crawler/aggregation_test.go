@@ -0,0 +1,254 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func concentrationPage(stockID string, period int, buy uint64) *entity.StakeConcentration {
+	return &entity.StakeConcentration{
+		StockID:      stockID,
+		Date:         "20220801",
+		HiddenField:  strconv.Itoa(period),
+		SumBuyShares: buy,
+	}
+}
+
+func TestMemoryAggregation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryAggregation()
+
+	for period := 0; period < stakeConcentrationTotalCount-1; period++ {
+		pages, err := store.Add(ctx, concentrationPage("2330", period, 100))
+		assert.NoError(t, err)
+		assert.Nil(t, pages)
+	}
+
+	// a page downloaded again replaces the former one
+	pages, err := store.Add(ctx, concentrationPage("2330", 0, 200))
+	assert.NoError(t, err)
+	assert.Nil(t, pages)
+
+	pages, err = store.Add(ctx, concentrationPage("2330", 4, 100))
+	assert.NoError(t, err)
+	assert.Len(t, pages, stakeConcentrationTotalCount)
+
+	res := entity.MapReduceStakeConcentration(pages)
+	assert.Equal(t, uint64(200), res.SumBuyShares)
+	assert.Empty(t, res.Missing)
+
+	_, err = store.Add(ctx, concentrationPage("2317", 1, 100))
+	assert.NoError(t, err)
+
+	expired, err := store.Expire(ctx, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, expired)
+
+	expired, err = store.Expire(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, expired, 1)
+
+	partial := entity.MapReduceStakeConcentration(expired[0])
+	assert.Equal(t, "2317", partial.StockID)
+	assert.Equal(t, []int32{0, 2, 3, 4}, partial.Missing)
+	assert.Equal(t, []int32{0, 100, 0, 0, 0}, partial.Diff)
+
+	links := missingLinks(expired[0])
+	assert.Len(t, links, 4)
+	assert.Equal(t, "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_2317_1.djhtm", links[0].URL)
+	assert.Equal(t, "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_2317_6.djhtm", links[3].URL)
+	assert.Equal(t, "2022-08-01", links[0].Date)
+}
+
+// intercepted collects the data sent into the intercept channel until closed.
+func intercepted(interceptChan chan convert.InterceptData) chan []convert.InterceptData {
+	res := make(chan []convert.InterceptData, 1)
+
+	go func() {
+		var objs []convert.InterceptData
+		for obj := range interceptChan {
+			objs = append(objs, obj)
+		}
+
+		res <- objs
+	}()
+
+	return res
+}
+
+func TestExpireAggregations(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logger := log.With().Str("test", "crawler").Logger()
+	store := NewMemoryAggregation()
+
+	_, err := store.Add(ctx, concentrationPage("2317", 1, 100))
+	assert.NoError(t, err)
+
+	c := New(Config{
+		URLGetter:          &mockSuccessHTTPClient{},
+		FetchWorkers:       1,
+		Aggregation:        store,
+		AggregationTimeout: time.Nanosecond,
+		Logger:             &logger,
+	})
+
+	// the crawls of other sources do not publish the timed out stake concentrations
+	interceptChan := make(chan convert.InterceptData)
+	objs := intercepted(interceptChan)
+
+	_, err = c.Crawl(ctx, &testLinkIterator{links: []*graph.Link{
+		{URL: "http://www.google.com", Date: "20220801", Strategy: convert.TwseStockList},
+	}}, interceptChan)
+	assert.NoError(t, err)
+
+	for _, obj := range <-objs {
+		assert.NotEqual(t, convert.StakeConcentration, obj.Type)
+	}
+
+	interceptChan = make(chan convert.InterceptData)
+	objs = intercepted(interceptChan)
+
+	assert.NoError(t, c.ExpireAggregations(ctx, interceptChan))
+
+	expired := <-objs
+	assert.Len(t, expired, 1)
+
+	partial, ok := (*expired[0].Data)[0].(*entity.StakeConcentration)
+	assert.True(t, ok)
+	assert.Equal(t, "2317", partial.StockID)
+	assert.Equal(t, []int32{0, 2, 3, 4}, partial.Missing)
+}
+
+// mockPartialHTTPClient serves the stake concentration pages but the one of the failed period.
+type mockPartialHTTPClient struct {
+	failed string
+}
+
+func (mp *mockPartialHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, mp.failed) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	doc, err := helper.ReadFromFile("../parser/.testfiles/concentration_fubon.html")
+	encoded, _ := helper.EncodeBig5([]byte(doc))
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(encoded)),
+	}, err
+}
+
+func TestExpireAggregationsRetryFailed(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logger := log.With().Str("test", "crawler").Logger()
+	store := NewMemoryAggregation()
+
+	_, err := store.Add(ctx, concentrationPage("3704", 0, 100))
+	assert.NoError(t, err)
+
+	progress := &testProgress{}
+	c := New(Config{
+		URLGetter:          &mockPartialHTTPClient{failed: "zco_3704_6.djhtm"},
+		FetchWorkers:       1,
+		Aggregation:        store,
+		AggregationTimeout: time.Nanosecond,
+		RetryMissingPages:  true,
+		Logger:             &logger,
+	})
+
+	interceptChan := make(chan convert.InterceptData)
+	objs := intercepted(interceptChan)
+
+	err = c.ExpireAggregations(ContextWithProgress(ctx, progress), interceptChan)
+	assert.ErrorIs(t, err, ErrLinksFailed)
+
+	// the page given up again is still missing, the partial is published anyway
+	expired := <-objs
+	assert.Len(t, expired, 1)
+
+	partial, ok := (*expired[0].Data)[0].(*entity.StakeConcentration)
+	assert.True(t, ok)
+	assert.Equal(t, "3704", partial.StockID)
+	assert.Equal(t, []int32{4}, partial.Missing)
+	assert.Equal(t, 1, progress.abandoned)
+}
+
+func TestRedisAggregation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	store := NewRedisAggregation(mockRedis, nil)
+	page := concentrationPage("2330", 4, 100)
+
+	mockRedis.EXPECT().
+		Collect(ctx, aggregationIndexKey, "concentration:20220801:2330", "4", gomock.Any(), stakeConcentrationTotalCount, aggregationExpire).
+		Return(map[string]string{
+			"0": `{"stockId":"2330","exchangeDate":"20220801","sumBuyShares":300}`,
+			"4": `{"stockId":"2330","exchangeDate":"20220801","sumBuyShares":100}`,
+		}, nil).
+		Times(1)
+
+	pages, err := store.Add(ctx, page)
+	assert.NoError(t, err)
+	assert.Len(t, pages, 2)
+
+	res := entity.MapReduceStakeConcentration(pages)
+	assert.Equal(t, uint64(300), res.SumBuyShares)
+	assert.Equal(t, []int32{1, 2, 3}, res.Missing)
+
+	// the hash failing to decode is skipped, the others are still expired
+	mockRedis.EXPECT().
+		TakeExpired(ctx, aggregationIndexKey, gomock.Any()).
+		Return(map[string]map[string]string{
+			"concentration:20220801:2330": {"4": `{"stockId":"2330","exchangeDate":"20220801","sumBuyShares":100}`},
+			"concentration:20220801:2317": {"1": `{"stockId":`},
+		}, nil).
+		Times(1)
+
+	expired, err := store.Expire(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, expired, 1)
+	assert.Equal(t, "2330", expired[0][0].StockID)
+}
@@ -15,12 +15,12 @@ const (
 
 type broadcastor struct {
 	interceptChan chan convert.InterceptData
-	memCache      map[string][]*entity.StakeConcentration
+	aggregation   AggregationStore
 }
 
-func newBroadcastor() *broadcastor {
+func newBroadcastor(aggregation AggregationStore) *broadcastor {
 	return &broadcastor{
-		memCache: make(map[string][]*entity.StakeConcentration),
+		aggregation: aggregation,
 	}
 }
 
@@ -28,7 +28,7 @@ func (b *broadcastor) InterceptData(_ context.Context, interceptChan chan conver
 	b.interceptChan = interceptChan
 }
 
-func (b *broadcastor) Process(_ context.Context, pipe pipeline.Payload) (pipeline.Payload, error) {
+func (b *broadcastor) Process(ctx context.Context, pipe pipeline.Payload) (pipeline.Payload, error) {
 	payload, ok := pipe.(*crawlerPayload)
 	if !ok {
 		return nil, xerrors.New("invalid payload")
@@ -37,39 +37,69 @@ func (b *broadcastor) Process(_ context.Context, pipe pipeline.Payload) (pipelin
 	intercept := convert.InterceptData{}
 
 	if payload.Strategy == convert.StakeConcentration {
-		if st := b.cacheInMemory(payload.ParsedContent); st != nil {
+		st, err := b.aggregate(ctx, payload.ParsedContent)
+		if err != nil {
+			return nil, err
+		}
+
+		if st != nil {
 			intercept = convert.InterceptData{
 				Data: &[]any{st},
 				Type: payload.Strategy,
+				URL:  payload.URL,
+				Date: payload.Date,
 			}
 		}
 	} else {
 		intercept = convert.InterceptData{
 			Data: payload.ParsedContent,
 			Type: payload.Strategy,
+			URL:  payload.URL,
+			Date: payload.Date,
 		}
 	}
 
-	if b.interceptChan != nil && intercept.Data != nil {
-		b.interceptChan <- intercept
+	if err := b.emit(ctx, intercept); err != nil {
+		return nil, err
 	}
 
 	return pipe, nil
 }
 
-func (b *broadcastor) cacheInMemory(data *[]any) *entity.StakeConcentration {
+// emit sends the data into the intercept channel, if any.
+func (b *broadcastor) emit(ctx context.Context, intercept convert.InterceptData) error {
+	if b.interceptChan == nil || intercept.Data == nil {
+		return nil
+	}
+
+	select {
+	case b.interceptChan <- intercept:
+	case <-ctx.Done():
+		return xerrors.Errorf("broadcastor.emit: failed, err=%w;", ctx.Err())
+	}
+
+	return nil
+}
+
+// aggregate records the period pages and returns the merged record of the stock once
+// every period arrived.
+func (b *broadcastor) aggregate(ctx context.Context, data *[]any) (*entity.StakeConcentration, error) {
+	if data == nil {
+		return nil, nil
+	}
+
 	for _, v := range *data {
 		if val, ok := v.(*entity.StakeConcentration); ok {
-			b.memCache[val.StockID] = append(b.memCache[val.StockID], val)
-
-			if len(b.memCache[val.StockID]) == stakeConcentrationTotalCount {
-				output := entity.MapReduceStakeConcentration(b.memCache[val.StockID])
-				delete(b.memCache, val.StockID)
+			pages, err := b.aggregation.Add(ctx, val)
+			if err != nil {
+				return nil, xerrors.Errorf("broadcastor.aggregate: failed, err=%w;", err)
+			}
 
-				return output
+			if pages != nil {
+				return entity.MapReduceStakeConcentration(pages), nil
 			}
 		}
 	}
 
-	return nil
+	return nil, nil
 }
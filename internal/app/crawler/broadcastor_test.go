@@ -34,7 +34,7 @@ func TestInterceptData(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			b := newBroadcastor()
+			b := newBroadcastor(NewMemoryAggregation())
 			b.InterceptData(context.Background(), tt.args.mockChan)
 			if (b.interceptChan == tt.args.mockChan) != tt.isEq {
 				t.Errorf(
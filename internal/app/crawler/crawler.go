@@ -16,28 +16,26 @@ package crawler
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/rs/zerolog"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/graph"
 	"github.com/samwang0723/stock-crawler/internal/app/pipeline"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
 	"golang.org/x/xerrors"
 )
 
-//nolint:nolintlint, lll
 const (
-	TwseDailyClose   = "https://www.twse.com.tw/exchangeReport/MI_INDEX?response=csv&date=%s&type=ALLBUT0999"
-	TwseThreePrimary = "https://www.twse.com.tw/rwd/zh/fund/T86?response=csv&date=%s&selectType=ALLBUT0999"
-	TpexDailyClose   = "https://wwwov.tpex.org.tw/web/stock/aftertrading/otc_quotes_no1430/stk_wn1430_result.php?l=zh-tw&o=csv&d=%s&se=EW&s=0,asc,0"
-	TpexThreePrimary = "https://www.tpex.org.tw/web/stock/3insti/daily_trade/3itrade_hedge_result.php?l=zh-tw&o=csv&se=EW&t=D&d=%s"
-	TWSEStocks       = "https://isin.twse.com.tw/isin/C_public.jsp?strMode=2"
-	TPEXStocks       = "https://isin.twse.com.tw/isin/C_public.jsp?strMode=4"
-	// backup: stockchannelnew.sinotrade.com.tw
-	ConcentrationDays = "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_%s_%d.djhtm"
-
 	defaultHTTPTimeout = 60 * time.Second
+	// incomplete stake concentrations are kept for the timeout if not configured
+	defaultAggregationTimeout = 10 * time.Minute
 )
 
 //nolint:nolintlint, gochecknoglobals, gosec
@@ -46,22 +44,24 @@ var (
 		Timeout: defaultHTTPTimeout,
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			Proxy:           proxyFromContext,
 		},
 	}
-
-	TypeLinkMapping = map[string]string{
-		convert.TpexStockList.String():      TPEXStocks,
-		convert.TwseStockList.String():      TWSEStocks,
-		convert.TwseDailyClose.String():     TwseDailyClose,
-		convert.TpexDailyClose.String():     TpexDailyClose,
-		convert.TwseThreePrimary.String():   TwseThreePrimary,
-		convert.TpexThreePrimary.String():   TpexThreePrimary,
-		convert.StakeConcentration.String(): ConcentrationDays,
-	}
 )
 
+// ErrLinksFailed is returned by Crawl when some links were given up, while the others
+// went through the pipeline.
+var ErrLinksFailed = errors.New("links failed")
+
 type Crawler interface {
+	// Crawl closes the intercept channel, if given, once every link went through the
+	// pipeline so the receiver knows no more data will be intercepted.
 	Crawl(ctx context.Context, linkIt graph.LinkIterator, interceptChan ...chan convert.InterceptData) (int, error)
+	// ExpireAggregations sends the stake concentrations timed out into the intercept
+	// channel, closed once done.
+	ExpireAggregations(ctx context.Context, interceptChan chan convert.InterceptData) error
+	// Circuits returns the circuit breaker state of every remote host.
+	Circuits() []circuit.Snapshot
 }
 
 // URLGetter is implemented by objects that can perform HTTP GET requests.
@@ -70,11 +70,28 @@ type URLGetter interface {
 }
 
 type Config struct {
-	URLGetter         URLGetter
-	Proxy             *Proxy
-	Logger            *zerolog.Logger
-	FetchWorkers      int
-	RateLimitInterval int64
+	URLGetter URLGetter
+	// Proxy is only used by the sources listed in ProxySources.
+	Proxy        *ProxyPool
+	ProxySources []convert.Source
+	Archive      archive.Archive
+	Logger       *zerolog.Logger
+	// RateLimits overrides the pace of the hosts serving the sources,
+	// other hosts are paced by RateLimitInterval.
+	RateLimits map[convert.Source]RateLimit
+	// A host fails fast for CircuitCoolDown after CircuitThreshold consecutive
+	// failures, the circuit breaker is disabled if the threshold is not set.
+	CircuitThreshold int
+	CircuitCoolDown  time.Duration
+	// Aggregation keeps the period pages of the stake concentrations, in memory if not set.
+	Aggregation AggregationStore
+	// Stake concentrations still missing periods after AggregationTimeout are sent
+	// partially by ExpireAggregations, once their missing pages were downloaded again
+	// if RetryMissingPages is set.
+	AggregationTimeout time.Duration
+	RetryMissingPages  bool
+	FetchWorkers       int
+	RateLimitInterval  int64
 }
 
 // crawlerImpl implements a stock information crawling pipeline consisting of following stages:
@@ -82,26 +99,50 @@ type Config struct {
 // - Given an URL, retrieve content from remote server
 // - Extract useful trading information from retrieved pages
 type crawlerImpl struct {
-	broadcast *broadcastor
-	pipe      *pipeline.Pipeline
+	throttler *hostThrottler
+	breaker   *circuit.Group
 	cfg       Config
 }
 
 func New(cfg Config) Crawler {
+	var breaker *circuit.Group
+	if cfg.CircuitThreshold > 0 {
+		breaker = circuit.NewGroup(circuit.Config{
+			Logger:    cfg.Logger,
+			Threshold: cfg.CircuitThreshold,
+			CoolDown:  cfg.CircuitCoolDown,
+		})
+	}
+
+	if cfg.Aggregation == nil {
+		cfg.Aggregation = NewMemoryAggregation()
+	}
+
+	if cfg.AggregationTimeout <= 0 {
+		cfg.AggregationTimeout = defaultAggregationTimeout
+	}
+
 	return &crawlerImpl{
 		cfg:       cfg,
-		broadcast: newBroadcastor(),
+		throttler: newHostThrottler(cfg),
+		breaker:   breaker,
 	}
 }
 
 // assembleCrawlerPipeline creates the various stages of a crawler pipeline
 // using the options in cfg and assembles them into a pipeline instance.
-func assembleCrawlerPipeline(cfg Config, broadcastor *broadcastor) *pipeline.Pipeline {
+func assembleCrawlerPipeline(
+	cfg Config,
+	broadcastor *broadcastor,
+	throttler *hostThrottler,
+	breaker *circuit.Group,
+) *pipeline.Pipeline {
 	return pipeline.New(
-		pipeline.DynamicWorkerPool(
-			newLinkFetcher(cfg.URLGetter, cfg.Proxy, cfg.Logger),
+		pipeline.ThrottledWorkerPool(
+			newLinkFetcher(cfg, throttler, breaker),
 			cfg.FetchWorkers,
 			time.Duration(cfg.RateLimitInterval)*time.Millisecond,
+			throttler,
 		),
 		pipeline.FIFO(newTextExtractor(cfg)),
 		pipeline.Broadcast(broadcastor),
@@ -111,26 +152,135 @@ func assembleCrawlerPipeline(cfg Config, broadcastor *broadcastor) *pipeline.Pip
 // Crawl iterates linkIt and sends each link through the crawler pipeline
 // returning the total count of links that went through the pipeline. Calls to
 // Crawl block until the link iterator is exhausted, an error occurs or the
-// context is canceled.
+// context is canceled, the intercept channel is closed before returning.
 func (c *crawlerImpl) Crawl(
 	ctx context.Context,
 	linkIt graph.LinkIterator,
 	interceptChan ...chan convert.InterceptData,
 ) (int, error) {
-	// reconstruct pipeline every time as previous pipeline may be terminated
-	c.pipe = assembleCrawlerPipeline(c.cfg, c.broadcast)
+	// each crawl assembles its own pipeline broadcasting into its own intercept
+	// channel, the throttler is kept so concurrent crawls share the pace of each host
+	broadcast := newBroadcastor(c.cfg.Aggregation)
+	pipe := assembleCrawlerPipeline(c.cfg, broadcast, c.throttler, c.breaker)
 
 	sink := new(countingSink)
 
 	if len(interceptChan) == 1 {
-		c.broadcast.InterceptData(ctx, interceptChan[0])
+		broadcast.InterceptData(ctx, interceptChan[0])
 	}
 
-	err := c.pipe.Process(ctx, &linkSource{linkIt: linkIt}, sink)
+	err := linksFailed(pipe.Process(ctx, &linkSource{linkIt: linkIt}, sink))
+
+	// every stage has exited once the pipeline returns, nothing is sent anymore
+	if len(interceptChan) == 1 {
+		close(interceptChan[0])
+	}
 
 	return sink.getCount(), err
 }
 
+// ExpireAggregations sends the stake concentrations missing periods for the timeout
+// partially, after downloading their missing pages again if configured.
+func (c *crawlerImpl) ExpireAggregations(ctx context.Context, interceptChan chan convert.InterceptData) error {
+	defer close(interceptChan)
+
+	broadcast := newBroadcastor(c.cfg.Aggregation)
+	broadcast.InterceptData(ctx, interceptChan)
+
+	return c.expireAggregations(ctx, broadcast, new(countingSink))
+}
+
+func (c *crawlerImpl) expireAggregations(ctx context.Context, broadcast *broadcastor, sink *countingSink) error {
+	expired, err := c.cfg.Aggregation.Expire(ctx, time.Now().Add(-c.cfg.AggregationTimeout))
+	if err != nil {
+		return xerrors.Errorf("crawlerImpl.expireAggregations: failed, err=%w;", err)
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	if c.cfg.RetryMissingPages {
+		// the missing pages are aggregated along the expired ones, once
+		retry := NewMemoryAggregation()
+
+		var links []*graph.Link
+
+		for _, pages := range expired {
+			for _, page := range pages {
+				//nolint:nolintlint, errcheck
+				retry.Add(ctx, page)
+			}
+
+			links = append(links, missingLinks(pages)...)
+		}
+
+		retryBroadcast := newBroadcastor(retry)
+		retryBroadcast.InterceptData(ctx, broadcast.interceptChan)
+		pipe := assembleCrawlerPipeline(c.cfg, retryBroadcast, c.throttler, c.breaker)
+
+		// the expired pages are taken out of the store already, they are published
+		// partially even if some of the missing pages are given up again
+		err = linksFailed(pipe.Process(ctx, &linkSource{linkIt: &sliceIterator{links: links}}, sink))
+		if err != nil && !errors.Is(err, ErrLinksFailed) {
+			return xerrors.Errorf("crawlerImpl.expireAggregations: failed, err=%w;", err)
+		}
+
+		//nolint:nolintlint, errcheck
+		expired, _ = retry.Expire(ctx, time.Now())
+	}
+
+	for _, pages := range expired {
+		st := entity.MapReduceStakeConcentration(pages)
+		if st == nil {
+			continue
+		}
+
+		if c.cfg.Logger != nil {
+			c.cfg.Logger.Warn().Msgf(
+				"crawlerImpl.expireAggregations: partial, stock_id=%s; date=%s; missing=%v;", st.StockID, st.Date, st.Missing,
+			)
+		}
+
+		emitErr := broadcast.emit(ctx, convert.InterceptData{Data: &[]any{st}, Type: convert.StakeConcentration, Date: st.Date})
+		if emitErr != nil {
+			return xerrors.Errorf("crawlerImpl.expireAggregations: failed, err=%w;", emitErr)
+		}
+	}
+
+	if err != nil {
+		return xerrors.Errorf("crawlerImpl.expireAggregations: failed, err=%w;", err)
+	}
+
+	return nil
+}
+
+// linksFailed marks the error of the pipeline with ErrLinksFailed if it only gave
+// links up.
+func linksFailed(err error) error {
+	var merr *multierror.Error
+	if !errors.As(err, &merr) {
+		return err
+	}
+
+	for _, e := range merr.Errors {
+		var stageErr *pipeline.StageError
+		if !errors.As(e, &stageErr) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("crawlerImpl.Crawl: failed, reason: %w; err=%w;", ErrLinksFailed, err)
+}
+
+func (c *crawlerImpl) Circuits() []circuit.Snapshot {
+	if c.breaker == nil {
+		return nil
+	}
+
+	return c.breaker.Snapshots()
+}
+
 type linkSource struct {
 	linkIt graph.LinkIterator
 }
@@ -17,16 +17,19 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"io"
 	"net/http"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/archive"
 	"github.com/samwang0723/stock-crawler/internal/helper"
 	"go.uber.org/goleak"
 )
@@ -78,6 +81,20 @@ func (mf *mockErrorHTTPClient) Do(_ *http.Request) (*http.Response, error) {
 	return nil, os.ErrInvalid
 }
 
+// testProgress counts the links given up.
+type testProgress struct {
+	noProgress
+	abandoned int
+	mu        sync.Mutex
+}
+
+func (p *testProgress) Abandoned(convert.Source, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.abandoned++
+}
+
 func TestMain(m *testing.M) {
 	leak := flag.Bool("leak", false, "use leak detector")
 
@@ -99,9 +116,10 @@ func TestCrawl(t *testing.T) {
 	}
 
 	tests := []struct {
-		args    args
-		name    string
-		wantErr bool
+		args          args
+		name          string
+		wantErr       bool
+		wantAbandoned int
 	}{
 		{
 			name: "regular http fetch",
@@ -125,7 +143,8 @@ func TestCrawl(t *testing.T) {
 					Strategy: convert.TwseStockList,
 				},
 			},
-			wantErr: true,
+			wantErr:       true,
+			wantAbandoned: 1,
 		},
 	}
 
@@ -143,12 +162,183 @@ func TestCrawl(t *testing.T) {
 				RateLimitInterval: 1000,
 				Logger:            &logger,
 			})
-			_, err := c.Crawl(context.TODO(), &testLinkIterator{links: []*graph.Link{
+			progress := &testProgress{}
+			_, err := c.Crawl(ContextWithProgress(context.TODO(), progress), &testLinkIterator{links: []*graph.Link{
 				tt.args.link,
 			}})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Crawl() = %v, want %v, err: %v", err != nil, tt.wantErr, err)
 			}
+
+			if err != nil && !errors.Is(err, ErrLinksFailed) {
+				t.Errorf("Crawl() = %v, want %v", err, ErrLinksFailed)
+			}
+
+			if progress.abandoned != tt.wantAbandoned {
+				t.Errorf("Crawl() abandoned = %d, want %d", progress.abandoned, tt.wantAbandoned)
+			}
 		})
 	}
 }
+
+func TestCrawlConcurrently(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "crawler").Logger()
+	c := New(Config{
+		URLGetter:         &mockSuccessHTTPClient{},
+		FetchWorkers:      2,
+		RateLimitInterval: 10,
+		Logger:            &logger,
+	})
+
+	var waitGroup sync.WaitGroup
+
+	for _, date := range []string{"20220801", "20220802"} {
+		waitGroup.Add(1)
+
+		go func(date string) {
+			defer waitGroup.Done()
+
+			interceptChan := make(chan convert.InterceptData)
+			received := make(chan int)
+
+			go func() {
+				count := 0
+				for range interceptChan {
+					count++
+				}
+				received <- count
+			}()
+
+			_, err := c.Crawl(context.TODO(), &testLinkIterator{links: []*graph.Link{
+				{URL: "http://www.google.com/" + date, Date: date, Strategy: convert.TwseStockList},
+			}}, interceptChan)
+			if err != nil {
+				t.Errorf("Crawl() err: %v", err)
+			}
+
+			if count := <-received; count != 1 {
+				t.Errorf("Crawl() intercepted = %d, want 1", count)
+			}
+		}(date)
+	}
+
+	waitGroup.Wait()
+}
+
+func TestCrawlArchive(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "crawler").Logger()
+	rawArchive := archive.NewLocal(archive.Config{Root: t.TempDir(), Logger: &logger})
+
+	c := New(Config{
+		URLGetter:         &mockSuccessHTTPClient{},
+		Archive:           rawArchive,
+		FetchWorkers:      1,
+		RateLimitInterval: 10,
+		Logger:            &logger,
+	})
+
+	_, err := c.Crawl(context.TODO(), &testLinkIterator{links: []*graph.Link{
+		{
+			URL:      "http://www.google.com",
+			Date:     "111/08/01",
+			Strategy: convert.TwseStockList,
+		},
+	}})
+	if err != nil {
+		t.Errorf("Crawl() err: %v", err)
+	}
+
+	meta, _, err := rawArchive.Get(context.TODO(), convert.TwseStockList.String(), "20220801", "http://www.google.com")
+	if err != nil || meta.StatusCode != http.StatusOK {
+		t.Errorf("archive.Get() = %+v, err: %v", meta, err)
+	}
+}
+
+func TestCrawlReplay(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "crawler").Logger()
+	rawArchive := archive.NewLocal(archive.Config{Root: t.TempDir(), Logger: &logger})
+
+	correctDoc, _ := helper.ReadFromFile("../parser/.testfiles/stocks.html")
+	correctBytes, _ := helper.EncodeBig5([]byte(correctDoc))
+
+	err := rawArchive.Put(context.TODO(), &archive.Metadata{
+		Source:     convert.TwseStockList.String(),
+		URL:        "http://www.google.com",
+		StatusCode: http.StatusOK,
+	}, correctBytes)
+	if err != nil {
+		t.Errorf("archive.Put() err: %v", err)
+	}
+
+	c := New(Config{
+		URLGetter:    NewReplayGetter(rawArchive),
+		FetchWorkers: 1,
+		Logger:       &logger,
+	})
+
+	interceptChan := make(chan convert.InterceptData)
+	done := make(chan int)
+
+	go func() {
+		var count int
+		for obj := range interceptChan {
+			count += len(*obj.Data)
+		}
+		done <- count
+	}()
+
+	_, err = c.Crawl(context.TODO(), &testLinkIterator{links: []*graph.Link{
+		{URL: "http://www.google.com", Strategy: convert.TwseStockList},
+		{URL: "http://www.yahoo.com", Strategy: convert.TwseStockList},
+	}}, interceptChan)
+
+	// only the archived link is replayed, the missing one is reported as not found
+	if err == nil {
+		t.Errorf("Crawl() expect not found error for the missing link")
+	}
+
+	if count := <-done; count != 5 {
+		t.Errorf("Crawl() replayed records = %d, want 5", count)
+	}
+}
+
+func TestHostThrottler(t *testing.T) {
+	t.Parallel()
+
+	throttler := newHostThrottler(Config{
+		RateLimitInterval: 1,
+		RateLimits: map[convert.Source]RateLimit{
+			convert.StakeConcentration: {Rate: 0.001, Burst: 1},
+		},
+	})
+
+	slow := &crawlerPayload{URL: "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_2330_1.djhtm"}
+	fast := &crawlerPayload{URL: "https://www.twse.com.tw/exchangeReport/MI_INDEX?response=csv"}
+
+	if lane := throttler.Lane(slow); lane != "fubon-ebrokerdj.fbs.com.tw" {
+		t.Errorf("Lane() = %s, want fubon-ebrokerdj.fbs.com.tw", lane)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the burst of the slow host is used up by the first request
+	if err := throttler.Wait(ctx, throttler.Lane(slow)); err != nil {
+		t.Errorf("Wait() err: %v", err)
+	}
+
+	if err := throttler.Wait(ctx, throttler.Lane(slow)); err == nil {
+		t.Errorf("Wait() = nil, want deadline exceeded")
+	}
+
+	// other hosts keep their own pace
+	if err := throttler.Wait(context.Background(), throttler.Lane(fast)); err != nil {
+		t.Errorf("Wait() err: %v", err)
+	}
+}
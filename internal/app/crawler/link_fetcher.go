@@ -15,13 +15,18 @@ package crawler
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/pipeline"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
 	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/samwang0723/stock-crawler/internal/retry"
 	"golang.org/x/xerrors"
 )
 
@@ -31,15 +36,28 @@ var _ pipeline.Processor = (*linkFetcher)(nil)
 // proxy could be nil if not necessary
 type linkFetcher struct {
 	urlGetter URLGetter
-	proxy     *Proxy
+	proxy     *ProxyPool
+	proxied   map[convert.Source]bool
+	throttler *hostThrottler
+	breaker   *circuit.Group
+	archiver  archive.Archive
 	logger    *zerolog.Logger
 }
 
-func newLinkFetcher(urlGetter URLGetter, proxy *Proxy, logger *zerolog.Logger) *linkFetcher {
+func newLinkFetcher(cfg Config, throttler *hostThrottler, breaker *circuit.Group) *linkFetcher {
+	proxied := make(map[convert.Source]bool, len(cfg.ProxySources))
+	for _, source := range cfg.ProxySources {
+		proxied[source] = true
+	}
+
 	return &linkFetcher{
-		urlGetter: urlGetter,
-		proxy:     proxy,
-		logger:    logger,
+		urlGetter: cfg.URLGetter,
+		proxy:     cfg.Proxy,
+		proxied:   proxied,
+		throttler: throttler,
+		breaker:   breaker,
+		archiver:  cfg.Archive,
+		logger:    cfg.Logger,
 	}
 }
 
@@ -49,12 +67,69 @@ func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipelin
 		return nil, xerrors.Errorf("linkFetcher.Process: failed, payload_type=%T;", p)
 	}
 
+	out, err := lf.guardedFetch(ctx, payload)
+
+	progress := progressFromContext(ctx)
+	if err != nil {
+		progress.Failed(payload.Strategy, err)
+	} else {
+		progress.Fetched(payload.Strategy)
+	}
+
+	return out, err
+}
+
+// Abandon implements pipeline.Abandoner.
+func (lf *linkFetcher) Abandon(ctx context.Context, p pipeline.Payload, err error) {
+	abandon(ctx, p, err)
+}
+
+// guardedFetch fetches the payload behind the circuit breaker of its host.
+func (lf *linkFetcher) guardedFetch(ctx context.Context, payload *crawlerPayload) (pipeline.Payload, error) {
+	if lf.breaker == nil {
+		return lf.fetch(ctx, payload)
+	}
+
+	// fail fast without retrying while the host is down
+	host := lf.throttler.Lane(payload)
+	if err := lf.breaker.Allow(host); err != nil {
+		return nil, retry.NoRetryError(xerrors.Errorf("linkFetcher.Process: failed, err=%w;", err))
+	}
+
+	out, err := lf.fetch(ctx, payload)
+
+	switch {
+	case err == nil, errors.As(err, &retry.Stop{}):
+		// permanent errors are answered by the host, it is up
+		lf.breaker.Success(host)
+	case ctx.Err() != nil, errors.Is(err, ErrNoProxyAvailable):
+		// not caused by the host
+	default:
+		lf.breaker.Failure(host)
+	}
+
+	return out, err
+}
+
+func (lf *linkFetcher) fetch(ctx context.Context, payload *crawlerPayload) (pipeline.Payload, error) {
 	uri := payload.URL
-	if lf.proxy != nil && payload.Strategy == convert.StakeConcentration {
-		uri = lf.proxy.URI(payload.URL)
+
+	var proxy *Proxy
+
+	if lf.proxy != nil && lf.proxied[payload.Strategy] {
+		selected, err := lf.proxy.Next()
+		if err != nil {
+			return nil, xerrors.Errorf("linkFetcher.Process: failed, err=%w;", err)
+		}
+
+		proxy = selected
+		uri = proxy.URI(payload.URL)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, http.NoBody)
+	// keep the original link within the request, replaying getters look up the archive with it
+	reqCtx := contextWithProxy(contextWithLink(ctx, payload), proxy)
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, uri, http.NoBody)
 	if err != nil {
 		return nil, xerrors.Errorf("linkFetcher.Process: failed, err=%w;", err)
 	}
@@ -69,15 +144,34 @@ func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipelin
 
 	resp, err := lf.urlGetter.Do(req)
 	if err != nil {
+		lf.proxy.Report(proxy, false)
+
 		return nil, xerrors.Errorf("urlGetter.Do(): %w", err)
 	}
 
+	lf.proxy.Report(proxy, !proxyFailed(resp.StatusCode))
+
 	// Skip payloads for invalid http status codes.
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+	switch classifyStatus(req, resp) {
+	case responseThrottled:
+		resp.Body.Close()
+
+		return nil, lf.backoff(payload, resp)
+	case responsePermanent:
+		resp.Body.Close()
+
+		return nil, retry.NoRetryError(xerrors.Errorf(
+			"linkFetcher.Process: failed, http_status_code=%d;",
+			resp.StatusCode,
+		))
+	case responseTransient:
+		resp.Body.Close()
+
 		return nil, xerrors.Errorf(
 			"linkFetcher.Process: failed, http_status_code=%d;",
 			resp.StatusCode,
 		)
+	case responseOK:
 	}
 
 	// copy stream from response body, although it consumes memory but
@@ -89,6 +183,14 @@ func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipelin
 		return nil, xerrors.Errorf("linkFetcher.Process: failed, err=%w;", err)
 	}
 
+	if isRetryLaterPage(payload.RawContent.Bytes()) {
+		payload.RawContent.Reset()
+
+		return nil, lf.backoff(payload, resp)
+	}
+
+	lf.archive(ctx, payload, resp.StatusCode)
+
 	//nolint:nolintlint, gomnd
 	lf.logger.Info().
 		Msgf("linkFetcher.Process: success, reason: download completed; size=%s; url=%s;",
@@ -96,3 +198,46 @@ func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipelin
 
 	return payload, nil
 }
+
+// backoff slows down the host of the payload once throttled, the link is retried
+// after the pause as the throttler holds back the retries as well.
+func (lf *linkFetcher) backoff(payload *crawlerPayload, resp *http.Response) error {
+	host := lf.throttler.Lane(payload)
+	pause := lf.throttler.Backoff(host, retryAfter(resp.Header, time.Now()))
+
+	lf.logger.Warn().Msgf("linkFetcher.Process: throttled, host=%s; http_status_code=%d; pause=%s; url=%s;",
+		host, resp.StatusCode, pause, payload.URL)
+
+	return xerrors.Errorf("linkFetcher.Process: failed, http_status_code=%d; err=%w;", resp.StatusCode, ErrThrottled)
+}
+
+// proxyFailed tells whether the status code is likely caused by the proxy
+// being blocked or broken rather than by the requested link.
+func proxyFailed(statusCode int) bool {
+	switch statusCode {
+	case http.StatusForbidden, http.StatusProxyAuthRequired, http.StatusTooManyRequests:
+		return true
+	}
+
+	return statusCode >= http.StatusInternalServerError
+}
+
+// archive keeps the raw response for later re-parsing, failures will not stop the crawl.
+func (lf *linkFetcher) archive(ctx context.Context, payload *crawlerPayload, statusCode int) {
+	if lf.archiver == nil {
+		return
+	}
+
+	source, date := ArchiveKey(payload.Strategy, payload.Date)
+
+	err := lf.archiver.Put(ctx, &archive.Metadata{
+		Source:      source,
+		Date:        date,
+		URL:         payload.URL,
+		StatusCode:  statusCode,
+		RetrievedAt: payload.RetrievedAt,
+	}, payload.RawContent.Bytes())
+	if err != nil {
+		lf.logger.Warn().Err(err).Msgf("linkFetcher.archive: failed, url=%s;", payload.URL)
+	}
+}
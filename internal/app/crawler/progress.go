@@ -0,0 +1,64 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"context"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/pipeline"
+)
+
+// Progress is notified while the links of a crawl go through the pipeline,
+// implementations must be safe for concurrent use.
+type Progress interface {
+	// Fetched is called once the content of a link is retrieved.
+	Fetched(source convert.Source)
+	// Parsed is called with the number of rows extracted from a link.
+	Parsed(source convert.Source, rows int)
+	// Failed is called on every failed attempt of fetching or parsing a link.
+	Failed(source convert.Source, err error)
+	// Abandoned is called with the url of a link given up after its last failed attempt.
+	Abandoned(source convert.Source, url string, err error)
+}
+
+type progressContextKey struct{}
+
+// ContextWithProgress attaches the progress to the context given to Crawl.
+func ContextWithProgress(ctx context.Context, progress Progress) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, progress)
+}
+
+// progressFromContext returns a no-op progress if none is attached.
+func progressFromContext(ctx context.Context) Progress {
+	if progress, ok := ctx.Value(progressContextKey{}).(Progress); ok {
+		return progress
+	}
+
+	return noProgress{}
+}
+
+type noProgress struct{}
+
+func (noProgress) Fetched(convert.Source)                  {}
+func (noProgress) Parsed(convert.Source, int)              {}
+func (noProgress) Failed(convert.Source, error)            {}
+func (noProgress) Abandoned(convert.Source, string, error) {}
+
+// abandon reports the link of the payload given up into the progress of the crawl.
+func abandon(ctx context.Context, p pipeline.Payload, err error) {
+	if payload, ok := p.(*crawlerPayload); ok {
+		progressFromContext(ctx).Abandoned(payload.Strategy, payload.URL, err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"golang.org/x/xerrors"
+)
+
+var ErrReplayLinkMissing = errors.New("replay link missing in request context")
+
+type linkContextKey struct{}
+
+// replayGetter serves archived responses instead of hitting the network, so a
+// crawl can re-parse the history offline through the regular pipeline.
+type replayGetter struct {
+	archiver archive.Archive
+}
+
+func NewReplayGetter(archiver archive.Archive) URLGetter {
+	return &replayGetter{archiver: archiver}
+}
+
+func (r *replayGetter) Do(req *http.Request) (*http.Response, error) {
+	link, ok := req.Context().Value(linkContextKey{}).(*graph.Link)
+	if !ok {
+		return nil, xerrors.Errorf("replayGetter.Do: failed, url=%s; err=%w;", req.URL, ErrReplayLinkMissing)
+	}
+
+	source, date := ArchiveKey(link.Strategy, link.Date)
+
+	meta, content, err := r.archiver.Get(req.Context(), source, date, link.URL)
+	if errors.Is(err, archive.ErrNotFound) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	if err != nil {
+		return nil, xerrors.Errorf("replayGetter.Do: failed, url=%s; err=%w;", link.URL, err)
+	}
+
+	return &http.Response{
+		StatusCode: meta.StatusCode,
+		Body:       io.NopCloser(bytes.NewReader(content)),
+		Request:    req,
+	}, nil
+}
+
+// ArchiveKey returns the archive source and date of a link, dates are unified
+// into the TWSE format so both exchanges share the same layout.
+func ArchiveKey(strategy convert.Source, date string) (string, string) {
+	return strategy.String(), helper.UnifiedDateFormatToTwse(date)
+}
+
+func contextWithLink(ctx context.Context, payload *crawlerPayload) context.Context {
+	return context.WithValue(ctx, linkContextKey{}, &graph.Link{
+		URL:      payload.URL,
+		Date:     payload.Date,
+		Strategy: payload.Strategy,
+	})
+}
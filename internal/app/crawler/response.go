@@ -0,0 +1,144 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+// responseClass tells how the fetcher should react on a response.
+type responseClass int
+
+const (
+	responseOK responseClass = iota
+	// responseThrottled means the remote host asks to slow down, the host is
+	// paused and the link is retried later.
+	responseThrottled
+	// responsePermanent means retrying the link would not help.
+	responsePermanent
+	// responseTransient means the link could be retried right away.
+	responseTransient
+)
+
+// pages asking to retry later are tiny, larger bodies are regular content
+const maxBlockPageSize = 8 * 1024
+
+var ErrThrottled = errors.New("throttled by remote host")
+
+// retryLaterMarks are the "please retry later" messages of the anti-bot pages
+// in both encodings the exchanges serve.
+//
+//nolint:nolintlint, gochecknoglobals
+var retryLaterMarks = func() [][]byte {
+	marks := [][]byte{}
+
+	for _, msg := range []string{"請稍後再試", "查詢過於頻繁"} {
+		marks = append(marks, []byte(msg))
+
+		if big5, err := helper.EncodeBig5([]byte(msg)); err == nil {
+			marks = append(marks, big5)
+		}
+	}
+
+	return marks
+}()
+
+// blockPages are the paths the exchanges redirect the blocked requests to. Other
+// redirects are followed as regular content, the retry later pages among them are
+// caught on their body.
+//
+//nolint:nolintlint, gochecknoglobals
+var blockPages = []string{"/page/error.html"}
+
+// classifyStatus decides by the status code and the redirects followed by the client.
+func classifyStatus(req *http.Request, resp *http.Response) responseClass {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusForbidden:
+		return responseThrottled
+	case resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "":
+		return responseThrottled
+	case resp.StatusCode >= http.StatusInternalServerError, resp.StatusCode == http.StatusRequestTimeout:
+		return responseTransient
+	case resp.StatusCode >= http.StatusBadRequest:
+		return responsePermanent
+	case resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices:
+		return responseTransient
+	}
+
+	if isBlockRedirect(req, resp) {
+		return responseThrottled
+	}
+
+	return responseOK
+}
+
+// isBlockRedirect tells whether the client followed a redirect to a block page.
+func isBlockRedirect(req *http.Request, resp *http.Response) bool {
+	if resp.Request == nil || resp.Request.URL == nil || req.URL == nil {
+		return false
+	}
+
+	final := resp.Request.URL
+	if final.Host == req.URL.Host && final.Path == req.URL.Path {
+		return false
+	}
+
+	for _, page := range blockPages {
+		if strings.HasSuffix(final.Path, page) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRetryLaterPage tells whether a successful body is the anti-bot page instead of content.
+func isRetryLaterPage(body []byte) bool {
+	if len(body) > maxBlockPageSize {
+		return false
+	}
+
+	for _, mark := range retryLaterMarks {
+		if bytes.Contains(body, mark) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryAfter parses the Retry-After header, either in seconds or as a http date.
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
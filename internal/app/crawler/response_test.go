@@ -0,0 +1,192 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/samwang0723/stock-crawler/internal/retry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	t.Parallel()
+
+	link, _ := url.Parse("https://www.twse.com.tw/rwd/zh/fund/T86?response=csv")
+	blocked, _ := url.Parse("https://www.twse.com.tw/zh/page/error.html")
+	moved, _ := url.Parse("https://www.twse.com.tw/exchangeReport/T86?response=csv")
+
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		redirect *url.URL
+		want     responseClass
+	}{
+		{name: "ok", status: http.StatusOK, want: responseOK},
+		{name: "too many requests", status: http.StatusTooManyRequests, want: responseThrottled},
+		{name: "forbidden", status: http.StatusForbidden, want: responseThrottled},
+		{
+			name:   "unavailable with retry after",
+			status: http.StatusServiceUnavailable,
+			header: http.Header{"Retry-After": []string{"120"}},
+			want:   responseThrottled,
+		},
+		{name: "bad gateway", status: http.StatusBadGateway, want: responseTransient},
+		{name: "not found", status: http.StatusNotFound, want: responsePermanent},
+		{name: "redirected to block page", status: http.StatusOK, redirect: blocked, want: responseThrottled},
+		{name: "redirect within same page", status: http.StatusOK, redirect: link, want: responseOK},
+		{name: "redirected to moved page", status: http.StatusOK, redirect: moved, want: responseOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := &http.Request{URL: link}
+			resp := &http.Response{StatusCode: tt.status, Header: tt.header}
+
+			if tt.redirect != nil {
+				resp.Request = &http.Request{URL: tt.redirect}
+			}
+
+			assert.Equal(t, tt.want, classifyStatus(req, resp))
+		})
+	}
+}
+
+func TestIsRetryLaterPage(t *testing.T) {
+	t.Parallel()
+
+	big5, _ := helper.EncodeBig5([]byte("<html>很抱歉，請稍後再試</html>"))
+
+	assert.True(t, isRetryLaterPage([]byte("<html>查詢過於頻繁</html>")))
+	assert.True(t, isRetryLaterPage(big5))
+	assert.False(t, isRetryLaterPage([]byte("\"證券代號\",\"證券名稱\"")))
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2022, 8, 1, 8, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 2*time.Minute, retryAfter(http.Header{"Retry-After": []string{"120"}}, now))
+	assert.Equal(t, time.Hour, retryAfter(http.Header{
+		"Retry-After": []string{now.Add(time.Hour).Format(http.TimeFormat)},
+	}, now))
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{}, now))
+}
+
+type mockStatusHTTPClient struct {
+	header http.Header
+	status int
+}
+
+func (ms *mockStatusHTTPClient) Do(_ *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: ms.status,
+		Header:     ms.header,
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+	}, nil
+}
+
+func TestLinkFetcherBackoff(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "crawler").Logger()
+
+	tests := []struct {
+		client    *mockStatusHTTPClient
+		name      string
+		wantErr   error
+		wantPause bool
+		noRetry   bool
+	}{
+		{
+			name:      "throttled honors retry after",
+			client:    &mockStatusHTTPClient{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"60"}}},
+			wantErr:   ErrThrottled,
+			wantPause: true,
+		},
+		{
+			name:    "not found is not retried",
+			client:  &mockStatusHTTPClient{status: http.StatusNotFound},
+			noRetry: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := Config{URLGetter: tt.client, RateLimitInterval: 1, Logger: &logger}
+			throttler := newHostThrottler(cfg)
+			fetcher := newLinkFetcher(cfg, throttler, nil)
+
+			payload := &crawlerPayload{URL: "https://www.twse.com.tw/rwd/zh/fund/T86", Strategy: convert.TwseThreePrimary}
+
+			_, err := fetcher.Process(context.TODO(), payload)
+			assert.Error(t, err)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.noRetry, errors.As(err, &retry.Stop{}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			paused := throttler.Wait(ctx, "www.twse.com.tw") != nil
+			assert.Equal(t, tt.wantPause, paused)
+		})
+	}
+}
+
+func TestLinkFetcherCircuit(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "crawler").Logger()
+	cfg := Config{URLGetter: &mockErrorHTTPClient{}, Logger: &logger}
+	breaker := circuit.NewGroup(circuit.Config{Threshold: 2, CoolDown: time.Hour})
+	fetcher := newLinkFetcher(cfg, newHostThrottler(cfg), breaker)
+
+	payload := &crawlerPayload{URL: "https://www.twse.com.tw/rwd/zh/fund/T86", Strategy: convert.TwseThreePrimary}
+
+	for i := 0; i < 2; i++ {
+		_, err := fetcher.Process(context.TODO(), payload)
+		assert.False(t, errors.As(err, &retry.Stop{}))
+	}
+
+	// the host fails fast without retries once the circuit is open
+	_, err := fetcher.Process(context.TODO(), payload)
+	assert.ErrorIs(t, err, circuit.ErrOpen)
+	assert.True(t, errors.As(err, &retry.Stop{}))
+	assert.Equal(t, "open", breaker.Snapshots()[0].State)
+}
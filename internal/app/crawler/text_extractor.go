@@ -32,7 +32,7 @@ func newTextExtractor(cfg Config) *textExtractor {
 }
 
 func (te *textExtractor) Process(
-	_ context.Context,
+	ctx context.Context,
 	raw pipeline.Payload,
 ) (pipeline.Payload, error) {
 	payload, ok := raw.(*crawlerPayload)
@@ -42,12 +42,25 @@ func (te *textExtractor) Process(
 
 	te.parser.SetStrategy(payload.Strategy, payload.Date)
 
+	progress := progressFromContext(ctx)
+
 	err := te.parser.Execute(payload.RawContent, payload.URL)
 	if err != nil {
+		progress.Failed(payload.Strategy, err)
+
 		return nil, xerrors.Errorf("parse error: %w", err)
 	}
 
 	payload.ParsedContent = te.parser.Flush()
 
+	if payload.ParsedContent != nil {
+		progress.Parsed(payload.Strategy, len(*payload.ParsedContent))
+	}
+
 	return payload, nil
 }
+
+// Abandon implements pipeline.Abandoner.
+func (te *textExtractor) Abandon(ctx context.Context, p pipeline.Payload, err error) {
+	abandon(ctx, p, err)
+}
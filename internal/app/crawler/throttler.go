@@ -0,0 +1,116 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/pipeline"
+	"github.com/samwang0723/stock-crawler/internal/ratelimit"
+	"golang.org/x/xerrors"
+)
+
+const (
+	millisecondsPerSecond = 1000
+	// pause of a throttled host if it does not tell when to come back
+	defaultThrottlePause = 30 * time.Second
+	maxThrottlePause     = 10 * time.Minute
+	// the host is kept slowed down after the pause for a while
+	throttleCoolDown = 5 * time.Minute
+)
+
+var _ pipeline.Throttler = (*hostThrottler)(nil)
+
+// RateLimit paces the downloads of a source, Rate is the steady number of
+// requests per second and Burst the number of requests allowed at once.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// hostThrottler paces the fetch stage per remote host, sources served by
+// the same host share their pace.
+type hostThrottler struct {
+	limiter *ratelimit.Group
+}
+
+func newHostThrottler(cfg Config) *hostThrottler {
+	// hosts without dedicated settings keep the pace of the global interval
+	fallback := ratelimit.Config{Burst: 1}
+	if cfg.RateLimitInterval > 0 {
+		fallback.Rate = millisecondsPerSecond / float64(cfg.RateLimitInterval)
+	}
+
+	hosts := make(map[string]ratelimit.Config, len(cfg.RateLimits))
+
+	for source, limit := range cfg.RateLimits {
+		def, err := convert.Lookup(source)
+		if err != nil {
+			continue
+		}
+
+		host := def.Host()
+
+		// sources sharing a host are paced by the most conservative setting
+		if prev, ok := hosts[host]; ok && prev.Rate <= limit.Rate {
+			continue
+		}
+
+		hosts[host] = ratelimit.Config{Rate: limit.Rate, Burst: limit.Burst}
+	}
+
+	return &hostThrottler{limiter: ratelimit.NewGroup(fallback, hosts)}
+}
+
+func (t *hostThrottler) Lane(p pipeline.Payload) string {
+	payload, ok := p.(*crawlerPayload)
+	if !ok {
+		return ""
+	}
+
+	uri, err := url.Parse(payload.URL)
+	if err != nil {
+		return ""
+	}
+
+	return uri.Host
+}
+
+func (t *hostThrottler) Wait(ctx context.Context, lane string) error {
+	if err := t.limiter.Wait(ctx, lane); err != nil {
+		return xerrors.Errorf("hostThrottler.Wait: failed, host=%s; err=%w;", lane, err)
+	}
+
+	return nil
+}
+
+// Backoff pauses the host after being throttled, honoring the Retry-After hint
+// if any, and returns the pause applied.
+func (t *hostThrottler) Backoff(lane string, retryAfter time.Duration) time.Duration {
+	pause := retryAfter
+	if pause <= 0 {
+		pause = defaultThrottlePause
+	}
+
+	if pause > maxThrottlePause {
+		pause = maxThrottlePause
+	}
+
+	t.limiter.Throttle(lane, pause, throttleCoolDown)
+
+	return pause
+}
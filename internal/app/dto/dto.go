@@ -13,10 +13,170 @@
 // limitations under the License.
 package dto
 
-import "github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+import (
+	"slices"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+)
 
 type StartCronjobRequest struct {
+	// Action is empty for downloads, or manages the schedule of ScheduleID.
+	Action     Action `json:"action,omitempty"`
+	ScheduleID string `json:"scheduleId,omitempty"`
+	// Schedule is the cron spec of a scheduled download.
 	Schedule string           `json:"schedule"`
 	Types    []convert.Source `json:"types"`
-	Rewind   int              `json:"rewind"`
+	// Date to download as 20220801, or 202208 for monthly sources, takes
+	// precedence over Rewind if specified.
+	Date string `json:"date,omitempty"`
+	// From and To download every trading day of the range as 20220801, both
+	// included, monthly sources once per month and undated sources once.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// StockIDs limits the per-stock sources to the given stocks, otherwise the stocks
+	// of the universe matching the Markets, Categories and SecurityTypes filters.
+	StockIDs      []string `json:"stockIds,omitempty"`
+	Markets       []string `json:"markets,omitempty"`
+	Categories    []string `json:"categories,omitempty"`
+	SecurityTypes []string `json:"securityTypes,omitempty"`
+	// Rewind offsets the query date, in days for daily sources and in
+	// months for monthly sources.
+	Rewind int `json:"rewind"`
+	// Refresh downloads again the units of work checkpointed on the date already.
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// StockFilter returns the stocks the per-stock sources of the request download.
+func (r *StartCronjobRequest) StockFilter() *StockFilter {
+	return &StockFilter{
+		StockIDs:      r.StockIDs,
+		Markets:       r.Markets,
+		Categories:    r.Categories,
+		SecurityTypes: r.SecurityTypes,
+	}
+}
+
+// StockFilter selects stocks of the universe, the given stocks are taken as is while
+// the other filters are combined, empty ones matching every stock.
+type StockFilter struct {
+	StockIDs      []string `json:"stockIds,omitempty"`
+	Markets       []string `json:"markets,omitempty"`
+	Categories    []string `json:"categories,omitempty"`
+	SecurityTypes []string `json:"securityTypes,omitempty"`
+}
+
+// Match tells whether the stock passes every filter.
+func (f *StockFilter) Match(stock *entity.Stock) bool {
+	return matchAny(f.StockIDs, stock.StockID) &&
+		matchAny(f.Markets, stock.Market) &&
+		matchAny(f.Categories, stock.Category) &&
+		matchAny(f.SecurityTypes, stock.SecurityType)
+}
+
+func matchAny(values []string, value string) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
+
+// ListedStock is a stock of the universe maintained by the crawled stock lists.
+type ListedStock struct {
+	entity.Stock
+	ListedAt  time.Time `json:"listedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// MissingSince is set once the stock left its list, it is delisted once every
+	// list was crawled without the stock since.
+	MissingSince *time.Time `json:"missingSince,omitempty"`
+	DelistedAt   *time.Time `json:"delistedAt,omitempty"`
+	// List is the stock list the stock was last seen on.
+	List convert.Source `json:"list"`
+}
+
+// Action of a request received on the download topic.
+type Action string
+
+const (
+	ActionDownload       Action = ""
+	ActionAddSchedule    Action = "add_schedule"
+	ActionRemoveSchedule Action = "remove_schedule"
+	ActionPauseSchedule  Action = "pause_schedule"
+	ActionResumeSchedule Action = "resume_schedule"
+)
+
+type JobStatus string
+
+const (
+	JobQueued          JobStatus = "queued"
+	JobRunning         JobStatus = "running"
+	JobSucceeded       JobStatus = "succeeded"
+	JobPartiallyFailed JobStatus = "partially_failed"
+	JobFailed          JobStatus = "failed"
+	JobCanceled        JobStatus = "canceled"
+)
+
+// Job is the record of a download run, triggered by a request or a cronjob.
+type Job struct {
+	CreatedAt  time.Time            `json:"createdAt"`
+	StartedAt  *time.Time           `json:"startedAt,omitempty"`
+	FinishedAt *time.Time           `json:"finishedAt,omitempty"`
+	Request    *StartCronjobRequest `json:"request"`
+	// Sources keeps the progress of each requested source by its name.
+	Sources map[string]*SourceProgress `json:"sources"`
+	ID      string                     `json:"id"`
+	// Parent is the job whose work queue the job helps with, run by another instance.
+	Parent string    `json:"parent,omitempty"`
+	Status JobStatus `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// SourceProgress counts what a job did for a source. Errors counts every failed
+// attempt of fetching, parsing or publishing, retried attempts included, while
+// Abandoned counts the links given up after their last attempt. Retries counts
+// the downloads repeated as the source was not published yet, Unpublished marks
+// a source still empty by its deadline.
+type SourceProgress struct {
+	Links       int  `json:"links"`
+	Fetched     int  `json:"fetched"`
+	Parsed      int  `json:"parsed"`
+	Published   int  `json:"published"`
+	Errors      int  `json:"errors"`
+	Abandoned   int  `json:"abandoned,omitempty"`
+	Skipped     int  `json:"skipped,omitempty"`
+	Retries     int  `json:"retries,omitempty"`
+	Unpublished bool `json:"unpublished,omitempty"`
+}
+
+// Finished tells whether the job reached a final status.
+func (j *Job) Finished() bool {
+	return j.Status != JobQueued && j.Status != JobRunning
+}
+
+// Backfill is the checkpoint of a date range download, resumed from the day
+// after Done if the instance running it stops.
+type Backfill struct {
+	UpdatedAt time.Time            `json:"updatedAt"`
+	Request   *StartCronjobRequest `json:"request"`
+	Key       string               `json:"key"`
+	JobID     string               `json:"jobId"`
+	// Done is the last downloaded day as 20220801.
+	Done string `json:"done,omitempty"`
+	// Failed lists the days which failed to download.
+	Failed   []string `json:"failed,omitempty"`
+	Finished bool     `json:"finished"`
+	// Abandoned marks a backfill canceled on purpose, it is not resumed anymore
+	// unless requested again.
+	Abandoned bool `json:"abandoned,omitempty"`
+}
+
+// Schedule is a download registered as cronjob, Next and Prev are the run times
+// of the cronjob and only set while registered.
+type Schedule struct {
+	CreatedAt time.Time            `json:"createdAt"`
+	Next      *time.Time           `json:"next,omitempty"`
+	Prev      *time.Time           `json:"prev,omitempty"`
+	Request   *StartCronjobRequest `json:"request"`
+	ID        string               `json:"id"`
+	Paused    bool                 `json:"paused"`
+	// Static schedules are declared in the config and can not be managed at runtime.
+	Static bool `json:"static"`
 }
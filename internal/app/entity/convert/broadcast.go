@@ -3,4 +3,8 @@ package convert
 type InterceptData struct {
 	Data *[]any
 	Type Source
+	// URL and Date the data was downloaded from, the URL of the last page for the
+	// aggregated records.
+	URL  string
+	Date string
 }
@@ -25,6 +25,11 @@ const (
 	TwseStockList
 	TpexStockList
 	StakeConcentration
+	TwseMarginTrade
+	TpexMarginTrade
+	TwseMonthlyRevenue
+	TpexMonthlyRevenue
+	TwseHolidaySchedule
 )
 
 type Data struct {
@@ -354,11 +354,27 @@ func TestStock(t *testing.T) {
 				Target:  TwseStockList,
 			},
 			exp: &entity.Stock{
-				StockID:  "2330",
-				Name:     "ABC",
-				Country:  "TW",
-				Category: "XXX",
-				Market:   "otc",
+				StockID:      "2330",
+				Name:         "ABC",
+				Country:      "TW",
+				Category:     "XXX",
+				Market:       "otc",
+				SecurityType: entity.SecurityStock,
+			},
+		},
+		{
+			name: "convert TDR",
+			val: &Data{
+				RawData: []string{"9103　美德醫療-DR", "", "", "上市", "XXX"},
+				Target:  TwseStockList,
+			},
+			exp: &entity.Stock{
+				StockID:      "9103",
+				Name:         "美德醫療-DR",
+				Country:      "TW",
+				Category:     "臺灣存託憑證(TDR)",
+				Market:       "tse",
+				SecurityType: entity.SecurityTDR,
 			},
 		},
 		{
@@ -392,3 +408,250 @@ func TestStock(t *testing.T) {
 		})
 	}
 }
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		source  Source
+		date    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "dated source",
+			source: TwseDailyClose,
+			date:   "20220525",
+			want:   "https://www.twse.com.tw/exchangeReport/MI_INDEX?response=csv&date=20220525&type=ALLBUT0999",
+		},
+		{
+			name:   "undated source",
+			source: TwseStockList,
+			date:   "20220525",
+			want:   "https://isin.twse.com.tw/isin/C_public.jsp?strMode=2",
+		},
+		{
+			name:   "monthly source",
+			source: TpexMonthlyRevenue,
+			date:   "202210",
+			want:   "https://mops.twse.com.tw/nas/t21/otc/t21sc03_111_10_0.html",
+		},
+		{
+			name:    "unregistered source",
+			source:  Source(-1),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			def, err := Lookup(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Lookup() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if err == nil {
+				assert.Equal(t, tt.want, def.Link(tt.date))
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source Source
+		want   string
+	}{
+		{
+			name:   "exchange csv",
+			source: TpexDailyClose,
+			want:   "wwwov.tpex.org.tw",
+		},
+		{
+			name:   "per stock template",
+			source: StakeConcentration,
+			want:   "fubon-ebrokerdj.fbs.com.tw",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			def, err := Lookup(tt.source)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, def.Host())
+		})
+	}
+}
+
+func TestMarginTrade(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		val  *Data
+		exp  *entity.MarginTrade
+		name string
+	}{
+		{
+			name: "convert TwseMarginTrade",
+			val: &Data{
+				ParseDate: "20221130",
+				RawData: []string{
+					"2330", "台積電", "1,733", "2,088", "29", "24,521", "24,137", "6,482,309",
+					"68", "262", "0", "1,285", "1,479", "6,482,309", "23", " ",
+				},
+				Target: TwseMarginTrade,
+			},
+			exp: &entity.MarginTrade{
+				StockID:           "2330",
+				Date:              "20221130",
+				MarginBuy:         1733,
+				MarginSell:        2088,
+				MarginRedemption:  29,
+				MarginPrevBalance: 24521,
+				MarginBalance:     24137,
+				MarginQuota:       6482309,
+				ShortBuy:          68,
+				ShortSell:         262,
+				ShortPrevBalance:  1285,
+				ShortBalance:      1479,
+				ShortQuota:        6482309,
+				Offset:            23,
+			},
+		},
+		{
+			name: "convert TpexMarginTrade",
+			val: &Data{
+				ParseDate: "20221130",
+				RawData: []string{
+					"3105", "穩懋", "3,217", "231", "304", "3", "3,141", "0", "0.74", "423,799",
+					"2,411", "198", "141", "0", "2,468", "0", "0.58", "423,799", "7", "",
+				},
+				Target: TpexMarginTrade,
+			},
+			exp: &entity.MarginTrade{
+				StockID:           "3105",
+				Date:              "20221130",
+				MarginBuy:         231,
+				MarginSell:        304,
+				MarginRedemption:  3,
+				MarginPrevBalance: 3217,
+				MarginBalance:     3141,
+				MarginQuota:       423799,
+				ShortBuy:          141,
+				ShortSell:         198,
+				ShortPrevBalance:  2411,
+				ShortBalance:      2468,
+				ShortQuota:        423799,
+				Offset:            7,
+			},
+		},
+		{
+			name: "empty ConvertData",
+			val:  nil,
+			exp:  nil,
+		},
+		{
+			name: "missing elements in ConvertData",
+			val: &Data{
+				ParseDate: "20221130",
+				RawData:   []string{"2330", "", "1000", "1000"},
+				Target:    TpexMarginTrade,
+			},
+			exp: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := MarginTrade()
+			res := c.Execute(tt.val)
+			if val, ok := res.(*entity.MarginTrade); ok {
+				assert.Equal(t, tt.exp, val)
+			} else {
+				t.Errorf("cannot convert MarginTrade: %+v", res)
+			}
+		})
+	}
+}
+
+func TestMonthlyRevenue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		val  *Data
+		exp  *entity.MonthlyRevenue
+		name string
+	}{
+		{
+			name: "convert MonthlyRevenue",
+			val: &Data{
+				ParseDate: "202210",
+				RawData: []string{
+					"1102", "亞泥", "7,285,024", "7,613,497", "7,926,108", "-4.31", "-8.08",
+					"67,391,612", "64,185,004", "4.99",
+				},
+				Target: TwseMonthlyRevenue,
+			},
+			exp: &entity.MonthlyRevenue{
+				StockID:                   "1102",
+				Month:                     "202210",
+				Revenue:                   7285024,
+				LastMonthRevenue:          7613497,
+				LastYearRevenue:           7926108,
+				MoM:                       -4.31,
+				YoY:                       -8.08,
+				CumulativeRevenue:         67391612,
+				LastYearCumulativeRevenue: 64185004,
+				CumulativeYoY:             4.99,
+			},
+		},
+		{
+			name: "empty ConvertData",
+			val:  nil,
+			exp:  nil,
+		},
+		{
+			name: "missing elements in ConvertData",
+			val: &Data{
+				ParseDate: "202210",
+				RawData:   []string{"1102", "亞泥", "7,285,024"},
+				Target:    TwseMonthlyRevenue,
+			},
+			exp: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := MonthlyRevenue()
+			res := c.Execute(tt.val)
+			if val, ok := res.(*entity.MonthlyRevenue); ok {
+				assert.Equal(t, tt.exp, val)
+			} else {
+				t.Errorf("cannot convert MonthlyRevenue: %+v", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package convert
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+)
+
+const rocYearOffset = 1911
+
+//nolint:nolintlint, gochecknoglobals
+var holidayDate = regexp.MustCompile(`^(\d{2,4})\D+(\d{1,2})\D+(\d{1,2})`)
+
+type holidayImpl struct{}
+
+func Holiday() IConvert {
+	return &holidayImpl{}
+}
+
+// Execute converts a row of the market holiday schedule, rows announcing the first
+// or last trading day and the non-trading make-up workdays are not holidays and
+// converted into nil.
+func (c *holidayImpl) Execute(data *Data) any {
+	if data == nil || len(data.RawData) < 2 {
+		return nil
+	}
+
+	date := parseHolidayDate(data.RawData[1])
+	if date == "" {
+		return nil
+	}
+
+	name := strings.TrimSpace(data.RawData[0])
+
+	var description string
+	if len(data.RawData) > 3 {
+		description = strings.TrimSpace(data.RawData[3])
+	}
+
+	text := name + description
+
+	switch {
+	case strings.Contains(text, "開始交易") || strings.Contains(text, "最後交易"):
+		return nil
+	case strings.Contains(text, "補行交易"):
+		return &entity.Holiday{Date: date, Name: name, Description: description, Trading: true}
+	case strings.Contains(text, "補行上班"):
+		// weekends are closed already unless the market trades
+		return nil
+	}
+
+	return &entity.Holiday{Date: date, Name: name, Description: description}
+}
+
+// parseHolidayDate converts 113/01/01 or 2024/01/01 into 20240101.
+func parseHolidayDate(input string) string {
+	match := holidayDate.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return ""
+	}
+
+	year, _ := strconv.Atoi(match[1])
+	month, _ := strconv.Atoi(match[2])
+	day, _ := strconv.Atoi(match[3])
+
+	if year < rocYearOffset {
+		year += rocYearOffset
+	}
+
+	return fmt.Sprintf("%04d%02d%02d", year, month, day)
+}
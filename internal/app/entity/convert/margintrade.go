@@ -0,0 +1,84 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package convert
+
+import (
+	"strings"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+type marginTradeImpl struct{}
+
+func MarginTrade() IConvert {
+	return &marginTradeImpl{}
+}
+
+func (c *marginTradeImpl) Execute(data *Data) any {
+	var output *entity.MarginTrade
+	if data == nil || len(data.RawData) < 15 {
+		return output
+	}
+
+	//nolint:nolintlint, exhaustive
+	switch data.Target {
+	case TwseMarginTrade:
+		output = &entity.MarginTrade{
+			StockID:           data.RawData[0],
+			Date:              data.ParseDate,
+			MarginBuy:         toUint64(data.RawData[2]),
+			MarginSell:        toUint64(data.RawData[3]),
+			MarginRedemption:  toUint64(data.RawData[4]),
+			MarginPrevBalance: toUint64(data.RawData[5]),
+			MarginBalance:     toUint64(data.RawData[6]),
+			MarginQuota:       toUint64(data.RawData[7]),
+			ShortBuy:          toUint64(data.RawData[8]),
+			ShortSell:         toUint64(data.RawData[9]),
+			ShortRedemption:   toUint64(data.RawData[10]),
+			ShortPrevBalance:  toUint64(data.RawData[11]),
+			ShortBalance:      toUint64(data.RawData[12]),
+			ShortQuota:        toUint64(data.RawData[13]),
+			Offset:            toUint64(data.RawData[14]),
+		}
+	case TpexMarginTrade:
+		if len(data.RawData) < 19 {
+			return output
+		}
+
+		output = &entity.MarginTrade{
+			StockID:           data.RawData[0],
+			Date:              data.ParseDate,
+			MarginPrevBalance: toUint64(data.RawData[2]),
+			MarginBuy:         toUint64(data.RawData[3]),
+			MarginSell:        toUint64(data.RawData[4]),
+			MarginRedemption:  toUint64(data.RawData[5]),
+			MarginBalance:     toUint64(data.RawData[6]),
+			MarginQuota:       toUint64(data.RawData[9]),
+			ShortPrevBalance:  toUint64(data.RawData[10]),
+			ShortSell:         toUint64(data.RawData[11]),
+			ShortBuy:          toUint64(data.RawData[12]),
+			ShortRedemption:   toUint64(data.RawData[13]),
+			ShortBalance:      toUint64(data.RawData[14]),
+			ShortQuota:        toUint64(data.RawData[17]),
+			Offset:            toUint64(data.RawData[18]),
+		}
+	}
+
+	return output
+}
+
+func toUint64(v string) uint64 {
+	return helper.ToUint64(strings.ReplaceAll(strings.TrimSpace(v), ",", ""))
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package convert
+
+import (
+	"strings"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+type monthlyRevenueImpl struct{}
+
+func MonthlyRevenue() IConvert {
+	return &monthlyRevenueImpl{}
+}
+
+func (c *monthlyRevenueImpl) Execute(data *Data) any {
+	var output *entity.MonthlyRevenue
+	if data == nil || len(data.RawData) < 10 {
+		return output
+	}
+
+	output = &entity.MonthlyRevenue{
+		StockID:                   strings.TrimSpace(data.RawData[0]),
+		Month:                     data.ParseDate,
+		Revenue:                   helper.ToInt64(strings.ReplaceAll(data.RawData[2], ",", "")),
+		LastMonthRevenue:          helper.ToInt64(strings.ReplaceAll(data.RawData[3], ",", "")),
+		LastYearRevenue:           helper.ToInt64(strings.ReplaceAll(data.RawData[4], ",", "")),
+		MoM:                       helper.ToFloat32(strings.ReplaceAll(data.RawData[5], ",", "")),
+		YoY:                       helper.ToFloat32(strings.ReplaceAll(data.RawData[6], ",", "")),
+		CumulativeRevenue:         helper.ToInt64(strings.ReplaceAll(data.RawData[7], ",", "")),
+		LastYearCumulativeRevenue: helper.ToInt64(strings.ReplaceAll(data.RawData[8], ",", "")),
+		CumulativeYoY:             helper.ToFloat32(strings.ReplaceAll(data.RawData[9], ",", "")),
+	}
+
+	return output
+}
@@ -0,0 +1,380 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/samwang0723/stock-crawler/internal/kafka"
+	"golang.org/x/xerrors"
+)
+
+// ParserType identifies which parser strategy handles the raw content of a source.
+type ParserType int
+
+const (
+	CsvParser ParserType = iota
+	HTMLParser
+	ConcentrationParser
+	RevenueParser
+	HolidayParser
+)
+
+// Period is the publishing frequency of a source, which decides how the
+// rewind offset of a download request is interpreted.
+type Period int
+
+const (
+	// Daily sources rewind by days.
+	Daily Period = iota
+	// Monthly sources rewind by months, offset 0 being the latest published month.
+	Monthly
+	// Yearly sources offset by years, offset 0 being the current year.
+	Yearly
+)
+
+//nolint:nolintlint, lll
+const (
+	twseDailyCloseURL   = "https://www.twse.com.tw/exchangeReport/MI_INDEX?response=csv&date=%s&type=ALLBUT0999"
+	twseThreePrimaryURL = "https://www.twse.com.tw/rwd/zh/fund/T86?response=csv&date=%s&selectType=ALLBUT0999"
+	tpexDailyCloseURL   = "https://wwwov.tpex.org.tw/web/stock/aftertrading/otc_quotes_no1430/stk_wn1430_result.php?l=zh-tw&o=csv&d=%s&se=EW&s=0,asc,0"
+	tpexThreePrimaryURL = "https://www.tpex.org.tw/web/stock/3insti/daily_trade/3itrade_hedge_result.php?l=zh-tw&o=csv&se=EW&t=D&d=%s"
+	twseStocksURL       = "https://isin.twse.com.tw/isin/C_public.jsp?strMode=2"
+	tpexStocksURL       = "https://isin.twse.com.tw/isin/C_public.jsp?strMode=4"
+	twseMarginTradeURL  = "https://www.twse.com.tw/rwd/zh/marginTrading/MI_MARGN?response=csv&date=%s&selectType=ALL"
+	tpexMarginTradeURL  = "https://www.tpex.org.tw/web/stock/margin_trading/margin_balance/margin_bal_result.php?l=zh-tw&o=csv&d=%s"
+	// monthly revenue reports of listed (sii) and otc companies, indexed by ROC year and month
+	twseMonthlyRevenueURL = "https://mops.twse.com.tw/nas/t21/sii/t21sc03_%s_0.html"
+	tpexMonthlyRevenueURL = "https://mops.twse.com.tw/nas/t21/otc/t21sc03_%s_0.html"
+	// market holiday schedule, indexed by ROC year
+	twseHolidayScheduleURL = "https://www.twse.com.tw/rwd/zh/holidaySchedule/holidaySchedule?response=csv&queryYear=%s"
+	// backup: stockchannelnew.sinotrade.com.tw
+	concentrationURL = "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_%s_%d.djhtm"
+)
+
+var ErrSourceNotRegistered = errors.New("source not registered")
+
+// Definition declares everything the crawling layers need to know about a source:
+// where to download it, how to format the query date, how to parse and convert the
+// raw content, which entity it produces and which kafka topic it is published to.
+type Definition struct {
+	// Converter turns a parsed row into the entity.
+	Converter IConvert
+
+	// Entity is the pointer type produced by the converter.
+	Entity reflect.Type
+
+	// URL is the download link template. Dated sources take the query date as
+	// the only verb, per-stock sources take the stock id and the page index.
+	URL string
+
+	// DateFormat used when querying the source, empty if the source is not dated.
+	DateFormat string
+
+	// Period of the published data, defaults to Daily.
+	Period Period
+
+	// Topic is the kafka topic the entities are published to.
+	Topic string
+
+	// Parser strategy and the minimum number of columns of a valid row.
+	Parser   ParserType
+	Capacity int
+
+	// PerStock marks sources that must be downloaded page by page for each stock.
+	PerStock bool
+
+	// Pages of a per-stock source, downloaded for every stock.
+	Pages []int
+
+	// Calendar marks sources updating the trading calendar instead of being published.
+	Calendar bool
+
+	// Universe marks the stock lists maintaining the stock universe, every one of
+	// them lists the stocks of a market.
+	Universe bool
+}
+
+//nolint:nolintlint, gochecknoglobals
+var registry = map[Source]*Definition{
+	TwseDailyClose: {
+		Converter:  DailyClose(),
+		Entity:     reflect.TypeOf(&entity.DailyClose{}),
+		URL:        twseDailyCloseURL,
+		DateFormat: helper.TwseDateFormat,
+		Topic:      kafka.DailyClosesV1,
+		Parser:     CsvParser,
+		Capacity:   17,
+	},
+	TpexDailyClose: {
+		Converter:  DailyClose(),
+		Entity:     reflect.TypeOf(&entity.DailyClose{}),
+		URL:        tpexDailyCloseURL,
+		DateFormat: helper.TpexDateFormat,
+		Topic:      kafka.DailyClosesV1,
+		Parser:     CsvParser,
+		Capacity:   17,
+	},
+	TwseThreePrimary: {
+		Converter:  ThreePrimary(),
+		Entity:     reflect.TypeOf(&entity.ThreePrimary{}),
+		URL:        twseThreePrimaryURL,
+		DateFormat: helper.TwseDateFormat,
+		Topic:      kafka.ThreePrimaryV1,
+		Parser:     CsvParser,
+		Capacity:   19,
+	},
+	TpexThreePrimary: {
+		Converter:  ThreePrimary(),
+		Entity:     reflect.TypeOf(&entity.ThreePrimary{}),
+		URL:        tpexThreePrimaryURL,
+		DateFormat: helper.TpexDateFormat,
+		Topic:      kafka.ThreePrimaryV1,
+		Parser:     CsvParser,
+		Capacity:   24,
+	},
+	TwseStockList: {
+		Converter: Stock(),
+		Entity:    reflect.TypeOf(&entity.Stock{}),
+		URL:       twseStocksURL,
+		Topic:     kafka.StocksV1,
+		Parser:    HTMLParser,
+		Capacity:  5,
+		Universe:  true,
+	},
+	TpexStockList: {
+		Converter: Stock(),
+		Entity:    reflect.TypeOf(&entity.Stock{}),
+		URL:       tpexStocksURL,
+		Topic:     kafka.StocksV1,
+		Parser:    HTMLParser,
+		Capacity:  5,
+		Universe:  true,
+	},
+	StakeConcentration: {
+		Converter:  Concentration(),
+		Entity:     reflect.TypeOf(&entity.StakeConcentration{}),
+		URL:        concentrationURL,
+		DateFormat: helper.StakeConcentrationFormat,
+		Topic:      kafka.StakeConcentrationV1,
+		Parser:     ConcentrationParser,
+		Capacity:   7,
+		PerStock:   true,
+		// in order to get accurate data, we must query each page as the top 15 brokers
+		// may different from day to day and not possible to store all detailed daily data
+		Pages: []int{1, 2, 3, 4, 6},
+	},
+	TwseMarginTrade: {
+		Converter:  MarginTrade(),
+		Entity:     reflect.TypeOf(&entity.MarginTrade{}),
+		URL:        twseMarginTradeURL,
+		DateFormat: helper.TwseDateFormat,
+		Topic:      kafka.MarginTradeV1,
+		Parser:     CsvParser,
+		Capacity:   15,
+	},
+	TpexMarginTrade: {
+		Converter:  MarginTrade(),
+		Entity:     reflect.TypeOf(&entity.MarginTrade{}),
+		URL:        tpexMarginTradeURL,
+		DateFormat: helper.TpexDateFormat,
+		Topic:      kafka.MarginTradeV1,
+		Parser:     CsvParser,
+		Capacity:   19,
+	},
+	TwseMonthlyRevenue: {
+		Converter:  MonthlyRevenue(),
+		Entity:     reflect.TypeOf(&entity.MonthlyRevenue{}),
+		URL:        twseMonthlyRevenueURL,
+		DateFormat: helper.MonthlyFormat,
+		Period:     Monthly,
+		Topic:      kafka.MonthlyRevenueV1,
+		Parser:     RevenueParser,
+		Capacity:   10,
+	},
+	TpexMonthlyRevenue: {
+		Converter:  MonthlyRevenue(),
+		Entity:     reflect.TypeOf(&entity.MonthlyRevenue{}),
+		URL:        tpexMonthlyRevenueURL,
+		DateFormat: helper.MonthlyFormat,
+		Period:     Monthly,
+		Topic:      kafka.MonthlyRevenueV1,
+		Parser:     RevenueParser,
+		Capacity:   10,
+	},
+	TwseHolidaySchedule: {
+		Converter:  Holiday(),
+		Entity:     reflect.TypeOf(&entity.Holiday{}),
+		URL:        twseHolidayScheduleURL,
+		DateFormat: helper.YearlyFormat,
+		Period:     Yearly,
+		Parser:     HolidayParser,
+		Capacity:   2,
+		Calendar:   true,
+	},
+}
+
+// Lookup returns the definition registered for the source.
+func Lookup(source Source) (*Definition, error) {
+	def, ok := registry[source]
+	if !ok {
+		return nil, xerrors.Errorf("convert.Lookup: failed, source=%v; err=%w;", source, ErrSourceNotRegistered)
+	}
+
+	return def, nil
+}
+
+// UniverseSources returns the stock lists maintaining the stock universe.
+func UniverseSources() []Source {
+	var sources []Source
+
+	for source, def := range registry {
+		if def.Universe {
+			sources = append(sources, source)
+		}
+	}
+
+	slices.Sort(sources)
+
+	return sources
+}
+
+// ParseSource returns the registered source by its name, e.g. TwseDailyClose.
+func ParseSource(name string) (Source, error) {
+	for source := range registry {
+		if source.String() == name {
+			return source, nil
+		}
+	}
+
+	return 0, xerrors.Errorf("convert.ParseSource: failed, name=%s; err=%w;", name, ErrSourceNotRegistered)
+}
+
+// MarshalJSON encodes the source by its name.
+func (s Source) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// UnmarshalJSON accepts either the source name or its numeric value.
+func (s *Source) UnmarshalJSON(data []byte) error {
+	if name, err := strconv.Unquote(string(data)); err == nil {
+		source, err := ParseSource(name)
+		if err != nil {
+			return err
+		}
+
+		*s = source
+
+		return nil
+	}
+
+	value, err := strconv.Atoi(string(data))
+	if err != nil {
+		return xerrors.Errorf("convert.UnmarshalJSON: failed, value=%s; err=%w;", data, err)
+	}
+
+	*s = Source(value)
+
+	return nil
+}
+
+// Link formats the download link of a dated source, undated sources are returned as is.
+func (d *Definition) Link(date string) string {
+	if d.DateFormat == "" {
+		return d.URL
+	}
+
+	switch d.Period {
+	case Monthly:
+		return fmt.Sprintf(d.URL, helper.UnifiedMonthFormatToRoc(date))
+	case Yearly:
+		return fmt.Sprintf(d.URL, helper.UnifiedYearFormatToRoc(date))
+	case Daily:
+	}
+
+	return fmt.Sprintf(d.URL, date)
+}
+
+// Host returns the host serving the source. The URL template is not parsed
+// with net/url as the formatting verbs are not valid escapes.
+func (d *Definition) Host() string {
+	host := d.URL
+	if idx := strings.Index(host, "://"); idx >= 0 {
+		host = host[idx+len("://"):]
+	}
+
+	if idx := strings.IndexAny(host, "/?"); idx >= 0 {
+		host = host[:idx]
+	}
+
+	return host
+}
+
+// StockLink formats the download link of a per-stock source page.
+func (d *Definition) StockLink(stockID string, page int) string {
+	return fmt.Sprintf(d.URL, stockID, page)
+}
+
+// StockLinks formats the download links of every page of a per-stock source.
+func (d *Definition) StockLinks(stockID string) []string {
+	links := make([]string, 0, len(d.Pages))
+	for _, page := range d.Pages {
+		links = append(links, d.StockLink(stockID, page))
+	}
+
+	return links
+}
+
+// DateOf returns the date in the source date format, or the month and the year
+// for monthly and yearly sources.
+func (d *Definition) DateOf(date time.Time) string {
+	if d.DateFormat == "" {
+		return ""
+	}
+
+	if d.Period == Monthly {
+		return helper.GetMonthFromOffset(0, d.DateFormat, date)
+	}
+
+	return helper.FormatDate(date, d.DateFormat)
+}
+
+// QueryDate returns the date string with offset calendar days from today in the source date
+// format, monthly sources count the offset in months from the latest published month and
+// yearly sources in years from the current year instead. Daily downloads offset by trading
+// days through helper.Calendar and DateOf.
+func (d *Definition) QueryDate(offset int32) string {
+	if d.DateFormat == "" {
+		return ""
+	}
+
+	switch d.Period {
+	case Monthly:
+		// reports of the month are published by the 10th of the next month
+		return helper.GetMonthFromOffset(offset-1, d.DateFormat)
+	case Yearly:
+		return helper.FormatDate(time.Now().AddDate(int(offset), 0, 0), d.DateFormat)
+	case Daily:
+	}
+
+	return helper.GetDateFromOffset(offset, d.DateFormat)
+}
@@ -15,11 +15,16 @@ func _() {
 	_ = x[TwseStockList-4]
 	_ = x[TpexStockList-5]
 	_ = x[StakeConcentration-6]
+	_ = x[TwseMarginTrade-7]
+	_ = x[TpexMarginTrade-8]
+	_ = x[TwseMonthlyRevenue-9]
+	_ = x[TpexMonthlyRevenue-10]
+	_ = x[TwseHolidaySchedule-11]
 }
 
-const _Source_name = "TwseDailyCloseTwseThreePrimaryTpexDailyCloseTpexThreePrimaryTwseStockListTpexStockListStakeConcentration"
+const _Source_name = "TwseDailyCloseTwseThreePrimaryTpexDailyCloseTpexThreePrimaryTwseStockListTpexStockListStakeConcentrationTwseMarginTradeTpexMarginTradeTwseMonthlyRevenueTpexMonthlyRevenueTwseHolidaySchedule"
 
-var _Source_index = [...]uint8{0, 14, 30, 44, 60, 73, 86, 104}
+var _Source_index = [...]uint8{0, 14, 30, 44, 60, 73, 86, 104, 119, 134, 152, 170, 189}
 
 func (i Source) String() string {
 	if i < 0 || i >= Source(len(_Source_index)-1) {
@@ -42,16 +42,19 @@ func (c *stockImpl) Execute(data *Data) any {
 		market = "otc"
 	}
 
+	security := entity.SecurityStock
 	if len(data.RawData) == maxLength {
 		data.RawData[4] = "臺灣存託憑證(TDR)"
+		security = entity.SecurityTDR
 	}
 
 	output = &entity.Stock{
-		StockID:  strings.TrimSpace(str[0]),
-		Name:     strings.TrimSpace(str[1]),
-		Country:  "TW",
-		Market:   market,
-		Category: strings.TrimSpace(data.RawData[4]),
+		StockID:      strings.TrimSpace(str[0]),
+		Name:         strings.TrimSpace(str[1]),
+		Country:      "TW",
+		Market:       market,
+		Category:     strings.TrimSpace(data.RawData[4]),
+		SecurityType: security,
 	}
 
 	return output
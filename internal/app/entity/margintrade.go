@@ -0,0 +1,33 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package entity
+
+// MarginTrade is the daily margin purchase / short sale balance of a stock, in lots.
+type MarginTrade struct {
+	StockID           string `json:"stockId"`
+	Date              string `json:"date"`
+	MarginBuy         uint64 `json:"marginBuy"`
+	MarginSell        uint64 `json:"marginSell"`
+	MarginRedemption  uint64 `json:"marginRedemption"` // Cash repayment
+	MarginPrevBalance uint64 `json:"marginPrevBalance"`
+	MarginBalance     uint64 `json:"marginBalance"`
+	MarginQuota       uint64 `json:"marginQuota"`
+	ShortBuy          uint64 `json:"shortBuy"`
+	ShortSell         uint64 `json:"shortSell"`
+	ShortRedemption   uint64 `json:"shortRedemption"` // Stock repayment
+	ShortPrevBalance  uint64 `json:"shortPrevBalance"`
+	ShortBalance      uint64 `json:"shortBalance"`
+	ShortQuota        uint64 `json:"shortQuota"`
+	Offset            uint64 `json:"offset"` // Margin and short sale offsetting
+}
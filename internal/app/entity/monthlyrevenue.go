@@ -0,0 +1,28 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package entity
+
+// MonthlyRevenue is the revenue report of a company, amounts are in thousand NTD.
+type MonthlyRevenue struct {
+	StockID                   string  `json:"stockId"`
+	Month                     string  `json:"month"` // Reporting month, e.g. 202210
+	Revenue                   int64   `json:"revenue"`
+	LastMonthRevenue          int64   `json:"lastMonthRevenue"`
+	LastYearRevenue           int64   `json:"lastYearRevenue"`
+	MoM                       float32 `json:"mom"` // Month over month change in percentage
+	YoY                       float32 `json:"yoy"` // Year over year change in percentage
+	CumulativeRevenue         int64   `json:"cumulativeRevenue"`
+	LastYearCumulativeRevenue int64   `json:"lastYearCumulativeRevenue"`
+	CumulativeYoY             float32 `json:"cumulativeYoy"`
+}
@@ -35,30 +35,54 @@ type StakeConcentration struct {
 	SumSellShares uint64  `json:"sumSellShares"`
 	AvgBuyPrice   float32 `json:"avgBuyPrice"`
 	AvgSellPrice  float32 `json:"avgSellPrice"`
+	// Missing lists the indexes of Diff whose period was not downloaded, only partial
+	// records published after the aggregation timed out miss periods.
+	Missing []int32 `json:"missing,omitempty"`
 }
 
+// MapReduceStakeConcentration merges the period records of a stock, the periods not
+// given are marked as missing. Without the latest period the shares and prices are zero.
 func MapReduceStakeConcentration(objs []*StakeConcentration) *StakeConcentration {
 	volumeDiff := []int32{0, 0, 0, 0, 0}
+	present := make([]bool, len(volumeDiff))
 
 	var res *StakeConcentration
 
 	for _, val := range objs {
 		idx, err := strconv.Atoi(val.HiddenField)
+		if err != nil || idx < 0 || idx >= len(volumeDiff) {
+			log.Error().Msgf("invalid concentration period, stock_id=%s; period=%s;", val.StockID, val.HiddenField)
+
+			continue
+		}
+
 		// make sure to cover latest source of truth date's concentration data
-		if err == nil && idx == 0 {
+		if idx == 0 {
 			res = val.Clone()
 		}
 
 		volumeDiff[idx] = int32(val.SumBuyShares - val.SumSellShares)
+		present[idx] = true
 	}
 
 	if res == nil {
-		log.Error().Msg("no latest source of truth date's concentration data")
+		if len(objs) == 0 {
+			log.Error().Msg("no latest source of truth date's concentration data")
 
-		return nil
+			return nil
+		}
+
+		res = &StakeConcentration{StockID: objs[0].StockID, Date: objs[0].Date}
 	}
 
 	res.Diff = volumeDiff
+	res.Missing = nil
+
+	for idx, ok := range present {
+		if !ok {
+			res.Missing = append(res.Missing, int32(idx))
+		}
+	}
 
 	return res
 }
@@ -88,6 +112,7 @@ func (sc *StakeConcentration) Recycle() {
 	sc.SumSellShares = 0
 	sc.AvgBuyPrice = 0.0
 	sc.AvgSellPrice = 0.0
+	sc.Missing = nil
 
 	concentrationPool.Put(sc)
 }
@@ -13,10 +13,17 @@
 // limitations under the License.
 package entity
 
+// Security types of the listed stocks.
+const (
+	SecurityStock = "stock"
+	SecurityTDR   = "tdr"
+)
+
 type Stock struct {
-	StockID  string `json:"stockId"`
-	Name     string `json:"name"`
-	Country  string `json:"country"`
-	Category string `json:"category"`
-	Market   string `json:"market"`
+	StockID      string `json:"stockId"`
+	Name         string `json:"name"`
+	Country      string `json:"country"`
+	Category     string `json:"category"`
+	Market       string `json:"market"`
+	SecurityType string `json:"securityType"`
 }
@@ -0,0 +1,37 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package entity
+
+// StockChangeType tells what changed about a listed stock.
+type StockChangeType string
+
+const (
+	StockListed         StockChangeType = "listing"
+	StockDelisted       StockChangeType = "delisting"
+	StockRenamed        StockChangeType = "name_change"
+	StockRecategorized  StockChangeType = "category_change"
+	StockMarketTransfer StockChangeType = "market_transfer"
+)
+
+// StockChange is a change of the stock universe found by comparing a crawled stock list
+// with the previous snapshot, one per changed field. From and To hold the former and the
+// new name, category or market, Stock is the stock as listed after the change, or before
+// it for delistings.
+type StockChange struct {
+	Type  StockChangeType `json:"type"`
+	Date  string          `json:"date"`
+	From  string          `json:"from,omitempty"`
+	To    string          `json:"to,omitempty"`
+	Stock Stock           `json:"stock"`
+}
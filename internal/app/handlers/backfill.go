@@ -0,0 +1,317 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"crypto/sha1" //nolint:nolintlint, gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+const (
+	// backfillPace is the pause between two downloaded days
+	backfillPace = 10 * time.Second
+	// a running backfill refreshes its checkpoint every heartbeat, it is
+	// considered abandoned and resumable once the checkpoint is stale
+	backfillHeartbeat  = time.Minute
+	backfillStaleAfter = 3 * backfillHeartbeat
+	backfillLockPeriod = 5 * time.Minute
+)
+
+var ErrBackfillRunning = errors.New("backfill running on another instance")
+
+//nolint:nolintlint, gochecknoglobals
+var jsoni = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// backfillKey identifies the range request, the same request resumes the same checkpoint.
+func backfillKey(req *dto.StartCronjobRequest) string {
+	//nolint:nolintlint, errcheck, errchkjson
+	data, _ := jsoni.Marshal(&dto.StartCronjobRequest{
+		Types:         req.Types,
+		From:          req.From,
+		To:            req.To,
+		StockIDs:      req.StockIDs,
+		Markets:       req.Markets,
+		Categories:    req.Categories,
+		SecurityTypes: req.SecurityTypes,
+	})
+	sum := sha1.Sum(data) //nolint:nolintlint, gosec
+
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpoint guards the backfill record shared with the heartbeat.
+type checkpoint struct {
+	backfill *dto.Backfill
+	mu       sync.Mutex
+}
+
+func (c *checkpoint) update(fn func(backfill *dto.Backfill)) dto.Backfill {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn(c.backfill)
+	c.backfill.UpdatedAt = time.Now()
+
+	return *c.backfill
+}
+
+func (h *handlerImpl) saveCheckpoint(ctx context.Context, cp *checkpoint, fn func(backfill *dto.Backfill)) {
+	backfill := cp.update(fn)
+
+	if err := h.dataService.SaveBackfill(context.WithoutCancel(ctx), &backfill); err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.saveCheckpoint: failed, key=%s;", backfill.Key)
+	}
+}
+
+// loadCheckpoint resumes the checkpoint of the request, or starts a new one.
+func (h *handlerImpl) loadCheckpoint(ctx context.Context, jobID string, req *dto.StartCronjobRequest) (*checkpoint, error) {
+	key := backfillKey(req)
+
+	backfill, err := h.dataService.GetBackfill(ctx, key)
+	if err != nil && !errors.Is(err, cache.ErrCacheMiss) {
+		return nil, fmt.Errorf("handlers.loadCheckpoint: failed, reason: %w", err)
+	}
+
+	switch {
+	case err != nil, backfill.Finished:
+		backfill = &dto.Backfill{Key: key, Request: req}
+	case !backfill.Abandoned && backfill.JobID != jobID && time.Since(backfill.UpdatedAt) < backfillStaleAfter:
+		return nil, fmt.Errorf("handlers.loadCheckpoint: failed, key=%s; job=%s; reason: %w",
+			key, backfill.JobID, ErrBackfillRunning)
+	default:
+		h.logger.Info().Msgf("handlers.loadCheckpoint: resume, key=%s; done=%s;", key, backfill.Done)
+	}
+
+	backfill.JobID = jobID
+	backfill.Abandoned = false
+
+	return &checkpoint{backfill: backfill}, nil
+}
+
+// backfill downloads every trading day of the request range, the progress is
+// checkpointed after each day so another run of the same range resumes it.
+func (h *handlerImpl) backfill(ctx context.Context, jobID string, req *dto.StartCronjobRequest) error {
+	days, err := h.rangeDays(ctx, req.From, req.To)
+	if err != nil {
+		return fmt.Errorf("handlers.backfill: failed, reason: %w", err)
+	}
+
+	cp, err := h.loadCheckpoint(ctx, jobID, req)
+	if err != nil {
+		return fmt.Errorf("handlers.backfill: failed, reason: %w", err)
+	}
+
+	h.saveCheckpoint(ctx, cp, func(*dto.Backfill) {})
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go h.heartbeat(ctx, cp, done)
+
+	var (
+		resumed = cp.backfill.Done
+		prev    time.Time
+		// tells whether a day failed otherwise than by giving some links up
+		fatal bool
+	)
+
+	for _, day := range days {
+		date := day.Format(helper.TwseDateFormat)
+		if date <= resumed {
+			continue
+		}
+
+		dayReq := &dto.StartCronjobRequest{
+			Types:         rangeTypes(req.Types, prev, day),
+			Date:          date,
+			StockIDs:      req.StockIDs,
+			Markets:       req.Markets,
+			Categories:    req.Categories,
+			SecurityTypes: req.SecurityTypes,
+			Refresh:       req.Refresh,
+		}
+		prev = day
+
+		if len(dayReq.Types) == 0 {
+			continue
+		}
+
+		err := h.batchingDownload(ctx, jobID, dayReq)
+		if ctx.Err() != nil {
+			h.abandonCheckpoint(ctx, cp)
+
+			return fmt.Errorf("handlers.backfill: failed, date=%s; reason: %w", date, ctx.Err())
+		}
+
+		h.saveCheckpoint(ctx, cp, func(backfill *dto.Backfill) {
+			backfill.Done = date
+			if err != nil {
+				backfill.Failed = append(backfill.Failed, date)
+			}
+		})
+
+		if err != nil {
+			h.logger.Error().Err(err).Msgf("handlers.backfill: failed, date=%s;", date)
+
+			fatal = fatal || !errors.Is(err, crawler.ErrLinksFailed)
+		}
+
+		select {
+		case <-ctx.Done():
+			h.abandonCheckpoint(ctx, cp)
+
+			return fmt.Errorf("handlers.backfill: failed, reason: %w", ctx.Err())
+		case <-time.After(backfillPace):
+		}
+	}
+
+	var failed []string
+
+	h.saveCheckpoint(ctx, cp, func(backfill *dto.Backfill) {
+		backfill.Finished = true
+		failed = backfill.Failed
+	})
+
+	switch {
+	case len(failed) == 0:
+		return nil
+	case fatal:
+		return fmt.Errorf("handlers.backfill: failed, dates=%s;", strings.Join(failed, ","))
+	}
+
+	return fmt.Errorf("handlers.backfill: failed, dates=%s; reason: %w", strings.Join(failed, ","), crawler.ErrLinksFailed)
+}
+
+// abandonCheckpoint stops listing the backfill canceled on purpose as active, while the
+// backfill stopped with its instance is resumed by the next one.
+func (h *handlerImpl) abandonCheckpoint(ctx context.Context, cp *checkpoint) {
+	if !errors.Is(context.Cause(ctx), ErrJobCanceled) {
+		return
+	}
+
+	h.saveCheckpoint(ctx, cp, func(backfill *dto.Backfill) { backfill.Abandoned = true })
+	h.logger.Info().Msgf("handlers.abandonCheckpoint: abandoned, key=%s;", cp.backfill.Key)
+}
+
+// heartbeat refreshes the checkpoint until done is closed.
+func (h *handlerImpl) heartbeat(ctx context.Context, cp *checkpoint, done chan struct{}) {
+	ticker := time.NewTicker(backfillHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			h.saveCheckpoint(ctx, cp, func(*dto.Backfill) {})
+		}
+	}
+}
+
+// ResumeBackfills restarts the backfills abandoned by a stopped instance.
+func (h *handlerImpl) ResumeBackfills(ctx context.Context) error {
+	backfills, err := h.dataService.ListBackfills(ctx)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("handlers.ResumeBackfills: failed, reason: dataService list backfills failed")
+
+		return fmt.Errorf("handlers.ResumeBackfills: failed, reason: %w", err)
+	}
+
+	for _, backfill := range backfills {
+		if backfill.Abandoned || time.Since(backfill.UpdatedAt) < backfillStaleAfter {
+			continue
+		}
+
+		// instances starting together must not resume the same backfill twice
+		lock, err := h.dataService.ObtainLock(ctx, "backfill-lock:"+backfill.Key, backfillLockPeriod)
+		if err != nil {
+			continue
+		}
+
+		if err = validateRequest(backfill.Request); err != nil {
+			//nolint:nolintlint, errcheck
+			lock.Release(ctx)
+
+			return fmt.Errorf("handlers.ResumeBackfills: failed, reason: %w", err)
+		}
+
+		// the lock is held until the resumed backfill is done
+		job := h.submit(ctx, backfill.Request, lock)
+
+		h.logger.Info().Msgf("handlers.ResumeBackfills: resumed, key=%s; job=%s;", backfill.Key, job.ID)
+	}
+
+	return nil
+}
+
+// rangeDays returns the trading days between from and to, both included.
+func (h *handlerImpl) rangeDays(ctx context.Context, from, to string) ([]time.Time, error) {
+	start, err := parseRequestDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseRequestDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	cal, err := h.dataService.TradingCalendar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("handlers.rangeDays: failed, reason: %w", err)
+	}
+
+	return cal.TradingDaysBetween(start, end), nil
+}
+
+// rangeTypes returns the sources to download on a day of a range following the prev
+// day, zero on the first day. Monthly and yearly sources are only downloaded on the
+// first day of each month or year and undated ones on the first day.
+func rangeTypes(types []convert.Source, prev, day time.Time) []convert.Source {
+	res := make([]convert.Source, 0, len(types))
+
+	for _, source := range types {
+		def, err := convert.Lookup(source)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case prev.IsZero():
+		case def.DateFormat == "":
+			continue
+		case def.Period == convert.Monthly && prev.Month() == day.Month() && prev.Year() == day.Year():
+			continue
+		case def.Period == convert.Yearly && prev.Year() == day.Year():
+			continue
+		}
+
+		res = append(res, source)
+	}
+
+	return res
+}
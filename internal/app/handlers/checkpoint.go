@@ -0,0 +1,137 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+// checkpointDate returns the date the units of work are checkpointed on, the undated
+// sources on the day they are downloaded.
+func checkpointDate(date string) string {
+	if date == "" {
+		return time.Now().Format(helper.TwseDateFormat)
+	}
+
+	return date
+}
+
+// doneUnits returns the units of work of the source done on the date already, none if
+// the request refreshes them.
+func (h *handlerImpl) doneUnits(
+	ctx context.Context,
+	strategy convert.Source,
+	date string,
+	req *dto.StartCronjobRequest,
+) map[string]bool {
+	if req.Refresh {
+		return nil
+	}
+
+	done, err := h.dataService.Checkpoint(ctx, strategy, checkpointDate(date))
+	if err != nil {
+		// downloaded again rather than skipped
+		h.logger.Error().Err(err).Msgf("handlers.doneUnits: failed, type=%v; date=%s;", strategy, date)
+
+		return nil
+	}
+
+	return done
+}
+
+// pendingStocks returns the stocks of the per-stock source not done on the date yet,
+// the others are counted as skipped into the job.
+func (h *handlerImpl) pendingStocks(
+	ctx context.Context,
+	jobID, date string,
+	strategy convert.Source,
+	req *dto.StartCronjobRequest,
+) ([]string, error) {
+	stocks, err := h.dataService.ListStocks(ctx, req.StockFilter())
+	if err != nil {
+		return nil, fmt.Errorf("handlers.pendingStocks: failed, reason: %w", err)
+	}
+
+	done := h.doneUnits(ctx, strategy, date, req)
+	pending := make([]string, 0, len(stocks))
+
+	for _, stockID := range stocks {
+		if !done[stockID] {
+			pending = append(pending, stockID)
+		}
+	}
+
+	h.skip(jobID, strategy, len(stocks)-len(pending))
+
+	return pending, nil
+}
+
+// skip counts the units of work of the source checkpointed already into the job.
+func (h *handlerImpl) skip(jobID string, strategy convert.Source, count int) {
+	if count == 0 {
+		return
+	}
+
+	h.logger.Info().Msgf("handlers.skip: checkpointed, id=%s; type=%v; units=%d;", jobID, strategy, count)
+	h.jobs.update(jobID, func(job *dto.Job) { source(job, strategy).Skipped += count })
+}
+
+// checkpointUnits returns the units of work of the batch, the stocks of the per-stock
+// records or the link otherwise. Batches without any row are left out as the source may
+// publish them later, and so are the stake concentrations published partially so their
+// missing pages are downloaded again.
+func checkpointUnits(obj convert.InterceptData) []string {
+	if obj.Data == nil || len(*obj.Data) == 0 {
+		return nil
+	}
+
+	var (
+		stocks   []string
+		perStock bool
+	)
+
+	for _, val := range *obj.Data {
+		if st, ok := val.(*entity.StakeConcentration); ok {
+			perStock = true
+
+			if len(st.Missing) == 0 {
+				stocks = append(stocks, st.StockID)
+			}
+		}
+	}
+
+	if perStock {
+		return stocks
+	}
+
+	return []string{obj.URL}
+}
+
+// markCheckpoint records the units of work of the published batch.
+func (h *handlerImpl) markCheckpoint(ctx context.Context, obj convert.InterceptData, units []string) {
+	if len(units) == 0 {
+		return
+	}
+
+	if err := h.dataService.MarkCheckpoint(ctx, obj.Type, checkpointDate(obj.Date), units); err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.markCheckpoint: failed, type=%v; date=%s;", obj.Type, obj.Date)
+	}
+}
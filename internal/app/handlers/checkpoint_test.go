@@ -0,0 +1,66 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"testing"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointUnits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		obj  convert.InterceptData
+		want []string
+	}{
+		{
+			name: "batch without rows",
+			obj:  convert.InterceptData{Data: &[]any{}, URL: "https://www.twse.com.tw/daily"},
+		},
+		{
+			name: "batch of a link",
+			obj:  convert.InterceptData{Data: &[]any{&entity.DailyClose{}}, URL: "https://www.twse.com.tw/daily"},
+			want: []string{"https://www.twse.com.tw/daily"},
+		},
+		{
+			name: "stake concentrations published partially left out",
+			obj: convert.InterceptData{Data: &[]any{
+				&entity.StakeConcentration{StockID: "2330"},
+				&entity.StakeConcentration{StockID: "2317", Missing: []int32{2}},
+			}},
+			want: []string{"2330"},
+		},
+		{
+			name: "partial stake concentration only",
+			obj: convert.InterceptData{Data: &[]any{
+				&entity.StakeConcentration{StockID: "2317", Missing: []int32{2}},
+			}, URL: "https://fubon-ebrokerdj.fbs.com.tw/z/zc/zco/zco_2317_1.djhtm"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, checkpointUnits(tt.obj))
+		})
+	}
+}
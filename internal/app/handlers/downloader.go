@@ -15,63 +15,81 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/samwang0723/stock-crawler/internal/app/crawler"
 	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/graph"
-	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/app/services"
 	"github.com/samwang0723/stock-crawler/internal/helper"
 )
 
-const (
-	cronLockPeriod     = 5
-	cronTerminatedHour = 8
-)
-
 func (h *handlerImpl) ListeningDownloadRequest(ctx context.Context, requestChan chan *dto.StartCronjobRequest) {
 	h.dataService.ListeningDownloadRequest(ctx, requestChan)
 }
 
-func (h *handlerImpl) CronDownload(ctx context.Context, req *dto.StartCronjobRequest) error {
-	err := h.dataService.AddJob(ctx, req.Schedule, func() {
-		// since we will have multiple daemonSet in nodes, need to make sure same cronjob
-		// only running once at a time, here we use distributed lock through Redis.
-		if h.dataService.ObtainLock(ctx, cache.CronjobLock, cronLockPeriod*time.Minute) != nil {
-			h.batchingDownload(ctx, 0, req.Types)
-		}
-	})
+// Download runs the request as a job and blocks until it finishes.
+func (h *handlerImpl) Download(ctx context.Context, req *dto.StartCronjobRequest) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	job := h.jobs.create(req, cancel)
+	h.runJob(jobCtx, job.ID, req)
+}
+
+// batching download all the historical stock data
+func (h *handlerImpl) batchingDownload(ctx context.Context, jobID string, req *dto.StartCronjobRequest) error {
+	var (
+		links  []*graph.Link
+		queues []string
+	)
+
+	cal, err := h.dataService.TradingCalendar(ctx)
 	if err != nil {
-		return fmt.Errorf("handlers.CronDownload: failed, reason: %w", err)
+		return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", err)
 	}
 
-	return nil
-}
+	for _, strategy := range req.Types {
+		def, err := convert.Lookup(strategy)
+		if err != nil {
+			h.logger.Error().Err(err).Msg("handlers.batchingDownload: failed, reason: unknown source")
 
-func (h *handlerImpl) Download(ctx context.Context, req *dto.StartCronjobRequest) {
-	h.batchingDownload(ctx, int32(req.Rewind), req.Types)
-}
+			continue
+		}
 
-// batching download all the historical stock data
-func (h *handlerImpl) batchingDownload(ctx context.Context, rewind int32, types []convert.Source) {
-	var links []*graph.Link
+		date, err := queryDate(def, req, cal)
+		if err != nil {
+			return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", err)
+		}
 
-	interceptChan := make(chan convert.InterceptData)
+		// dated sources are not published when the market is closed
+		if def.DateFormat != "" && date == "" {
+			h.logger.Warn().Msgf("handlers.batchingDownload: skip non-trading day, type=%v;", strategy)
+
+			continue
+		}
 
-	for _, strategy := range types {
-		date := formatQueryDate(rewind, strategy)
+		// the stocks are shared with the other instances through the work queue
+		if def.PerStock && h.queue.Batch > 0 {
+			queue, err := h.shareStocks(ctx, jobID, date, strategy, req)
+			if err != nil {
+				return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", err)
+			}
 
-		// skip holiday if within redis cache
-		if h.dataService.IsHoliday(ctx, date) {
-			h.logger.Warn().Msg(fmt.Sprintf("handlers.batchingDownload: skip holiday, date=%s;", date))
+			if queue != "" {
+				queues = append(queues, queue)
+			}
 
 			continue
 		}
 
-		urls := h.generateURLs(ctx, date, strategy)
+		urls := h.generateURLs(ctx, jobID, date, strategy, def, req)
+
+		h.jobs.update(jobID, func(job *dto.Job) { source(job, strategy).Links += len(urls) })
 
 		for _, l := range urls {
 			links = append(links, &graph.Link{
@@ -82,89 +100,250 @@ func (h *handlerImpl) batchingDownload(ctx context.Context, rewind int32, types
 		}
 	}
 
+	// the work queues are still consumed if only some links were given up
+	_, crawlErr := h.crawl(ctx, jobID, &linkIterator{links: links})
+	if crawlErr != nil {
+		h.logger.Error().Err(crawlErr).Msg("handlers.batchingDownload: failed, reason: dataService crawl failed")
+
+		if !errors.Is(crawlErr, crawler.ErrLinksFailed) {
+			return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", crawlErr)
+		}
+	}
+
+	for _, queue := range queues {
+		if err = h.consumeWorkQueue(ctx, jobID, queue, true); err != nil {
+			return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", err)
+		}
+	}
+
+	if crawlErr != nil {
+		return fmt.Errorf("handlers.batchingDownload: failed, reason: %w", crawlErr)
+	}
+
+	return nil
+}
+
+// crawlResult of a crawl, the stocks whose aggregated records got published and
+// the links given up.
+type crawlResult struct {
+	published map[string]bool
+	abandoned map[string]bool
+}
+
+// crawl sends the links through the crawler for the job and returns its result.
+func (h *handlerImpl) crawl(ctx context.Context, jobID string, linkIt graph.LinkIterator) (*crawlResult, error) {
+	interceptChan := make(chan convert.InterceptData)
+	published := make(map[string]bool)
+	done := make(chan struct{})
+
 	go func() {
-		for {
-			select {
-			// since its hard to predict how many records already been processed,
-			// sync.WaitGroup hard to apply in this scenario, use timeout instead
-			case <-time.After(cronTerminatedHour * time.Hour):
-				h.logger.Warn().Msg("handlers.batchingDownload: failed, reason: timeout")
-
-				return
-			case <-ctx.Done():
-				h.logger.Warn().Msg("handlers.batchingDownload: failed, reason: context_cancel")
-
-				return
-			case obj, ok := <-interceptChan:
-				if ok {
-					h.processData(ctx, obj)
+		defer close(done)
+
+		// drained until crawl closes the channel, even if canceled, so the
+		// broadcast stage never blocks
+		for obj := range interceptChan {
+			// the records are recycled once published
+			stocks := stocksOf(obj)
+			units := checkpointUnits(obj)
+
+			if err := h.publish(ctx, jobID, obj); err == nil {
+				for _, stockID := range stocks {
+					published[stockID] = true
 				}
+
+				h.markCheckpoint(ctx, obj, units)
 			}
 		}
 	}()
 
-	_, err := h.dataService.Crawl(ctx, &linkIterator{links: links}, interceptChan)
+	progress := &jobProgress{jobs: h.jobs, id: jobID}
+
+	_, err := h.dataService.Crawl(crawler.ContextWithProgress(ctx, progress), linkIt, interceptChan)
+
+	// the job is only done once every intercepted batch is published
+	<-done
+
+	res := &crawlResult{published: published, abandoned: progress.abandoned}
+
 	if err != nil {
-		h.logger.Error().Err(err).Msg("handlers.batchingDownload: failed, reason: dataService crawl failed")
+		return res, fmt.Errorf("handlers.crawl: failed, reason: %w", err)
 	}
+
+	return res, nil
 }
 
-func (h *handlerImpl) generateURLs(ctx context.Context, date string, source convert.Source) []string {
-	var urls []string
+// ExpireAggregations publishes the stake concentrations timed out partially, apart from
+// the jobs whose crawls aggregated their pages.
+func (h *handlerImpl) ExpireAggregations(ctx context.Context) error {
+	interceptChan := make(chan convert.InterceptData)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for obj := range interceptChan {
+			// the records are recycled once published
+			units := checkpointUnits(obj)
+
+			if err := h.processData(ctx, obj); err == nil {
+				h.markCheckpoint(ctx, obj, units)
+			}
+		}
+	}()
+
+	err := h.dataService.ExpireAggregations(ctx, interceptChan)
+
+	<-done
 
-	var err error
+	if err != nil {
+		h.logger.Error().Err(err).Msg("handlers.ExpireAggregations: failed")
+
+		return fmt.Errorf("handlers.ExpireAggregations: failed, reason: %w", err)
+	}
 
-	//nolint:nolintlint, exhaustive
-	switch source {
-	case convert.StakeConcentration:
-		// align the date format to be 20220107, but remains the query date as 2022-01-07
-		unifiedDate := strings.ReplaceAll(date, "-", "")
-		urls, err = h.dataService.ListCrawlingConcentrationURLs(ctx, unifiedDate)
+	return nil
+}
+
+// stocksOf returns the stocks of the per-stock records.
+func stocksOf(obj convert.InterceptData) []string {
+	if obj.Data == nil {
+		return nil
+	}
+
+	var stocks []string
+
+	for _, val := range *obj.Data {
+		if res, ok := val.(*entity.StakeConcentration); ok {
+			stocks = append(stocks, res.StockID)
+		}
+	}
+
+	return stocks
+}
+
+// queryDate returns the date to download in the source date format, daily sources
+// rewind by trading days and return an empty date if the market is closed on the day.
+func queryDate(def *convert.Definition, req *dto.StartCronjobRequest, cal *helper.Calendar) (string, error) {
+	if def.DateFormat == "" {
+		return "", nil
+	}
+
+	var day time.Time
+
+	switch {
+	case req.Date != "":
+		date, err := parseRequestDate(req.Date)
 		if err != nil {
-			h.logger.Error().Err(err).Msg(
-				"handlers.generateURLs: failed, reason: dataService list crawling concentration urls failed",
-			)
+			return "", err
 		}
-	case convert.TwseStockList, convert.TpexStockList:
-		urls = append(urls, crawler.TypeLinkMapping[source.String()])
+
+		day = date
+	case def.Period != convert.Daily:
+		return def.QueryDate(int32(req.Rewind)), nil
 	default:
-		urls = append(urls, fmt.Sprintf(crawler.TypeLinkMapping[source.String()], date))
+		// rewind is a negative offset, rewind 0 is today
+		day = cal.TradingDaysBefore(time.Now(), -req.Rewind)
 	}
 
-	return urls
+	if def.Period == convert.Daily && !cal.IsTradingDay(day) {
+		return "", nil
+	}
+
+	return def.DateOf(day), nil
+}
+
+// parseRequestDate accepts a date as 20220801 or a month as 202208.
+func parseRequestDate(input string) (time.Time, error) {
+	loc, err := time.LoadLocation(helper.TimeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("handlers.parseRequestDate: failed, reason: %w", err)
+	}
+
+	for _, format := range []string{helper.TwseDateFormat, helper.MonthlyFormat} {
+		if date, err := time.ParseInLocation(format, input, loc); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("handlers.parseRequestDate: failed, date=%s; reason: %w", input, ErrJobRequestInvalid)
 }
 
-func formatQueryDate(rewind int32, t convert.Source) string {
-	var date string
+// generateURLs returns the links of the source not checkpointed on the date yet.
+func (h *handlerImpl) generateURLs(
+	ctx context.Context,
+	jobID, date string,
+	strategy convert.Source,
+	def *convert.Definition,
+	req *dto.StartCronjobRequest,
+) []string {
+	if !def.PerStock {
+		link := def.Link(date)
+		if h.doneUnits(ctx, strategy, date, req)[link] {
+			h.skip(jobID, strategy, 1)
 
-	//nolint:nolintlint, exhaustive
-	switch t {
-	case convert.TwseDailyClose, convert.TwseThreePrimary:
-		date = helper.GetDateFromOffset(rewind, helper.TwseDateFormat)
-	case convert.TpexDailyClose, convert.TpexThreePrimary:
-		date = helper.GetDateFromOffset(rewind, helper.TpexDateFormat)
-	case convert.StakeConcentration:
-		date = helper.GetDateFromOffset(rewind, helper.StakeConcentrationFormat)
+			return nil
+		}
+
+		return []string{link}
 	}
 
-	return date
+	stocks, err := h.pendingStocks(ctx, jobID, date, strategy, req)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("handlers.generateURLs: failed, reason: list pending stocks failed")
+
+		return nil
+	}
+
+	var urls []string
+
+	// https://stockchannelnew.sinotrade.com.tw/z/zc/zco/zco_6598_6.djhtm
+	for _, stockID := range stocks {
+		urls = append(urls, def.StockLinks(stockID)...)
+	}
+
+	return urls
 }
 
-func (h *handlerImpl) processData(ctx context.Context, obj convert.InterceptData) {
-	var err error
+// publish sends the intercepted batch and counts the outcome into the job.
+func (h *handlerImpl) publish(ctx context.Context, jobID string, obj convert.InterceptData) error {
+	err := h.processData(ctx, obj)
 
-	switch obj.Type {
-	case convert.TwseDailyClose, convert.TpexDailyClose:
-		err = h.dataService.DailyCloseThroughKafka(ctx, obj.Data)
-	case convert.TwseThreePrimary, convert.TpexThreePrimary:
-		err = h.dataService.ThreePrimaryThroughKafka(ctx, obj.Data)
-	case convert.TwseStockList, convert.TpexStockList:
-		err = h.dataService.StockThroughKafka(ctx, obj.Data)
-	case convert.StakeConcentration:
-		err = h.dataService.StakeConcentrationThroughKafka(ctx, obj.Data)
+	h.jobs.update(jobID, func(job *dto.Job) {
+		if err != nil {
+			source(job, obj.Type).Errors++
+		} else if obj.Data != nil {
+			source(job, obj.Type).Published += len(*obj.Data)
+		}
+	})
+
+	return err
+}
+
+func (h *handlerImpl) processData(ctx context.Context, obj convert.InterceptData) error {
+	def, err := convert.Lookup(obj.Type)
+	if err != nil {
+		return fmt.Errorf("handlers.processData: failed, reason: %w", err)
+	}
+
+	switch {
+	case def.Calendar:
+		err = h.dataService.UpdateCalendar(ctx, obj.Data)
+	case def.Universe:
+		// the universe is kept current even if the stocks are not published, while
+		// a list rejected as truncated is not published at all
+		err = h.dataService.UpdateUniverse(ctx, obj.Type, obj.Data)
+		if !errors.Is(err, services.ErrUniverseShrunk) {
+			err = errors.Join(err, h.dataService.SendThroughKafka(ctx, obj.Type, obj.Data))
+		}
+	default:
+		err = h.dataService.SendThroughKafka(ctx, obj.Type, obj.Data)
 	}
 
 	if err != nil {
-		h.logger.Error().Err(err).Msg(fmt.Sprintf("handlers.processData: failed, reason: unknown_type=%v;", obj.Type))
+		h.logger.Error().Err(err).Msg(fmt.Sprintf("handlers.processData: failed, type=%v;", obj.Type))
+
+		return fmt.Errorf("handlers.processData: failed, reason: %w", err)
 	}
+
+	return nil
 }
@@ -0,0 +1,96 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// universeService fails the universe updates with err and counts the published lists.
+type universeService struct {
+	services.IService
+	err       error
+	published int
+}
+
+func (s *universeService) UpdateUniverse(_ context.Context, _ convert.Source, _ *[]any) error {
+	return s.err
+}
+
+func (s *universeService) SendThroughKafka(_ context.Context, _ convert.Source, _ *[]any) error {
+	s.published++
+
+	return nil
+}
+
+func TestProcessStockList(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "handlers").Logger()
+	errRedis := errors.New("redis down")
+
+	tests := []struct {
+		name          string
+		err           error
+		wantPublished int
+	}{
+		{
+			name:          "universe updated",
+			wantPublished: 1,
+		},
+		{
+			name:          "universe update failed",
+			err:           errRedis,
+			wantPublished: 1,
+		},
+		{
+			name: "stock list rejected as truncated",
+			err:  fmt.Errorf("service.updateUniverse: failed, reason: %w", services.ErrUniverseShrunk),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := &universeService{err: tt.err}
+			h, ok := New(svc, &logger).(*handlerImpl)
+			assert.True(t, ok)
+
+			err := h.processData(context.Background(), convert.InterceptData{
+				Data: &[]any{&entity.Stock{StockID: "2330"}},
+				Type: convert.TwseStockList,
+			})
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantPublished, svc.published)
+		})
+	}
+}
@@ -15,27 +15,66 @@ package handlers
 
 import (
 	"context"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/samwang0723/stock-crawler/internal/app/dto"
 	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
 )
 
 type IHandler interface {
 	CronDownload(ctx context.Context, req *dto.StartCronjobRequest) error
 	Download(ctx context.Context, req *dto.StartCronjobRequest)
 	ListeningDownloadRequest(ctx context.Context, requestChan chan *dto.StartCronjobRequest)
+	Replay(ctx context.Context, req *dto.StartCronjobRequest) error
+	SubmitDownload(ctx context.Context, req *dto.StartCronjobRequest) (*dto.Job, error)
+	ListJobs(ctx context.Context) []*dto.Job
+	GetJob(ctx context.Context, id string) (*dto.Job, error)
+	CancelJob(ctx context.Context, id string) error
+	ResumeBackfills(ctx context.Context) error
+	ManageSchedule(ctx context.Context, req *dto.StartCronjobRequest) (*dto.Schedule, error)
+	ListSchedules(ctx context.Context) []*dto.Schedule
+	SyncSchedules(ctx context.Context) error
+	JoinWorkQueues(ctx context.Context) error
+	ExpireAggregations(ctx context.Context) error
+	ListUniverse(ctx context.Context, filter *dto.StockFilter) ([]*dto.ListedStock, error)
+	Circuits(ctx context.Context) []circuit.Snapshot
 }
 
 type handlerImpl struct {
 	logger      *zerolog.Logger
 	dataService services.IService
+	jobs        *jobRegistry
+	schedules   *scheduleRegistry
+	retry       PublishRetry
+	election    *cache.Election
+	queue       WorkQueue
+	// consuming keeps the work queues of other instances consumed by this instance
+	consuming sync.Map
 }
 
-func New(dataService services.IService, logger *zerolog.Logger) IHandler {
+// Option configures the handler.
+type Option func(h *handlerImpl)
+
+// WithElection skips the scheduled downloads unless the instance leads.
+func WithElection(election *cache.Election) Option {
+	return func(h *handlerImpl) {
+		h.election = election
+	}
+}
+
+func New(dataService services.IService, logger *zerolog.Logger, opts ...Option) IHandler {
 	res := &handlerImpl{
 		logger:      logger,
 		dataService: dataService,
+		jobs:        newJobRegistry(),
+		schedules:   newScheduleRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(res)
 	}
 
 	return res
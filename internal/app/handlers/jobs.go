@@ -0,0 +1,482 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+const (
+	maxRecentJobs = 100
+	jobIDLength   = 4
+	// running jobs are persisted periodically so other pods can follow the progress
+	jobFlushInterval = 5 * time.Second
+)
+
+var (
+	ErrJobNotFound       = errors.New("job not found")
+	ErrJobFinished       = errors.New("job already finished")
+	ErrJobRemote         = errors.New("job running on another instance")
+	ErrJobRequestInvalid = errors.New("invalid job request")
+	// ErrJobCanceled is the cause of the jobs canceled on purpose, unlike the jobs
+	// stopped with their instance.
+	ErrJobCanceled = errors.New("job canceled")
+)
+
+type jobEntry struct {
+	job    *dto.Job
+	cancel context.CancelCauseFunc
+}
+
+// jobRegistry keeps the running jobs and the most recent finished ones.
+type jobRegistry struct {
+	entries map[string]*jobEntry
+	mu      sync.RWMutex
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{entries: make(map[string]*jobEntry)}
+}
+
+func newJobID() string {
+	suffix := make([]byte, jobIDLength)
+	//nolint:nolintlint, errcheck
+	rand.Read(suffix)
+
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102150405"), hex.EncodeToString(suffix))
+}
+
+func (r *jobRegistry) create(req *dto.StartCronjobRequest, cancel context.CancelCauseFunc) *dto.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := &dto.Job{
+		ID:        newJobID(),
+		Status:    dto.JobQueued,
+		Request:   req,
+		Sources:   make(map[string]*dto.SourceProgress),
+		CreatedAt: time.Now(),
+	}
+	r.entries[job.ID] = &jobEntry{job: job, cancel: cancel}
+	r.prune()
+
+	return r.copy(job)
+}
+
+// update applies fn onto the job under lock.
+func (r *jobRegistry) update(id string, fn func(job *dto.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.entries[id]; ok {
+		fn(entry.job)
+	}
+}
+
+func (r *jobRegistry) get(id string) (*dto.Job, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("jobRegistry.get: failed, id=%s; reason: %w", id, ErrJobNotFound)
+	}
+
+	return r.copy(entry.job), nil
+}
+
+// list returns the jobs from the newest.
+func (r *jobRegistry) list() []*dto.Job {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make([]*dto.Job, 0, len(r.entries))
+	for _, entry := range r.entries {
+		jobs = append(jobs, r.copy(entry.job))
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+
+	return jobs
+}
+
+func (r *jobRegistry) cancel(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[id]
+	if !ok {
+		return fmt.Errorf("jobRegistry.cancel: failed, id=%s; reason: %w", id, ErrJobNotFound)
+	}
+
+	if entry.job.Finished() {
+		return fmt.Errorf("jobRegistry.cancel: failed, id=%s; reason: %w", id, ErrJobFinished)
+	}
+
+	entry.cancel(ErrJobCanceled)
+
+	return nil
+}
+
+// prune drops the oldest finished jobs beyond the retention.
+func (r *jobRegistry) prune() {
+	finished := []*dto.Job{}
+
+	for _, entry := range r.entries {
+		if entry.job.Finished() {
+			finished = append(finished, entry.job)
+		}
+	}
+
+	if len(finished) <= maxRecentJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CreatedAt.Before(finished[j].CreatedAt) })
+
+	for _, job := range finished[:len(finished)-maxRecentJobs] {
+		delete(r.entries, job.ID)
+	}
+}
+
+func (r *jobRegistry) copy(job *dto.Job) *dto.Job {
+	res := *job
+
+	res.Sources = make(map[string]*dto.SourceProgress, len(job.Sources))
+	for name, progress := range job.Sources {
+		p := *progress
+		res.Sources[name] = &p
+	}
+
+	return &res
+}
+
+// source returns the progress of the source, creating it if absent. Must be called under lock.
+func source(job *dto.Job, source convert.Source) *dto.SourceProgress {
+	progress, ok := job.Sources[source.String()]
+	if !ok {
+		progress = &dto.SourceProgress{}
+		job.Sources[source.String()] = progress
+	}
+
+	return progress
+}
+
+// jobProgress counts the crawling progress of a job by source, and records the links
+// given up by the crawl.
+type jobProgress struct {
+	jobs      *jobRegistry
+	abandoned map[string]bool
+	id        string
+	mu        sync.Mutex
+}
+
+func (p *jobProgress) Fetched(s convert.Source) {
+	p.jobs.update(p.id, func(job *dto.Job) { source(job, s).Fetched++ })
+}
+
+func (p *jobProgress) Parsed(s convert.Source, rows int) {
+	p.jobs.update(p.id, func(job *dto.Job) { source(job, s).Parsed += rows })
+}
+
+func (p *jobProgress) Failed(s convert.Source, _ error) {
+	p.jobs.update(p.id, func(job *dto.Job) { source(job, s).Errors++ })
+}
+
+func (p *jobProgress) Abandoned(s convert.Source, url string, _ error) {
+	p.jobs.update(p.id, func(job *dto.Job) { source(job, s).Abandoned++ })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.abandoned == nil {
+		p.abandoned = make(map[string]bool)
+	}
+
+	p.abandoned[url] = true
+}
+
+// outcome decides the final status of a finished crawl from its progress, the failed
+// attempts recovered by a retry are not taken as failures.
+func outcome(job *dto.Job) dto.JobStatus {
+	var links, fetched, abandoned int
+
+	for _, progress := range job.Sources {
+		links += progress.Links
+		fetched += progress.Fetched
+		abandoned += progress.Abandoned
+	}
+
+	switch {
+	case links > 0 && fetched == 0:
+		return dto.JobFailed
+	case fetched < links || abandoned > 0:
+		return dto.JobPartiallyFailed
+	}
+
+	return dto.JobSucceeded
+}
+
+// saveJob persists the current state of the job, failures are only logged
+// as the job itself is not affected.
+func (h *handlerImpl) saveJob(ctx context.Context, id string) {
+	job, err := h.jobs.get(id)
+	if err != nil {
+		return
+	}
+
+	if err := h.dataService.SaveJob(context.WithoutCancel(ctx), job); err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.saveJob: failed, id=%s;", id)
+	}
+}
+
+// flushJob persists the running job periodically until done is closed.
+func (h *handlerImpl) flushJob(ctx context.Context, id string, done chan struct{}) {
+	ticker := time.NewTicker(jobFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			h.saveJob(ctx, id)
+		}
+	}
+}
+
+// SubmitDownload starts the download in background and returns the queued job.
+func (h *handlerImpl) SubmitDownload(ctx context.Context, req *dto.StartCronjobRequest) (*dto.Job, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, fmt.Errorf("handlers.SubmitDownload: failed, reason: %w", err)
+	}
+
+	return h.submit(ctx, req, nil), nil
+}
+
+// submit starts the job in background. The job holds the lock, if any, until done,
+// and stops once the lock is lost.
+func (h *handlerImpl) submit(ctx context.Context, req *dto.StartCronjobRequest, lock *cache.Lock) *dto.Job {
+	// the job outlives the submitting request until finished or canceled
+	jobCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	job := h.jobs.create(req, cancel)
+	h.saveJob(ctx, job.ID)
+
+	go func() {
+		defer cancel(nil)
+
+		if lock == nil {
+			h.runJob(jobCtx, job.ID, req)
+
+			return
+		}
+
+		lockCtx, stop := lock.Context(jobCtx)
+		defer stop()
+
+		h.runJob(lockCtx, job.ID, req)
+
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			h.logger.Error().Err(err).Msgf("handlers.submit: failed, id=%s;", job.ID)
+		}
+	}()
+
+	return job
+}
+
+// ListJobs returns the local jobs merged with the persisted ones of every instance.
+func (h *handlerImpl) ListJobs(ctx context.Context) []*dto.Job {
+	jobs := h.jobs.list()
+
+	persisted, err := h.dataService.ListJobs(ctx, maxRecentJobs)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("handlers.ListJobs: failed, reason: dataService list jobs failed")
+
+		return jobs
+	}
+
+	local := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		local[job.ID] = true
+	}
+
+	for _, job := range persisted {
+		if !local[job.ID] {
+			jobs = append(jobs, job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+
+	return jobs
+}
+
+// GetJob returns the local job, or the persisted record if run by another instance.
+func (h *handlerImpl) GetJob(ctx context.Context, id string) (*dto.Job, error) {
+	job, err := h.jobs.get(id)
+	if err == nil {
+		return job, nil
+	}
+
+	job, err = h.dataService.GetJob(ctx, id)
+	if errors.Is(err, cache.ErrCacheMiss) {
+		return nil, fmt.Errorf("handlers.GetJob: failed, id=%s; reason: %w", id, ErrJobNotFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("handlers.GetJob: failed, reason: %w", err)
+	}
+
+	return job, nil
+}
+
+// CancelJob cancels a local running job, jobs of other instances can not be canceled.
+func (h *handlerImpl) CancelJob(ctx context.Context, id string) error {
+	err := h.jobs.cancel(id)
+	if !errors.Is(err, ErrJobNotFound) {
+		if err != nil {
+			return fmt.Errorf("handlers.CancelJob: failed, reason: %w", err)
+		}
+
+		return nil
+	}
+
+	job, err := h.GetJob(ctx, id)
+	if err != nil {
+		return fmt.Errorf("handlers.CancelJob: failed, reason: %w", err)
+	}
+
+	if job.Finished() {
+		return fmt.Errorf("handlers.CancelJob: failed, id=%s; reason: %w", id, ErrJobFinished)
+	}
+
+	return fmt.Errorf("handlers.CancelJob: failed, id=%s; reason: %w", id, ErrJobRemote)
+}
+
+// runJob downloads the request and records the outcome into the job.
+func (h *handlerImpl) runJob(ctx context.Context, id string, req *dto.StartCronjobRequest) {
+	h.logger.Info().Msgf("handlers.runJob: started, id=%s; types=%v;", id, req.Types)
+
+	h.track(ctx, id, func() error {
+		if req.From != "" {
+			return h.backfill(ctx, id, req)
+		}
+
+		return h.downloadUntilPublished(ctx, id, req)
+	})
+}
+
+// track runs the job, persisting its progress, and records the outcome.
+func (h *handlerImpl) track(ctx context.Context, id string, run func() error) {
+	h.jobs.update(id, func(job *dto.Job) {
+		now := time.Now()
+		job.Status = dto.JobRunning
+		job.StartedAt = &now
+	})
+
+	h.saveJob(ctx, id)
+
+	done := make(chan struct{})
+	go h.flushJob(ctx, id, done)
+
+	err := run()
+
+	close(done)
+
+	h.jobs.update(id, func(job *dto.Job) {
+		now := time.Now()
+		job.FinishedAt = &now
+
+		if err != nil {
+			job.Error = err.Error()
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			job.Status = dto.JobCanceled
+		case err != nil && !errors.Is(err, crawler.ErrLinksFailed):
+			job.Status = dto.JobFailed
+		case err != nil && outcome(job) != dto.JobFailed:
+			// the other links went through
+			job.Status = dto.JobPartiallyFailed
+		default:
+			job.Status = outcome(job)
+		}
+
+		for name, progress := range job.Sources {
+			h.logger.Info().Msgf(
+				"handlers.track: finished, id=%s; type=%s; links=%d; fetched=%d; parsed=%d; published=%d; errors=%d; abandoned=%d;",
+				id, name, progress.Links, progress.Fetched, progress.Parsed, progress.Published, progress.Errors,
+				progress.Abandoned,
+			)
+		}
+
+		h.logger.Info().Msgf("handlers.track: finished, id=%s; status=%s;", id, job.Status)
+	})
+
+	h.saveJob(ctx, id)
+}
+
+func validateRequest(req *dto.StartCronjobRequest) error {
+	if req == nil || len(req.Types) == 0 {
+		return fmt.Errorf("%w: types are required", ErrJobRequestInvalid)
+	}
+
+	for _, source := range req.Types {
+		if _, err := convert.Lookup(source); err != nil {
+			return fmt.Errorf("%w: %w", ErrJobRequestInvalid, err)
+		}
+	}
+
+	if req.Date != "" {
+		if _, err := parseRequestDate(req.Date); err != nil {
+			return fmt.Errorf("%w: %w", ErrJobRequestInvalid, err)
+		}
+	}
+
+	if req.From == "" && req.To == "" {
+		return nil
+	}
+
+	if req.Date != "" {
+		return fmt.Errorf("%w: date and range are exclusive", ErrJobRequestInvalid)
+	}
+
+	from, err := time.Parse(helper.TwseDateFormat, req.From)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrJobRequestInvalid, err)
+	}
+
+	to, err := time.Parse(helper.TwseDateFormat, req.To)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrJobRequestInvalid, err)
+	}
+
+	if to.Before(from) {
+		return fmt.Errorf("%w: range ends before it starts", ErrJobRequestInvalid)
+	}
+
+	return nil
+}
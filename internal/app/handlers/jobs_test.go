@@ -0,0 +1,68 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"testing"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcome(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		progress *dto.SourceProgress
+		name     string
+		want     dto.JobStatus
+	}{
+		{
+			name:     "every link fetched",
+			progress: &dto.SourceProgress{Links: 2, Fetched: 2},
+			want:     dto.JobSucceeded,
+		},
+		{
+			name:     "failed attempts recovered by a retry",
+			progress: &dto.SourceProgress{Links: 2, Fetched: 2, Errors: 3},
+			want:     dto.JobSucceeded,
+		},
+		{
+			name:     "link given up",
+			progress: &dto.SourceProgress{Links: 2, Fetched: 1, Errors: 3, Abandoned: 1},
+			want:     dto.JobPartiallyFailed,
+		},
+		{
+			name:     "page given up after being fetched",
+			progress: &dto.SourceProgress{Links: 2, Fetched: 2, Errors: 1, Abandoned: 1},
+			want:     dto.JobPartiallyFailed,
+		},
+		{
+			name:     "every link given up",
+			progress: &dto.SourceProgress{Links: 2, Errors: 6, Abandoned: 2},
+			want:     dto.JobFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := &dto.Job{Sources: map[string]*dto.SourceProgress{"TwseDailyClose": tt.progress}}
+			assert.Equal(t, tt.want, outcome(job))
+		})
+	}
+}
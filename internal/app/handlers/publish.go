@@ -0,0 +1,209 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+const (
+	defaultPublishBackoff    = 5 * time.Minute
+	defaultPublishMaxBackoff = 30 * time.Minute
+)
+
+var ErrNotPublished = errors.New("not published by the deadline")
+
+// PublishRetry downloads again the daily sources found empty on a trading day,
+// until the deadline of the source.
+type PublishRetry struct {
+	// Deadlines are the time of the day by which the sources are published,
+	// sources without deadline are not retried.
+	Deadlines map[convert.Source]time.Duration
+	// Backoff before the first retry, doubled up to MaxBackoff on every retry.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func WithPublishRetry(retry PublishRetry) Option {
+	return func(h *handlerImpl) {
+		if retry.Backoff <= 0 {
+			retry.Backoff = defaultPublishBackoff
+		}
+
+		if retry.MaxBackoff < retry.Backoff {
+			retry.MaxBackoff = max(retry.Backoff, defaultPublishMaxBackoff)
+		}
+
+		h.retry = retry
+	}
+}
+
+// downloadUntilPublished downloads the request, then the daily sources with a deadline fetched
+// without any row are downloaded again for the same date. As the market is open on the day, the
+// data is not published yet, unlike an empty day in the past which is taken as no trading.
+func (h *handlerImpl) downloadUntilPublished(ctx context.Context, id string, req *dto.StartCronjobRequest) error {
+	// the sources are still awaited if only some links were given up
+	linksErr := h.batchingDownload(ctx, id, req)
+	if linksErr != nil && !errors.Is(linksErr, crawler.ErrLinksFailed) {
+		return linksErr
+	}
+
+	day, pending, err := h.publishPending(ctx, req)
+	if err != nil {
+		return err
+	} else if len(pending) == 0 {
+		return linksErr
+	}
+
+	retry := &dto.StartCronjobRequest{
+		Date:          day.Format(helper.TwseDateFormat),
+		StockIDs:      req.StockIDs,
+		Markets:       req.Markets,
+		Categories:    req.Categories,
+		SecurityTypes: req.SecurityTypes,
+		Refresh:       req.Refresh,
+	}
+	backoff := h.retry.Backoff
+
+	var missed []convert.Source
+
+	for {
+		var waiting []convert.Source
+
+		now := time.Now()
+		wait := backoff
+
+		for _, s := range pending {
+			if !h.unpublished(id, s) {
+				continue
+			}
+
+			deadline := day.Add(h.retry.Deadlines[s])
+			if !now.Before(deadline) {
+				missed = append(missed, s)
+
+				continue
+			}
+
+			waiting = append(waiting, s)
+			wait = min(wait, deadline.Sub(now))
+		}
+
+		if len(waiting) == 0 {
+			break
+		}
+
+		h.logger.Warn().Msgf(
+			"handlers.downloadUntilPublished: not published, id=%s; types=%v; retry_in=%s;", id, waiting, wait,
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("handlers.downloadUntilPublished: failed, reason: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+
+		h.jobs.update(id, func(job *dto.Job) {
+			for _, s := range waiting {
+				source(job, s).Retries++
+			}
+		})
+
+		retry.Types = waiting
+		if err = h.batchingDownload(ctx, id, retry); err != nil && !errors.Is(err, crawler.ErrLinksFailed) {
+			return err
+		} else if err != nil {
+			linksErr = err
+		}
+
+		pending = waiting
+		backoff = min(backoff*2, h.retry.MaxBackoff)
+	}
+
+	if len(missed) == 0 {
+		return linksErr
+	}
+
+	h.jobs.update(id, func(job *dto.Job) {
+		for _, s := range missed {
+			source(job, s).Unpublished = true
+		}
+	})
+
+	return fmt.Errorf("handlers.downloadUntilPublished: failed, types=%v; reason: %w", missed, ErrNotPublished)
+}
+
+// publishPending returns the download day at midnight and its retried sources, only the sources
+// downloaded for today on a trading day are awaited.
+func (h *handlerImpl) publishPending(
+	ctx context.Context,
+	req *dto.StartCronjobRequest,
+) (time.Time, []convert.Source, error) {
+	if len(h.retry.Deadlines) == 0 {
+		return time.Time{}, nil, nil
+	}
+
+	cal, err := h.dataService.TradingCalendar(ctx)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("handlers.publishPending: failed, reason: %w", err)
+	}
+
+	today := cal.Day(time.Now())
+	day := cal.TradingDaysBefore(today, -req.Rewind)
+
+	if req.Date != "" {
+		if day, err = parseRequestDate(req.Date); err != nil {
+			return time.Time{}, nil, fmt.Errorf("handlers.publishPending: failed, reason: %w", err)
+		}
+	}
+
+	if !day.Equal(today) || !cal.IsTradingDay(day) {
+		return time.Time{}, nil, nil
+	}
+
+	var pending []convert.Source
+
+	for _, s := range req.Types {
+		def, err := convert.Lookup(s)
+		if err != nil || def.DateFormat == "" || def.Period != convert.Daily {
+			continue
+		}
+
+		if _, ok := h.retry.Deadlines[s]; ok {
+			pending = append(pending, s)
+		}
+	}
+
+	return day, pending, nil
+}
+
+// unpublished tells whether the source was fetched without parsing any row.
+func (h *handlerImpl) unpublished(id string, s convert.Source) bool {
+	job, err := h.jobs.get(id)
+	if err != nil {
+		return false
+	}
+
+	progress, ok := job.Sources[s.String()]
+
+	return ok && progress.Fetched > 0 && progress.Parsed == 0
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+)
+
+// Replay re-parses the archived responses of the requested sources and date,
+// the data service must be configured with a replaying crawler.
+func (h *handlerImpl) Replay(ctx context.Context, req *dto.StartCronjobRequest) error {
+	var links []*graph.Link
+
+	cal, err := h.dataService.TradingCalendar(ctx)
+	if err != nil {
+		return fmt.Errorf("handlers.Replay: failed, reason: %w", err)
+	}
+
+	for _, strategy := range req.Types {
+		def, err := convert.Lookup(strategy)
+		if err != nil {
+			return fmt.Errorf("handlers.Replay: failed, reason: %w", err)
+		}
+
+		date, err := queryDate(def, req, cal)
+		if err != nil {
+			return fmt.Errorf("handlers.Replay: failed, reason: %w", err)
+		}
+
+		urls, err := h.dataService.ListArchivedURLs(ctx, strategy, date)
+		if err != nil {
+			return fmt.Errorf("handlers.Replay: failed, reason: %w", err)
+		}
+
+		h.logger.Info().Msgf("handlers.Replay: started, type=%v; date=%s; links=%d;", strategy, date, len(urls))
+
+		for _, l := range urls {
+			links = append(links, &graph.Link{
+				URL:      l,
+				Date:     date,
+				Strategy: strategy,
+			})
+		}
+	}
+
+	interceptChan := make(chan convert.InterceptData)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for obj := range interceptChan {
+			//nolint:nolintlint, errcheck
+			h.processData(ctx, obj)
+		}
+	}()
+
+	_, err = h.dataService.Crawl(ctx, &linkIterator{links: links}, interceptChan)
+
+	// crawl closes the intercept channel once drained, wait for the last batch to be published
+	<-done
+
+	if err != nil {
+		return fmt.Errorf("handlers.Replay: failed, reason: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,375 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"crypto/sha1" //nolint:nolintlint, gosec
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+)
+
+const (
+	cronLockPeriod       = 5 * time.Minute
+	staticSchedulePrefix = "config-"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleStatic   = errors.New("schedule declared in the config")
+)
+
+type scheduleEntry struct {
+	schedule *dto.Schedule
+	// registered is the request run by the cronjob
+	registered *dto.StartCronjobRequest
+	// entryID is the registered cronjob, zero while paused
+	entryID cron.EntryID
+}
+
+// scheduleRegistry keeps the schedules registered on this instance.
+type scheduleRegistry struct {
+	entries map[string]*scheduleEntry
+	mu      sync.Mutex
+}
+
+func newScheduleRegistry() *scheduleRegistry {
+	return &scheduleRegistry{entries: make(map[string]*scheduleEntry)}
+}
+
+// staticScheduleID identifies a config schedule by its content, so every instance
+// gives the same id to the same schedule.
+func staticScheduleID(req *dto.StartCronjobRequest) string {
+	//nolint:nolintlint, errcheck, errchkjson
+	data, _ := jsoni.Marshal(&dto.StartCronjobRequest{
+		Schedule: req.Schedule,
+		Types:    req.Types,
+		Rewind:   req.Rewind,
+	})
+	sum := sha1.Sum(data) //nolint:nolintlint, gosec
+
+	return staticSchedulePrefix + hex.EncodeToString(sum[:jobIDLength])
+}
+
+func (h *handlerImpl) CronDownload(ctx context.Context, req *dto.StartCronjobRequest) error {
+	schedule := &dto.Schedule{
+		ID:        staticScheduleID(req),
+		Request:   req,
+		Static:    true,
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.register(ctx, schedule); err != nil {
+		return fmt.Errorf("handlers.CronDownload: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// register adds the cronjob of the schedule, paused schedules are only recorded. The
+// cronjob is replaced if the spec or the request of the schedule changed.
+func (h *handlerImpl) register(ctx context.Context, schedule *dto.Schedule) error {
+	h.schedules.mu.Lock()
+	defer h.schedules.mu.Unlock()
+
+	entry, ok := h.schedules.entries[schedule.ID]
+	if !ok {
+		entry = &scheduleEntry{}
+		h.schedules.entries[schedule.ID] = entry
+	}
+
+	entry.schedule = schedule
+
+	if schedule.Paused {
+		h.unschedule(entry)
+
+		return nil
+	}
+
+	req := schedule.Request
+
+	if entry.entryID != 0 && sameRun(entry.registered, req) {
+		return nil
+	}
+
+	h.unschedule(entry)
+
+	id, err := h.dataService.AddJob(ctx, req.Schedule, func() { h.runSchedule(ctx, schedule.ID, req) })
+	if err != nil {
+		delete(h.schedules.entries, schedule.ID)
+
+		return fmt.Errorf("handlers.register: failed, id=%s; reason: %w", schedule.ID, err)
+	}
+
+	entry.entryID = id
+	entry.registered = req
+
+	return nil
+}
+
+// sameRun tells whether both requests are scheduled and downloaded alike.
+func sameRun(a, b *dto.StartCronjobRequest) bool {
+	return a.Schedule == b.Schedule && a.Rewind == b.Rewind && slices.Equal(a.Types, b.Types)
+}
+
+// runSchedule downloads the scheduled request on a single instance, since we will have
+// multiple daemonSet in nodes. The cronjobs only run on the leader, and the distributed
+// lock of the schedule, refreshed while the download runs, covers the leader changes.
+func (h *handlerImpl) runSchedule(ctx context.Context, id string, req *dto.StartCronjobRequest) {
+	start := time.Now()
+
+	// a former leader, paused past its lease, must not start a download along the new one,
+	// the lock and the work queues of the download are refused once another term started
+	if h.election != nil {
+		fenced, err := h.election.Fence(ctx)
+		if err != nil {
+			h.logger.Warn().Err(err).Msgf("handlers.runSchedule: skipped, id=%s;", id)
+
+			return
+		}
+
+		ctx = fenced
+	}
+
+	lock, err := h.dataService.ObtainLock(ctx, cache.CronjobLock+":"+id, cronLockPeriod)
+	if errors.Is(err, cache.ErrLockNotObtained) {
+		h.logger.Info().Msgf("handlers.runSchedule: skipped, reason: running on another instance; id=%s;", id)
+
+		return
+	} else if errors.Is(err, cache.ErrNotLeader) {
+		h.logger.Warn().Err(err).Msgf("handlers.runSchedule: skipped, id=%s;", id)
+
+		return
+	} else if err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.runSchedule: failed, id=%s;", id)
+
+		return
+	}
+
+	// stopped once the lock is lost, since another instance may download it then
+	lockCtx, stop := lock.Context(ctx)
+	h.Download(lockCtx, &dto.StartCronjobRequest{Types: req.Types, Rewind: req.Rewind})
+	stop()
+
+	if errors.Is(context.Cause(lockCtx), cache.ErrLockLost) {
+		h.logger.Warn().Msgf("handlers.runSchedule: stopped, reason: lock lost; id=%s;", id)
+
+		return
+	}
+
+	// held for the lock period at least, so instances firing the same tick
+	// a bit later do not download it again
+	if err = lock.ReleaseAfter(context.WithoutCancel(ctx), cronLockPeriod-time.Since(start)); err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.runSchedule: failed, id=%s;", id)
+	}
+}
+
+// unregister removes the schedule and its cronjob from this instance.
+func (h *handlerImpl) unregister(id string) {
+	h.schedules.mu.Lock()
+	defer h.schedules.mu.Unlock()
+
+	if entry, ok := h.schedules.entries[id]; ok {
+		h.unschedule(entry)
+		delete(h.schedules.entries, id)
+	}
+}
+
+func (h *handlerImpl) unschedule(entry *scheduleEntry) {
+	if entry.entryID != 0 {
+		h.dataService.RemoveJob(entry.entryID)
+		entry.entryID = 0
+		entry.registered = nil
+	}
+}
+
+// ManageSchedule applies the schedule action of the request, the change is persisted
+// in Redis and picked up by the other instances on their next sync.
+func (h *handlerImpl) ManageSchedule(ctx context.Context, req *dto.StartCronjobRequest) (*dto.Schedule, error) {
+	if req.Action == dto.ActionAddSchedule {
+		return h.addSchedule(ctx, req)
+	}
+
+	schedule, err := h.findSchedule(ctx, req.ScheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("handlers.ManageSchedule: failed, reason: %w", err)
+	}
+
+	switch req.Action {
+	case dto.ActionRemoveSchedule:
+		if err = h.dataService.DeleteSchedule(ctx, schedule.ID); err != nil {
+			return nil, fmt.Errorf("handlers.ManageSchedule: failed, reason: %w", err)
+		}
+
+		h.unregister(schedule.ID)
+
+		return schedule, nil
+	case dto.ActionPauseSchedule:
+		schedule.Paused = true
+	case dto.ActionResumeSchedule:
+		schedule.Paused = false
+	default:
+		return nil, fmt.Errorf("handlers.ManageSchedule: failed, action=%s; reason: %w", req.Action, ErrJobRequestInvalid)
+	}
+
+	if err = h.dataService.SaveSchedule(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("handlers.ManageSchedule: failed, reason: %w", err)
+	}
+
+	if err = h.register(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("handlers.ManageSchedule: failed, reason: %w", err)
+	}
+
+	h.logger.Info().Msgf("handlers.ManageSchedule: %s, id=%s;", req.Action, schedule.ID)
+
+	return schedule, nil
+}
+
+func (h *handlerImpl) addSchedule(ctx context.Context, req *dto.StartCronjobRequest) (*dto.Schedule, error) {
+	if err := validateRequest(req); err != nil {
+		return nil, fmt.Errorf("handlers.addSchedule: failed, reason: %w", err)
+	}
+
+	if _, err := cron.ParseStandard(req.Schedule); err != nil {
+		return nil, fmt.Errorf("handlers.addSchedule: failed, reason: %w: %w", ErrJobRequestInvalid, err)
+	}
+
+	id := req.ScheduleID
+	if id == "" {
+		id = newJobID()
+	} else if strings.HasPrefix(id, staticSchedulePrefix) {
+		return nil, fmt.Errorf("handlers.addSchedule: failed, id=%s; reason: %w", id, ErrScheduleStatic)
+	}
+
+	schedule := &dto.Schedule{
+		ID: id,
+		Request: &dto.StartCronjobRequest{
+			Schedule: req.Schedule,
+			Types:    req.Types,
+			Rewind:   req.Rewind,
+		},
+		CreatedAt: time.Now(),
+	}
+
+	if err := h.dataService.SaveSchedule(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("handlers.addSchedule: failed, reason: %w", err)
+	}
+
+	if err := h.register(ctx, schedule); err != nil {
+		return nil, fmt.Errorf("handlers.addSchedule: failed, reason: %w", err)
+	}
+
+	h.logger.Info().Msgf("handlers.addSchedule: added, id=%s; spec=%s;", schedule.ID, req.Schedule)
+
+	return schedule, nil
+}
+
+// findSchedule returns the persisted schedule, config schedules can not be managed.
+func (h *handlerImpl) findSchedule(ctx context.Context, id string) (*dto.Schedule, error) {
+	h.schedules.mu.Lock()
+	entry, ok := h.schedules.entries[id]
+	h.schedules.mu.Unlock()
+
+	if ok && entry.schedule.Static {
+		return nil, fmt.Errorf("handlers.findSchedule: failed, id=%s; reason: %w", id, ErrScheduleStatic)
+	}
+
+	schedules, err := h.dataService.ListSchedules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("handlers.findSchedule: failed, reason: %w", err)
+	}
+
+	for _, schedule := range schedules {
+		if schedule.ID == id {
+			return schedule, nil
+		}
+	}
+
+	return nil, fmt.Errorf("handlers.findSchedule: failed, id=%s; reason: %w", id, ErrScheduleNotFound)
+}
+
+// SyncSchedules aligns the cronjobs of this instance with the schedules persisted in Redis.
+func (h *handlerImpl) SyncSchedules(ctx context.Context) error {
+	schedules, err := h.dataService.ListSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("handlers.SyncSchedules: failed, reason: %w", err)
+	}
+
+	persisted := make(map[string]bool, len(schedules))
+
+	for _, schedule := range schedules {
+		persisted[schedule.ID] = true
+
+		if err = h.register(ctx, schedule); err != nil {
+			h.logger.Error().Err(err).Msgf("handlers.SyncSchedules: failed, id=%s;", schedule.ID)
+		}
+	}
+
+	h.schedules.mu.Lock()
+
+	var removed []string
+
+	for id, entry := range h.schedules.entries {
+		if !entry.schedule.Static && !persisted[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	h.schedules.mu.Unlock()
+
+	for _, id := range removed {
+		h.unregister(id)
+	}
+
+	return nil
+}
+
+// ListSchedules returns the schedules of this instance with the next and previous run times.
+func (h *handlerImpl) ListSchedules(_ context.Context) []*dto.Schedule {
+	h.schedules.mu.Lock()
+	defer h.schedules.mu.Unlock()
+
+	schedules := make([]*dto.Schedule, 0, len(h.schedules.entries))
+
+	for _, entry := range h.schedules.entries {
+		schedule := *entry.schedule
+
+		if entry.entryID != 0 {
+			cronEntry := h.dataService.JobEntry(entry.entryID)
+			if !cronEntry.Next.IsZero() {
+				schedule.Next = &cronEntry.Next
+			}
+
+			if !cronEntry.Prev.IsZero() {
+				schedule.Prev = &cronEntry.Prev
+			}
+		}
+
+		schedules = append(schedules, &schedule)
+	}
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+
+	return schedules
+}
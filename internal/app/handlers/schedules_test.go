@@ -0,0 +1,233 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	cron "github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubService keeps the cronjobs and the persisted schedules in memory.
+type stubService struct {
+	services.IService
+	jobs      map[cron.EntryID]string
+	schedules map[string]*dto.Schedule
+	nextID    cron.EntryID
+	mu        sync.Mutex
+}
+
+func newStubService() *stubService {
+	return &stubService{
+		jobs:      make(map[cron.EntryID]string),
+		schedules: make(map[string]*dto.Schedule),
+	}
+}
+
+func (s *stubService) AddJob(_ context.Context, spec string, _ func()) (cron.EntryID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	s.jobs[s.nextID] = spec
+
+	return s.nextID, nil
+}
+
+func (s *stubService) RemoveJob(id cron.EntryID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+}
+
+func (s *stubService) JobEntry(id cron.EntryID) cron.Entry {
+	return cron.Entry{ID: id}
+}
+
+func (s *stubService) SaveSchedule(_ context.Context, schedule *dto.Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *schedule
+	s.schedules[schedule.ID] = &copied
+
+	return nil
+}
+
+func (s *stubService) DeleteSchedule(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.schedules, id)
+
+	return nil
+}
+
+func (s *stubService) ListSchedules(_ context.Context) ([]*dto.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]*dto.Schedule, 0, len(s.schedules))
+
+	for _, schedule := range s.schedules {
+		copied := *schedule
+		schedules = append(schedules, &copied)
+	}
+
+	return schedules, nil
+}
+
+// specs returns the specs of the registered cronjobs.
+func (s *stubService) specs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	specs := make([]string, 0, len(s.jobs))
+	for _, spec := range s.jobs {
+		specs = append(specs, spec)
+	}
+
+	return specs
+}
+
+func TestManageSchedule(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "handlers").Logger()
+
+	tests := []struct {
+		name      string
+		reqs      []*dto.StartCronjobRequest
+		wantSpecs []string
+		wantErr   error
+	}{
+		{
+			name: "add schedule",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionAddSchedule, ScheduleID: "a", Schedule: "00 18 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+			},
+			wantSpecs: []string{"00 18 * * *"},
+		},
+		{
+			name: "add schedule again with another spec",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionAddSchedule, ScheduleID: "a", Schedule: "00 18 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+				{Action: dto.ActionAddSchedule, ScheduleID: "a", Schedule: "00 19 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+			},
+			wantSpecs: []string{"00 19 * * *"},
+		},
+		{
+			name: "pause and resume schedule",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionAddSchedule, ScheduleID: "a", Schedule: "00 18 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+				{Action: dto.ActionPauseSchedule, ScheduleID: "a"},
+				{Action: dto.ActionResumeSchedule, ScheduleID: "a"},
+			},
+			wantSpecs: []string{"00 18 * * *"},
+		},
+		{
+			name: "remove schedule",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionAddSchedule, ScheduleID: "a", Schedule: "00 18 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+				{Action: dto.ActionRemoveSchedule, ScheduleID: "a"},
+			},
+			wantSpecs: []string{},
+		},
+		{
+			name: "remove unknown schedule",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionRemoveSchedule, ScheduleID: "a"},
+			},
+			wantSpecs: []string{},
+			wantErr:   ErrScheduleNotFound,
+		},
+		{
+			name: "add schedule with a config id",
+			reqs: []*dto.StartCronjobRequest{
+				{Action: dto.ActionAddSchedule, ScheduleID: "config-a", Schedule: "00 18 * * *", Types: []convert.Source{convert.TwseDailyClose}},
+			},
+			wantSpecs: []string{},
+			wantErr:   ErrScheduleStatic,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := newStubService()
+			h := New(svc, &logger)
+
+			var err error
+			for _, req := range tt.reqs {
+				_, err = h.ManageSchedule(context.Background(), req)
+			}
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.ElementsMatch(t, tt.wantSpecs, svc.specs())
+		})
+	}
+}
+
+func TestSyncSchedules(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "handlers").Logger()
+	ctx := context.Background()
+
+	svc := newStubService()
+	h := New(svc, &logger)
+
+	static := &dto.StartCronjobRequest{Schedule: "00 15 * * 1-6", Types: []convert.Source{convert.TwseDailyClose}}
+	assert.NoError(t, h.CronDownload(ctx, static))
+
+	for _, id := range []string{"a", "b"} {
+		//nolint:nolintlint, errcheck
+		svc.SaveSchedule(ctx, &dto.Schedule{ID: id, Request: &dto.StartCronjobRequest{
+			Schedule: "00 18 * * *",
+			Types:    []convert.Source{convert.TwseDailyClose},
+		}})
+	}
+
+	assert.NoError(t, h.SyncSchedules(ctx))
+	assert.ElementsMatch(t, []string{"00 15 * * 1-6", "00 18 * * *", "00 18 * * *"}, svc.specs())
+
+	// edited and removed by another instance
+	//nolint:nolintlint, errcheck
+	svc.SaveSchedule(ctx, &dto.Schedule{ID: "a", Request: &dto.StartCronjobRequest{
+		Schedule: "00 19 * * *",
+		Types:    []convert.Source{convert.TwseDailyClose, convert.TpexDailyClose},
+	}})
+	//nolint:nolintlint, errcheck
+	svc.DeleteSchedule(ctx, "b")
+
+	assert.NoError(t, h.SyncSchedules(ctx))
+	assert.ElementsMatch(t, []string{"00 15 * * 1-6", "00 19 * * *"}, svc.specs())
+
+	schedules := h.ListSchedules(ctx)
+	assert.Len(t, schedules, 2)
+	assert.Equal(t, "a", schedules[0].ID)
+	assert.Equal(t, []convert.Source{convert.TwseDailyClose, convert.TpexDailyClose}, schedules[0].Request.Types)
+	assert.True(t, schedules[1].Static)
+}
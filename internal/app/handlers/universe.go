@@ -0,0 +1,31 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+)
+
+// ListUniverse returns the listed stocks of the universe matching the filter.
+func (h *handlerImpl) ListUniverse(ctx context.Context, filter *dto.StockFilter) ([]*dto.ListedStock, error) {
+	stocks, err := h.dataService.ListUniverse(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("handlers.ListUniverse: failed, reason: %w", err)
+	}
+
+	return stocks, nil
+}
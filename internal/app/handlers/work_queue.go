@@ -0,0 +1,391 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+)
+
+const (
+	// units not published after this many attempts are dropped
+	maxWorkAttempts = 3
+	// the owner polls its work queue while the last claims of other instances run
+	workQueuePollInterval = 5 * time.Second
+	// claims are visible again after this period unless set
+	defaultWorkVisibility = 10 * time.Minute
+)
+
+// WorkQueue shares the per-stock downloads between the instances, disabled if Batch is not set.
+type WorkQueue struct {
+	// Batch is the count of stocks claimed by each crawl of an instance.
+	Batch int
+	// Visibility of a claim, the stocks not settled within it are claimed again.
+	// Defaults to 10 minutes.
+	Visibility time.Duration
+}
+
+// WithWorkQueue shares the per-stock downloads through the Redis work queue.
+func WithWorkQueue(queue WorkQueue) Option {
+	return func(h *handlerImpl) {
+		if queue.Visibility <= 0 {
+			queue.Visibility = defaultWorkVisibility
+		}
+
+		h.queue = queue
+	}
+}
+
+// workUnit is the item of the work queue, the pages of a stock are claimed together
+// so the broadcastor aggregates them on the same instance.
+type workUnit struct {
+	Date     string         `json:"date"`
+	StockID  string         `json:"stockId"`
+	Links    []string       `json:"links"`
+	Strategy convert.Source `json:"strategy"`
+	Attempts int            `json:"attempts"`
+}
+
+type claimedUnit struct {
+	unit *workUnit
+	// item is the claimed item as queued
+	item string
+}
+
+// queueIterator claims up to limit units of the work queue and iterates their links.
+type queueIterator struct {
+	claim    func() (*claimedUnit, error)
+	err      error
+	units    []*claimedUnit
+	links    []*graph.Link
+	limit    int
+	curIndex int
+}
+
+// Next implements graph.LinkIterator.
+func (i *queueIterator) Next() bool {
+	for i.curIndex >= len(i.links) {
+		if len(i.units) >= i.limit {
+			return false
+		}
+
+		claimed, err := i.claim()
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return false
+		} else if err != nil {
+			i.err = err
+
+			return false
+		}
+
+		i.units = append(i.units, claimed)
+
+		for _, l := range claimed.unit.Links {
+			i.links = append(i.links, &graph.Link{
+				URL:      l,
+				Date:     claimed.unit.Date,
+				Strategy: claimed.unit.Strategy,
+			})
+		}
+	}
+
+	i.curIndex++
+
+	return true
+}
+
+// Error implements graph.LinkIterator.
+func (i *queueIterator) Error() error {
+	return i.err
+}
+
+// Link implements graph.LinkIterator.
+func (i *queueIterator) Link() *graph.Link {
+	link := new(graph.Link)
+	*link = *i.links[i.curIndex-1]
+
+	return link
+}
+
+// parentJob returns the job owning the work queue.
+func parentJob(queue string) string {
+	id, _, _ := strings.Cut(queue, ":")
+
+	return id
+}
+
+// shareStocks enqueues the stocks of the per-stock source not checkpointed on the date
+// and returns the work queue, empty if every stock is done already.
+func (h *handlerImpl) shareStocks(
+	ctx context.Context,
+	jobID, date string,
+	strategy convert.Source,
+	req *dto.StartCronjobRequest,
+) (string, error) {
+	def, err := convert.Lookup(strategy)
+	if err != nil {
+		return "", fmt.Errorf("handlers.shareStocks: failed, reason: %w", err)
+	}
+
+	stocks, err := h.pendingStocks(ctx, jobID, date, strategy, req)
+	if err != nil {
+		return "", fmt.Errorf("handlers.shareStocks: failed, reason: %w", err)
+	}
+
+	if len(stocks) == 0 {
+		return "", nil
+	}
+
+	items := make([]string, 0, len(stocks))
+
+	for _, stockID := range stocks {
+		item, err := jsoni.MarshalToString(&workUnit{
+			Date:     date,
+			StockID:  stockID,
+			Links:    def.StockLinks(stockID),
+			Strategy: strategy,
+		})
+		if err != nil {
+			return "", fmt.Errorf("handlers.shareStocks: failed, reason: %w", err)
+		}
+
+		items = append(items, item)
+	}
+
+	// align the date format to be 20220107, but remains the query date as 2022-01-07
+	queue := jobID + ":" + strings.ReplaceAll(date, "-", "")
+
+	if err = h.dataService.EnqueueWork(ctx, queue, items); err != nil {
+		return "", fmt.Errorf("handlers.shareStocks: failed, reason: %w", err)
+	}
+
+	h.logger.Info().Msgf("handlers.shareStocks: enqueued, queue=%s; stocks=%d;", queue, len(items))
+
+	return queue, nil
+}
+
+// consumeWorkQueue crawls the units of the work queue batch by batch. The owner waits
+// for the claims of the other instances and deletes the queue once every unit is settled,
+// or once canceled, while the other instances return when nothing is pending.
+func (h *handlerImpl) consumeWorkQueue(ctx context.Context, jobID, queue string, owner bool) error {
+	for {
+		claimed, err := h.crawlWork(ctx, jobID, queue)
+		if ctx.Err() != nil {
+			if owner {
+				h.deleteWorkQueue(context.WithoutCancel(ctx), queue)
+			}
+
+			return fmt.Errorf("handlers.consumeWorkQueue: failed, reason: %w", ctx.Err())
+		} else if err != nil && !errors.Is(err, crawler.ErrLinksFailed) {
+			// the units of the links given up are requeued
+			return fmt.Errorf("handlers.consumeWorkQueue: failed, reason: %w", err)
+		}
+
+		if claimed > 0 {
+			continue
+		}
+
+		pending, running, err := h.dataService.WorkQueueLen(ctx, queue)
+		if err != nil {
+			return fmt.Errorf("handlers.consumeWorkQueue: failed, reason: %w", err)
+		}
+
+		if pending == 0 && running == 0 {
+			h.deleteWorkQueue(ctx, queue)
+
+			return nil
+		}
+
+		if !owner {
+			return nil
+		}
+
+		// claims expired on a stopped instance are claimed again by the next poll
+		select {
+		case <-ctx.Done():
+		case <-time.After(workQueuePollInterval):
+		}
+	}
+}
+
+func (h *handlerImpl) deleteWorkQueue(ctx context.Context, queue string) {
+	if err := h.dataService.DeleteWorkQueue(ctx, queue); err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.deleteWorkQueue: failed, queue=%s;", queue)
+	}
+}
+
+// crawlWork crawls a batch of units claimed from the work queue, then acks the units
+// done and requeues the others. It returns the count of claimed units.
+func (h *handlerImpl) crawlWork(ctx context.Context, jobID, queue string) (int, error) {
+	linkIt := &queueIterator{
+		limit: h.queue.Batch,
+		claim: func() (*claimedUnit, error) { return h.claimUnit(ctx, jobID, queue) },
+	}
+
+	res, err := h.crawl(ctx, jobID, linkIt)
+
+	// the links of a crawl run to the end are either given up or parsed, a stock
+	// may have no records to publish on the date
+	crawled := ctx.Err() == nil && (err == nil || errors.Is(err, crawler.ErrLinksFailed))
+
+	// settled even if canceled, so the units are not hidden until their claims expire
+	for _, claimed := range linkIt.units {
+		done := res.published[claimed.unit.StockID] || (crawled && !res.failed(claimed.unit))
+		h.settle(context.WithoutCancel(ctx), queue, claimed, done)
+	}
+
+	return len(linkIt.units), err
+}
+
+// failed tells whether a link of the unit was given up.
+func (r *crawlResult) failed(unit *workUnit) bool {
+	for _, l := range unit.Links {
+		if r.abandoned[l] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// claimUnit claims the next unit of the work queue and counts its links into the job,
+// invalid items are dropped.
+func (h *handlerImpl) claimUnit(ctx context.Context, jobID, queue string) (*claimedUnit, error) {
+	for {
+		item, err := h.dataService.ClaimWork(ctx, queue, h.queue.Visibility)
+		if err != nil {
+			return nil, fmt.Errorf("handlers.claimUnit: failed, reason: %w", err)
+		}
+
+		unit := &workUnit{}
+		if err = jsoni.UnmarshalFromString(item, unit); err != nil {
+			h.logger.Error().Err(err).Msgf("handlers.claimUnit: dropped, queue=%s; item=%s;", queue, item)
+
+			if err = h.dataService.AckWork(ctx, queue, item); err != nil {
+				return nil, fmt.Errorf("handlers.claimUnit: failed, reason: %w", err)
+			}
+
+			continue
+		}
+
+		h.jobs.update(jobID, func(job *dto.Job) { source(job, unit.Strategy).Links += len(unit.Links) })
+
+		return &claimedUnit{unit: unit, item: item}, nil
+	}
+}
+
+// settle acks the unit done, the others are requeued until out of attempts.
+func (h *handlerImpl) settle(ctx context.Context, queue string, claimed *claimedUnit, done bool) {
+	unit := claimed.unit
+
+	var err error
+
+	switch {
+	case done:
+		err = h.dataService.AckWork(ctx, queue, claimed.item)
+	case unit.Attempts+1 >= maxWorkAttempts:
+		h.logger.Warn().Msgf(
+			"handlers.settle: dropped, queue=%s; stock=%s; attempts=%d;", queue, unit.StockID, unit.Attempts+1,
+		)
+
+		err = h.dataService.AckWork(ctx, queue, claimed.item)
+	default:
+		retry := *unit
+		retry.Attempts++
+
+		var item string
+
+		if item, err = jsoni.MarshalToString(&retry); err == nil {
+			err = h.dataService.RequeueWork(ctx, queue, claimed.item, item)
+		}
+	}
+
+	if err != nil {
+		h.logger.Error().Err(err).Msgf("handlers.settle: failed, queue=%s; stock=%s;", queue, unit.StockID)
+	}
+}
+
+// JoinWorkQueues helps with the work queues of the jobs run by other instances, each
+// consumed as a job of this instance recording its parent job.
+func (h *handlerImpl) JoinWorkQueues(ctx context.Context) error {
+	if h.queue.Batch <= 0 {
+		return nil
+	}
+
+	queues, err := h.dataService.ListWorkQueues(ctx)
+	if err != nil {
+		return fmt.Errorf("handlers.JoinWorkQueues: failed, reason: %w", err)
+	}
+
+	for _, queue := range queues {
+		// consumed by the owner already
+		if _, err := h.jobs.get(parentJob(queue)); err == nil {
+			continue
+		}
+
+		pending, claimed, err := h.dataService.WorkQueueLen(ctx, queue)
+		if err != nil {
+			h.logger.Error().Err(err).Msgf("handlers.JoinWorkQueues: failed, queue=%s;", queue)
+
+			continue
+		}
+
+		if pending == 0 {
+			// finished, or abandoned and expired
+			if claimed == 0 {
+				h.deleteWorkQueue(ctx, queue)
+			}
+
+			continue
+		}
+
+		if _, loaded := h.consuming.LoadOrStore(queue, true); !loaded {
+			go h.helpWorkQueue(ctx, queue)
+		}
+	}
+
+	return nil
+}
+
+// helpWorkQueue consumes the work queue of another instance until nothing is pending.
+func (h *handlerImpl) helpWorkQueue(ctx context.Context, queue string) {
+	defer h.consuming.Delete(queue)
+
+	parent := parentJob(queue)
+
+	// the job runs the request of its parent, if still recorded
+	var req *dto.StartCronjobRequest
+	if job, err := h.dataService.GetJob(ctx, parent); err == nil {
+		req = job.Request
+	}
+
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	job := h.jobs.create(req, cancel)
+	h.jobs.update(job.ID, func(job *dto.Job) { job.Parent = parent })
+
+	h.logger.Info().Msgf("handlers.helpWorkQueue: started, id=%s; queue=%s;", job.ID, queue)
+
+	h.track(jobCtx, job.ID, func() error { return h.consumeWorkQueue(jobCtx, job.ID, queue, false) })
+}
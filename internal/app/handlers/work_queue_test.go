@@ -0,0 +1,232 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// queueService keeps a work queue in memory and crawls the claimed links with crawl.
+type queueService struct {
+	services.IService
+	crawl    func(links []*graph.Link, interceptChan chan convert.InterceptData) error
+	pending  []string
+	acked    []string
+	requeued []string
+	mu       sync.Mutex
+}
+
+func (s *queueService) ClaimWork(_ context.Context, _ string, _ time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return "", cache.ErrCacheMiss
+	}
+
+	item := s.pending[0]
+	s.pending = s.pending[1:]
+
+	return item, nil
+}
+
+func (s *queueService) AckWork(_ context.Context, _, item string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.acked = append(s.acked, item)
+
+	return nil
+}
+
+func (s *queueService) RequeueWork(_ context.Context, _, _, item string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requeued = append(s.requeued, item)
+
+	return nil
+}
+
+func (s *queueService) SendThroughKafka(_ context.Context, _ convert.Source, _ *[]any) error {
+	return nil
+}
+
+func (s *queueService) MarkCheckpoint(_ context.Context, _ convert.Source, _ string, _ []string) error {
+	return nil
+}
+
+func (s *queueService) Crawl(
+	_ context.Context,
+	linkIt graph.LinkIterator,
+	interceptChan ...chan convert.InterceptData,
+) (int, error) {
+	defer close(interceptChan[0])
+
+	var links []*graph.Link
+	for linkIt.Next() {
+		links = append(links, linkIt.Link())
+	}
+
+	return len(links), s.crawl(links, interceptChan[0])
+}
+
+func workItem(t *testing.T, stockID string, attempts int) string {
+	t.Helper()
+
+	item, err := jsoni.MarshalToString(&workUnit{
+		Date:     "20220801",
+		StockID:  stockID,
+		Links:    []string{"https://example.com/" + stockID},
+		Strategy: convert.StakeConcentration,
+		Attempts: attempts,
+	})
+	assert.NoError(t, err)
+
+	return item
+}
+
+func TestCrawlWork(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "handlers").Logger()
+
+	// publishes the stake concentration of 2330 only
+	publish2330 := func(_ []*graph.Link, interceptChan chan convert.InterceptData) error {
+		interceptChan <- convert.InterceptData{
+			Data: &[]any{&entity.StakeConcentration{StockID: "2330"}},
+			Type: convert.StakeConcentration,
+		}
+
+		return nil
+	}
+
+	tests := []struct {
+		name         string
+		crawl        func([]*graph.Link, chan convert.InterceptData) error
+		attempts     int
+		canceled     bool
+		wantAcked    []string
+		wantRequeued []string
+	}{
+		{
+			name:      "published and crawled without records acked",
+			crawl:     publish2330,
+			wantAcked: []string{"2330", "2317"},
+		},
+		{
+			name: "crawled with other links given up acked",
+			crawl: func(_ []*graph.Link, _ chan convert.InterceptData) error {
+				return fmt.Errorf("crawl: %w", crawler.ErrLinksFailed)
+			},
+			wantAcked: []string{"2330", "2317"},
+		},
+		{
+			name: "failed crawl requeued",
+			crawl: func(_ []*graph.Link, _ chan convert.InterceptData) error {
+				return errors.New("broken pipeline")
+			},
+			wantRequeued: []string{"2330", "2317"},
+		},
+		{
+			name:         "canceled crawl requeued unless published",
+			crawl:        publish2330,
+			canceled:     true,
+			wantAcked:    []string{"2330"},
+			wantRequeued: []string{"2317"},
+		},
+		{
+			name:      "canceled crawl out of attempts dropped",
+			crawl:     publish2330,
+			attempts:  maxWorkAttempts - 1,
+			canceled:  true,
+			wantAcked: []string{"2330", "2317"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := &queueService{
+				crawl:   tt.crawl,
+				pending: []string{workItem(t, "2330", tt.attempts), workItem(t, "2317", tt.attempts)},
+			}
+			h, ok := New(svc, &logger, WithWorkQueue(WorkQueue{Batch: 10})).(*handlerImpl)
+			assert.True(t, ok)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.canceled {
+				cancel()
+			}
+
+			claimed, _ := h.crawlWork(ctx, "job-1", "job-1:20220801")
+			assert.Equal(t, 2, claimed)
+
+			wantAcked := make([]string, 0, len(tt.wantAcked))
+			for _, stockID := range tt.wantAcked {
+				wantAcked = append(wantAcked, workItem(t, stockID, tt.attempts))
+			}
+
+			wantRequeued := make([]string, 0, len(tt.wantRequeued))
+			for _, stockID := range tt.wantRequeued {
+				wantRequeued = append(wantRequeued, workItem(t, stockID, tt.attempts+1))
+			}
+
+			assert.ElementsMatch(t, wantAcked, svc.acked)
+			assert.ElementsMatch(t, wantRequeued, svc.requeued)
+		})
+	}
+}
+
+func TestCrawlResultFailed(t *testing.T) {
+	t.Parallel()
+
+	res := &crawlResult{abandoned: map[string]bool{"https://example.com/2330?page=2": true}}
+
+	assert.True(t, res.failed(&workUnit{Links: []string{"https://example.com/2330", "https://example.com/2330?page=2"}}))
+	assert.False(t, res.failed(&workUnit{Links: []string{"https://example.com/2317"}}))
+}
+
+func TestWithWorkQueue(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "handlers").Logger()
+
+	h, ok := New(&queueService{}, &logger, WithWorkQueue(WorkQueue{Batch: 10})).(*handlerImpl)
+	assert.True(t, ok)
+	assert.Equal(t, defaultWorkVisibility, h.queue.Visibility)
+
+	h, ok = New(&queueService{}, &logger, WithWorkQueue(WorkQueue{Batch: 10, Visibility: time.Minute})).(*handlerImpl)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, h.queue.Visibility)
+}
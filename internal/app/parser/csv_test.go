@@ -29,11 +29,15 @@ func TestParseCsv(t *testing.T) {
 	correctCsv, _ := helper.ReadFromFile(".testfiles/correct.csv")
 	threePrimaryCsv, _ := helper.ReadFromFile(".testfiles/twse_threeprimary.csv")
 	tpexThreePrimaryCsv, _ := helper.ReadFromFile(".testfiles/tpex_threeprimary.csv")
+	twseMarginTradeCsv, _ := helper.ReadFromFile(".testfiles/twse_margintrade.csv")
+	tpexMarginTradeCsv, _ := helper.ReadFromFile(".testfiles/tpex_margintrade.csv")
 
 	b1, _ := helper.EncodeBig5([]byte(correctCsv))
 	b2, _ := helper.EncodeBig5([]byte(wrongCsv))
 	b3, _ := helper.EncodeBig5([]byte(threePrimaryCsv))
 	b4, _ := helper.EncodeBig5([]byte(tpexThreePrimaryCsv))
+	b5, _ := helper.EncodeBig5([]byte(twseMarginTradeCsv))
+	b6, _ := helper.EncodeBig5([]byte(tpexMarginTradeCsv))
 
 	tests := []struct {
 		name    string
@@ -65,6 +69,18 @@ func TestParseCsv(t *testing.T) {
 			want:    63,
 			target:  convert.TpexThreePrimary,
 		},
+		{
+			name:    "twse margin trade csv",
+			content: string(b5),
+			want:    4,
+			target:  convert.TwseMarginTrade,
+		},
+		{
+			name:    "tpex margin trade csv",
+			content: string(b6),
+			want:    3,
+			target:  convert.TpexMarginTrade,
+		},
 	}
 
 	for _, tt := range tests {
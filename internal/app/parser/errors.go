@@ -19,4 +19,5 @@ var (
 	ErrNoParseResults          = errors.New("empty parsing results")
 	ErrWrongConcentrationTitle = errors.New("wrong concentration html title")
 	ErrParseDayMissing         = errors.New("parse day missing")
+	ErrStrategyMissing         = errors.New("parser strategy missing")
 )
@@ -0,0 +1,62 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+)
+
+// holidayStrategy parses the TWSE market holiday schedule csv, one row per date
+// with the name, the date in ROC year, the weekday and the description.
+type holidayStrategy struct {
+	converter convert.IConvert
+	source    convert.Source
+	capacity  int
+}
+
+func (s *holidayStrategy) Parse(input io.Reader, _ ...string) ([]any, error) {
+	var output []any
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	for {
+		records, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if len(records) == 0 || s.capacity > len(records) {
+			continue
+		}
+
+		// the converter skips the title, the header and the trading day notices
+		res := s.converter.Execute(&convert.Data{
+			RawData: records,
+			Target:  s.source,
+		})
+		if res != nil {
+			output = append(output, res)
+		}
+	}
+
+	if len(output) == 0 {
+		return nil, ErrNoParseResults
+	}
+
+	return output, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHoliday(t *testing.T) {
+	t.Parallel()
+
+	correctCsv, err := helper.ReadFromFile(".testfiles/twse_holiday.csv")
+	if err != nil {
+		t.Errorf("failed to load csv test file: %s", err)
+	}
+
+	correctBytes, _ := helper.EncodeBig5([]byte(correctCsv))
+
+	res := &parserImpl{
+		result: &[]any{},
+	}
+	res.SetStrategy(convert.TwseHolidaySchedule, "2024")
+
+	if err := res.Execute(*bytes.NewBuffer(correctBytes)); err != nil {
+		t.Errorf("parser.Execute() error = %v", err)
+	}
+
+	var dates []string
+
+	var makeUps []string
+
+	for _, val := range *res.result {
+		holiday, ok := val.(*entity.Holiday)
+		if !ok {
+			t.Fatalf("parser.result = %+v", val)
+		}
+
+		if holiday.Trading {
+			makeUps = append(makeUps, holiday.Date)
+		} else {
+			dates = append(dates, holiday.Date)
+		}
+	}
+
+	// trading day notices and the non-trading make-up workday are not holidays
+	assert.Equal(t, []string{"20240101", "20240206", "20240208"}, dates)
+	assert.Equal(t, []string{"20240224"}, makeUps)
+}
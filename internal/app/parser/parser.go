@@ -27,14 +27,6 @@ import (
 	"golang.org/x/xerrors"
 )
 
-const (
-	StockCap            = 5
-	DailyCloseCap       = 17
-	TwseThreePrimaryCap = 19
-	TpexThreePrimaryCap = 24
-	ConcentrationCap    = 7
-)
-
 type Parser interface {
 	SetStrategy(source convert.Source, additional ...string)
 	Execute(in bytes.Buffer, additional ...string) error
@@ -65,44 +57,59 @@ func New(cfg Config) Parser {
 }
 
 func (p *parserImpl) SetStrategy(source convert.Source, additional ...string) {
-	switch source {
-	case convert.TpexStockList, convert.TwseStockList:
+	def, err := convert.Lookup(source)
+	if err != nil {
+		p.strategy = nil
+
+		return
+	}
+
+	var date string
+	if len(additional) > 0 {
+		date = additional[0]
+	}
+
+	switch def.Parser {
+	case convert.HTMLParser:
 		p.strategy = &htmlStrategy{
-			capacity:  StockCap,
+			capacity:  def.Capacity,
 			source:    source,
-			converter: convert.Stock(),
+			converter: def.Converter,
 		}
-	case convert.TwseDailyClose, convert.TpexDailyClose:
+	case convert.CsvParser:
 		p.strategy = &csvStrategy{
-			capacity:  DailyCloseCap,
+			capacity:  def.Capacity,
 			source:    source,
-			converter: convert.DailyClose(),
-			date:      additional[0],
+			converter: def.Converter,
+			date:      date,
 		}
-	case convert.TwseThreePrimary:
-		p.strategy = &csvStrategy{
-			capacity:  TwseThreePrimaryCap,
+	case convert.RevenueParser:
+		p.strategy = &revenueStrategy{
+			capacity:  def.Capacity,
 			source:    source,
-			converter: convert.ThreePrimary(),
-			date:      additional[0],
+			converter: def.Converter,
+			date:      date,
 		}
-	case convert.TpexThreePrimary:
-		p.strategy = &csvStrategy{
-			capacity:  TpexThreePrimaryCap,
+	case convert.HolidayParser:
+		p.strategy = &holidayStrategy{
+			capacity:  def.Capacity,
 			source:    source,
-			converter: convert.ThreePrimary(),
-			date:      additional[0],
+			converter: def.Converter,
 		}
-	case convert.StakeConcentration:
+	case convert.ConcentrationParser:
 		p.strategy = &concentrationStrategy{
-			capacity:  ConcentrationCap,
-			date:      additional[0],
-			converter: convert.Concentration(),
+			capacity:  def.Capacity,
+			date:      date,
+			converter: def.Converter,
 		}
 	}
 }
 
 func (p *parserImpl) Execute(in bytes.Buffer, additional ...string) error {
+	if p.strategy == nil {
+		return xerrors.Errorf("parser.Execute: failed, err=%w;", ErrStrategyMissing)
+	}
+
 	reader := transform.NewReader(&in, traditionalchinese.Big5.NewDecoder())
 
 	res, err := p.strategy.Parse(reader, additional...)
@@ -0,0 +1,107 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"io"
+	"strings"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"golang.org/x/net/html"
+)
+
+// revenueStrategy parses the MOPS monthly revenue report, which is split into
+// one table per industry where each company row starts with its stock id.
+type revenueStrategy struct {
+	converter convert.IConvert
+	date      string
+	source    convert.Source
+	capacity  int
+}
+
+//nolint:nolintlint, cyclop
+func (s *revenueStrategy) Parse(input io.Reader, _ ...string) ([]any, error) {
+	if s.date == "" {
+		return nil, ErrParseDayMissing
+	}
+
+	var output []any
+
+	var records []string
+
+	var cell strings.Builder
+
+	var isColumn bool
+
+	tokenizer := html.NewTokenizer(input)
+
+	for {
+		next := tokenizer.Next()
+
+		//nolint:nolintlint,exhaustive // ignore rest of the TokenType
+		switch next {
+		case html.StartTagToken:
+			// the cells may nest formatting tags like <font>, kept in the column
+			switch tokenizer.Token().Data {
+			case "td":
+				isColumn = true
+
+				cell.Reset()
+			case "tr":
+				isColumn = false
+			}
+		case html.TextToken:
+			if isColumn {
+				cell.WriteString(tokenizer.Token().Data)
+			}
+		case html.EndTagToken:
+			t := tokenizer.Token()
+			if t.Data == "td" {
+				// the fields are mapped by position, so the empty cells are kept
+				if isColumn {
+					records = append(records, strings.TrimSpace(cell.String()))
+				}
+
+				isColumn = false
+			}
+
+			if t.Data != "tr" {
+				continue
+			}
+
+			isColumn = false
+
+			// make sure only parse recognized stock_id rows
+			if s.capacity <= len(records) && len(records[0]) < 6 && helper.IsInteger(records[0]) {
+				res := s.converter.Execute(&convert.Data{
+					ParseDate: s.date,
+					RawData:   records,
+					Target:    s.source,
+				})
+				if res != nil {
+					output = append(output, res)
+				}
+			}
+			// reset the buffer to parse next row
+			records = []string{}
+		case html.ErrorToken:
+			if len(output) == 0 {
+				return nil, ErrNoParseResults
+			}
+
+			return output, nil
+		}
+	}
+}
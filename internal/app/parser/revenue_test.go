@@ -0,0 +1,100 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+)
+
+func TestParseRevenue(t *testing.T) {
+	t.Parallel()
+
+	correctDoc, err := helper.ReadFromFile(".testfiles/monthly_revenue.html")
+	if err != nil {
+		t.Errorf("failed to load html test file: %s", err)
+	}
+
+	correctBytes, _ := helper.EncodeBig5([]byte(correctDoc))
+
+	tests := []struct {
+		name    string
+		content string
+		date    string
+		want    int
+	}{
+		{
+			name:    "normal monthly revenue html",
+			content: string(correctBytes),
+			date:    "202210",
+			want:    4,
+		},
+		{
+			name:    "wrong monthly revenue html",
+			content: "<html><body></body></html>",
+			date:    "202210",
+			want:    0,
+		},
+		{
+			name:    "missing parse month",
+			content: string(correctBytes),
+			date:    "",
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := &parserImpl{
+				result: &[]any{},
+			}
+			res.SetStrategy(convert.TwseMonthlyRevenue, tt.date)
+			//nolint:nolintlint, errcheck
+			res.Execute(*bytes.NewBuffer([]byte(tt.content)))
+
+			if got := len(*res.result); got != tt.want {
+				t.Errorf("len(parser.result) = %v, want %v", got, tt.want)
+			}
+
+			if tt.want > 0 {
+				if val, ok := (*res.result)[0].(*entity.MonthlyRevenue); !ok || val.StockID != "1101" || val.Month != tt.date {
+					t.Errorf("parser.result[0] = %+v", (*res.result)[0])
+				}
+
+				// the empty cells keep the following fields in place
+				want := &entity.MonthlyRevenue{
+					StockID:                   "2303",
+					Month:                     tt.date,
+					Revenue:                   24925116,
+					LastMonthRevenue:          24181374,
+					MoM:                       3.07,
+					CumulativeRevenue:         213449683,
+					LastYearCumulativeRevenue: 161938924,
+					CumulativeYoY:             31.8,
+				}
+				if val, ok := (*res.result)[3].(*entity.MonthlyRevenue); !ok || *val != *want {
+					t.Errorf("parser.result[3] = %+v, want %+v", (*res.result)[3], want)
+				}
+			}
+		})
+	}
+}
@@ -36,6 +36,13 @@ type Processor interface {
 	Process(context.Context, Payload) (Payload, error)
 }
 
+// Abandoner is implemented by processors which are notified of the payloads given
+// up, after the last failed attempt of processing them.
+type Abandoner interface {
+	// Abandon is invoked once per payload given up with the error of its last attempt.
+	Abandon(ctx context.Context, p Payload, err error)
+}
+
 // ProcessorFunc is an adapter to allow the use of plain functions as Processor
 // instances. If f is a function with the appropriate signature, ProcessorFunc(f)
 // is a Processor that calls f.
@@ -78,6 +85,17 @@ type StageRunner interface {
 	Run(context.Context, StageParams)
 }
 
+// Throttler is implemented by types that pace the processing of payloads.
+// Payloads sharing the same lane are throttled together.
+type Throttler interface {
+	// Lane returns the name of the lane the payload is throttled by.
+	Lane(Payload) string
+
+	// Wait blocks until one more payload of the lane is allowed to be
+	// processed, or returns an error if the context is done first.
+	Wait(ctx context.Context, lane string) error
+}
+
 // Source is implemented by types that generate Payload instances which can be
 // used as inputs to a Pipeline instance.
 type Source interface {
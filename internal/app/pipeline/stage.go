@@ -15,6 +15,7 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -26,6 +27,30 @@ const (
 	defaultRetryTimes = 3
 )
 
+// StageError is emitted when a stage gives a payload up, unlike the errors of the
+// source and the sink the other payloads are still processed.
+type StageError struct {
+	Err   error
+	Stage int
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("pipeline stage %d: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// abandon notifies the processor of the payload given up and emits the error.
+func abandon(ctx context.Context, proc Processor, params StageParams, payload Payload, err error) {
+	if abandoner, ok := proc.(Abandoner); ok {
+		abandoner.Abandon(ctx, payload, err)
+	}
+
+	maybeEmitError(&StageError{Stage: params.StageIndex(), Err: err}, params.Error())
+}
+
 type fifo struct {
 	proc Processor
 }
@@ -51,8 +76,7 @@ func (r fifo) Run(ctx context.Context, params StageParams) {
 
 			payloadOut, err := r.proc.Process(ctx, payloadIn)
 			if err != nil {
-				wrappedErr := xerrors.Errorf("pipeline stage %d: %w", params.StageIndex(), err)
-				maybeEmitError(wrappedErr, params.Error())
+				abandon(ctx, r.proc, params, payloadIn, err)
 			}
 			// If the processor did not output a payload for the
 			// next stage there is nothing we need to do.
@@ -83,6 +107,10 @@ type dynamicWorkerPool struct {
 // that can scale up to maxWorkers for processing incoming inputs in parallel
 // and emitting their outputs to the next stage.
 func DynamicWorkerPool(proc Processor, maxWorkers int, interval time.Duration) StageRunner {
+	return newDynamicWorkerPool(proc, maxWorkers, interval)
+}
+
+func newDynamicWorkerPool(proc Processor, maxWorkers int, interval time.Duration) *dynamicWorkerPool {
 	if maxWorkers <= 0 {
 		panic("DynamicWorkerPool: maxWorkers must be > 0")
 	}
@@ -115,54 +143,237 @@ stop:
 				break stop
 			}
 
-			go func(payloadIn Payload, token struct{}) {
-				defer func() { p.tokenPool <- token }()
+			go p.process(ctx, params, payloadIn, token, nil)
 
-				var payloadOut Payload
+			// prevent rate limit
+			<-time.After(p.interval)
+		}
+	}
 
-				err := retry.Retry(defaultRetryTimes, p.interval, func() error {
-					out, procErr := p.proc.Process(ctx, payloadIn)
-					payloadOut = out
+	// Wait for all workers to exit by trying to empty the token pool
+	for i := 0; i < cap(p.tokenPool); i++ {
+		<-p.tokenPool
+	}
+}
 
-					if procErr != nil {
-						return xerrors.Errorf("retry error: %w", procErr)
-					}
+// process runs the processor with retries and emits the output to the next stage,
+// the worker token is returned to the pool once done. Retries are held back by
+// beforeRetry if specified.
+func (p *dynamicWorkerPool) process(
+	ctx context.Context,
+	params StageParams,
+	payloadIn Payload,
+	token struct{},
+	beforeRetry func() error,
+) {
+	defer func() { p.tokenPool <- token }()
 
-					return nil
-				})
-				if err != nil {
-					wrappedErr := xerrors.Errorf("pipeline stage %d: %w", params.StageIndex(), err)
-					maybeEmitError(wrappedErr, params.Error())
+	var (
+		payloadOut Payload
+		attempted  bool
+	)
 
-					return
-				}
+	err := retry.Retry(defaultRetryTimes, p.interval, func() error {
+		if attempted && beforeRetry != nil {
+			if waitErr := beforeRetry(); waitErr != nil {
+				return retry.NoRetryError(waitErr)
+			}
+		}
 
-				// If the processor did not output a payload for the
-				// next stage there is nothing we need to do.
-				if payloadOut == nil {
-					payloadIn.MarkAsProcessed()
+		attempted = true
 
-					return
-				}
+		out, procErr := p.proc.Process(ctx, payloadIn)
+		payloadOut = out
 
-				// Output processed data
-				select {
-				case params.Output() <- payloadOut:
-				case <-ctx.Done():
-				}
-			}(payloadIn, token)
+		if procErr != nil {
+			return xerrors.Errorf("retry error: %w", procErr)
+		}
 
-			// prevent rate limit
-			<-time.After(p.interval)
+		return nil
+	})
+	if err != nil {
+		abandon(ctx, p.proc, params, payloadIn, err)
+
+		return
+	}
+
+	// If the processor did not output a payload for the
+	// next stage there is nothing we need to do.
+	if payloadOut == nil {
+		payloadIn.MarkAsProcessed()
+
+		return
+	}
+
+	// Output processed data
+	select {
+	case params.Output() <- payloadOut:
+	case <-ctx.Done():
+	}
+}
+
+type throttledWorkerPool struct {
+	throttler Throttler
+	dynamicWorkerPool
+}
+
+// ThrottledWorkerPool returns a StageRunner that works like DynamicWorkerPool,
+// except that the pace is decided by the throttler per lane instead of a global
+// interval. Each lane queues its own payloads, so a slow lane never holds back
+// the workers of the others. The interval is only used as the retry backoff.
+func ThrottledWorkerPool(proc Processor, maxWorkers int, interval time.Duration, throttler Throttler) StageRunner {
+	if throttler == nil {
+		panic("ThrottledWorkerPool: throttler must be specified")
+	}
+
+	return &throttledWorkerPool{
+		dynamicWorkerPool: *newDynamicWorkerPool(proc, maxWorkers, interval),
+		throttler:         throttler,
+	}
+}
+
+// Run implements StageRunner.
+func (p *throttledWorkerPool) Run(ctx context.Context, params StageParams) {
+	var (
+		waitGroup sync.WaitGroup
+		lanes     = make(map[string]*lane)
+	)
+
+stop:
+	for {
+		select {
+		case <-ctx.Done():
+			// Asked to cleanly shut down
+			break stop
+		case payloadIn, ok := <-params.Input():
+			if !ok {
+				break stop
+			}
+
+			name := p.throttler.Lane(payloadIn)
+
+			queue, found := lanes[name]
+			if !found {
+				queue = newLane()
+				lanes[name] = queue
+
+				waitGroup.Add(1)
+
+				go func(name string, queue *lane) {
+					defer waitGroup.Done()
+
+					p.dispatch(ctx, params, name, queue)
+				}(name, queue)
+			}
+
+			queue.push(payloadIn)
 		}
 	}
 
+	// Let the lanes drain their queued payloads and exit
+	for _, queue := range lanes {
+		queue.close()
+	}
+
+	waitGroup.Wait()
+
 	// Wait for all workers to exit by trying to empty the token pool
 	for i := 0; i < cap(p.tokenPool); i++ {
 		<-p.tokenPool
 	}
 }
 
+// dispatch hands the payloads of a lane to the workers once the throttler allows.
+func (p *throttledWorkerPool) dispatch(ctx context.Context, params StageParams, name string, queue *lane) {
+	for {
+		payloadIn, ok := queue.pop(ctx)
+		if !ok {
+			return
+		}
+
+		// only fails when the context is done
+		if err := p.throttler.Wait(ctx, name); err != nil {
+			return
+		}
+
+		var token struct{}
+		select {
+		case token = <-p.tokenPool:
+		case <-ctx.Done():
+			return
+		}
+
+		// retries keep the pace of the lane as well
+		go p.process(ctx, params, payloadIn, token, func() error {
+			return p.throttler.Wait(ctx, name)
+		})
+	}
+}
+
+// lane is an unbounded queue of payloads sharing the same throttling.
+type lane struct {
+	notify   chan struct{}
+	payloads []Payload
+	mu       sync.Mutex
+	closed   bool
+}
+
+func newLane() *lane {
+	return &lane{notify: make(chan struct{}, 1)}
+}
+
+func (l *lane) push(payload Payload) {
+	l.mu.Lock()
+	l.payloads = append(l.payloads, payload)
+	l.mu.Unlock()
+
+	l.wakeup()
+}
+
+func (l *lane) close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	l.wakeup()
+}
+
+func (l *lane) wakeup() {
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop blocks until a payload is queued, returns false once the lane
+// is closed and drained or the context is done.
+func (l *lane) pop(ctx context.Context) (Payload, bool) {
+	for {
+		l.mu.Lock()
+		if len(l.payloads) > 0 {
+			payload := l.payloads[0]
+			l.payloads[0] = nil
+			l.payloads = l.payloads[1:]
+			l.mu.Unlock()
+
+			return payload, true
+		}
+
+		closed := l.closed
+		l.mu.Unlock()
+
+		if closed {
+			return nil, false
+		}
+
+		select {
+		case <-l.notify:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
 type broadcast struct {
 	fifos []StageRunner
 }
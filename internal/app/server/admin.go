@@ -0,0 +1,205 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/handlers"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+)
+
+const maxRequestBodySize = 1 << 20
+
+var errUnauthorized = errors.New("missing or invalid admin token")
+
+//nolint:nolintlint, gochecknoglobals
+var jsoni = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// adminAPI exposes the crawl jobs over http:
+//
+// - POST /api/v1/jobs submits a download, the body is a dto.StartCronjobRequest
+// - GET /api/v1/jobs lists the running and recent jobs
+// - GET /api/v1/jobs/{id} returns the progress and outcome of a job
+// - POST /api/v1/jobs/{id}/cancel cancels a running job
+// - GET /api/v1/stocks lists the stock universe, filtered by the market, category and
+// securityType query parameters
+// - GET /api/v1/schedules lists the schedules with their next and previous run times
+// - GET /api/v1/circuits lists the circuit breaker state of the remote hosts, an open
+// circuit is reported only and does not fail the readiness check
+//
+// The POST routes require the admin token as a bearer token, they are refused when no
+// token is configured.
+type adminAPI struct {
+	handler handlers.IHandler
+	logger  *zerolog.Logger
+	token   string
+}
+
+// election is the leadership of the instance.
+type election interface {
+	ID() string
+	Leader() *cache.Leader
+}
+
+// withLeader reports the instance and the leader running the cronjobs, as "id term",
+// in the X-Instance and X-Leader headers of the health check responses.
+func withLeader(health http.Handler, election election) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Instance", election.ID())
+
+		if leader := election.Leader(); leader != nil {
+			w.Header().Set("X-Leader", leader.String())
+		}
+
+		health.ServeHTTP(w, r)
+	})
+}
+
+// newHTTPHandler serves the admin api next to the health check endpoints.
+func newHTTPHandler(
+	handler handlers.IHandler,
+	health http.Handler,
+	token string,
+	logger *zerolog.Logger,
+) http.Handler {
+	api := &adminAPI{handler: handler, logger: logger, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/jobs", api.authorized(api.submitJob))
+	mux.HandleFunc("GET /api/v1/jobs", api.listJobs)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", api.getJob)
+	mux.HandleFunc("POST /api/v1/jobs/{id}/cancel", api.authorized(api.cancelJob))
+	mux.HandleFunc("GET /api/v1/stocks", api.listStocks)
+	mux.HandleFunc("GET /api/v1/schedules", api.listSchedules)
+	mux.HandleFunc("GET /api/v1/circuits", api.listCircuits)
+	mux.Handle("/", health)
+
+	return mux
+}
+
+// authorized lets the requests bearing the admin token through.
+func (a *adminAPI) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if a.token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			a.writeError(w, http.StatusUnauthorized, errUnauthorized)
+
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (a *adminAPI) submitJob(w http.ResponseWriter, r *http.Request) {
+	req := &dto.StartCronjobRequest{}
+
+	if err := jsoni.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(req); err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	job, err := a.handler.SubmitDownload(r.Context(), req)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusAccepted, job)
+}
+
+func (a *adminAPI) listJobs(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.handler.ListJobs(r.Context()))
+}
+
+func (a *adminAPI) getJob(w http.ResponseWriter, r *http.Request) {
+	job, err := a.handler.GetJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		a.writeError(w, statusOf(err), err)
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, job)
+}
+
+func (a *adminAPI) cancelJob(w http.ResponseWriter, r *http.Request) {
+	if err := a.handler.CancelJob(r.Context(), r.PathValue("id")); err != nil {
+		a.writeError(w, statusOf(err), err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *adminAPI) listStocks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	stocks, err := a.handler.ListUniverse(r.Context(), &dto.StockFilter{
+		Markets:       query["market"],
+		Categories:    query["category"],
+		SecurityTypes: query["securityType"],
+	})
+	if err != nil {
+		a.writeError(w, statusOf(err), err)
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, stocks)
+}
+
+func (a *adminAPI) listSchedules(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.handler.ListSchedules(r.Context()))
+}
+
+func (a *adminAPI) listCircuits(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.handler.Circuits(r.Context()))
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, handlers.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, handlers.ErrJobFinished), errors.Is(err, handlers.ErrJobRemote):
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func (a *adminAPI) writeError(w http.ResponseWriter, status int, err error) {
+	a.logger.Warn().Err(err).Msgf("adminAPI: failed, status=%d;", status)
+	a.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (a *adminAPI) writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := jsoni.NewEncoder(w).Encode(body); err != nil {
+		a.logger.Error().Err(err).Msg("adminAPI.writeJSON: failed")
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog/log"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/handlers"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubHandler struct {
+	handlers.IHandler
+	submitted *dto.StartCronjobRequest
+	canceled  string
+}
+
+func (s *stubHandler) SubmitDownload(_ context.Context, req *dto.StartCronjobRequest) (*dto.Job, error) {
+	s.submitted = req
+
+	return &dto.Job{ID: "job-1", Status: dto.JobQueued, Request: req}, nil
+}
+
+func (s *stubHandler) ListJobs(_ context.Context) []*dto.Job {
+	return []*dto.Job{{ID: "job-1", Status: dto.JobRunning}}
+}
+
+func (s *stubHandler) GetJob(_ context.Context, id string) (*dto.Job, error) {
+	if id != "job-1" {
+		return nil, fmt.Errorf("stub: %w", handlers.ErrJobNotFound)
+	}
+
+	return &dto.Job{ID: id, Status: dto.JobSucceeded}, nil
+}
+
+func (s *stubHandler) CancelJob(_ context.Context, id string) error {
+	s.canceled = id
+
+	return nil
+}
+
+func (s *stubHandler) ListSchedules(_ context.Context) []*dto.Schedule {
+	return []*dto.Schedule{{ID: "config-1", Static: true, Request: &dto.StartCronjobRequest{Schedule: "00 15 * * 1-6"}}}
+}
+
+func (s *stubHandler) Circuits(_ context.Context) []circuit.Snapshot {
+	return []circuit.Snapshot{{Key: "www.twse.com.tw", State: circuit.Open.String(), Failures: 5}}
+}
+
+func (s *stubHandler) ListUniverse(_ context.Context, filter *dto.StockFilter) ([]*dto.ListedStock, error) {
+	var stocks []*dto.ListedStock
+
+	for _, stock := range []entity.Stock{
+		{StockID: "2330", Market: "tse", SecurityType: entity.SecurityStock},
+		{StockID: "6488", Market: "otc", SecurityType: entity.SecurityStock},
+	} {
+		if filter.Match(&stock) {
+			stocks = append(stocks, &dto.ListedStock{Stock: stock})
+		}
+	}
+
+	return stocks, nil
+}
+
+type stubElection struct{}
+
+func (stubElection) ID() string            { return "pod-a" }
+func (stubElection) Leader() *cache.Leader { return &cache.Leader{ID: "pod-b", Term: 2} }
+
+func TestAdminAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "server").Logger()
+	adminToken := "secret"
+	health := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusTeapot) })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantBody   string
+		wantHeader http.Header
+	}{
+		{
+			name:       "submit download",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs",
+			body:       `{"types":["TwseDailyClose",0],"date":"20220801"}`,
+			token:      adminToken,
+			wantStatus: http.StatusAccepted,
+			wantBody:   `"types":["TwseDailyClose","TwseDailyClose"]`,
+		},
+		{
+			name:       "submit unknown source",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs",
+			body:       `{"types":["Unknown"]}`,
+			token:      adminToken,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "submit without token",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs",
+			body:       `{"types":["TwseDailyClose"],"date":"20220801"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantHeader: http.Header{"Www-Authenticate": {`Bearer realm="admin"`}},
+		},
+		{
+			name:       "submit with invalid token",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs",
+			body:       `{"types":["TwseDailyClose"],"date":"20220801"}`,
+			token:      "guess",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "list jobs",
+			method:     http.MethodGet,
+			path:       "/api/v1/jobs",
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"running"`,
+		},
+		{
+			name:       "get job",
+			method:     http.MethodGet,
+			path:       "/api/v1/jobs/job-1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"succeeded"`,
+		},
+		{
+			name:       "get missing job",
+			method:     http.MethodGet,
+			path:       "/api/v1/jobs/job-2",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "cancel job",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs/job-1/cancel",
+			token:      adminToken,
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "cancel without token",
+			method:     http.MethodPost,
+			path:       "/api/v1/jobs/job-1/cancel",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "list schedules",
+			method:     http.MethodGet,
+			path:       "/api/v1/schedules",
+			wantStatus: http.StatusOK,
+			wantBody:   `"schedule":"00 15 * * 1-6"`,
+		},
+		{
+			name:       "list stocks",
+			method:     http.MethodGet,
+			path:       "/api/v1/stocks?market=otc&securityType=stock",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"stockId":"6488"`,
+		},
+		{
+			name:       "list circuits",
+			method:     http.MethodGet,
+			path:       "/api/v1/circuits",
+			wantStatus: http.StatusOK,
+			wantBody:   `"key":"www.twse.com.tw","state":"open","failures":5}]`,
+		},
+		{
+			name:       "health check",
+			method:     http.MethodGet,
+			path:       "/live",
+			wantStatus: http.StatusTeapot,
+			wantHeader: http.Header{"X-Instance": {"pod-a"}, "X-Leader": {"pod-b 2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stub := &stubHandler{}
+			mux := newHTTPHandler(stub, withLeader(health, stubElection{}), adminToken, &logger)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+
+			for key := range tt.wantHeader {
+				assert.Equal(t, tt.wantHeader.Get(key), rec.Header().Get(key))
+			}
+
+			switch {
+			case tt.name == "submit download":
+				assert.Equal(t, []convert.Source{convert.TwseDailyClose, convert.TwseDailyClose}, stub.submitted.Types)
+			case tt.wantStatus == http.StatusUnauthorized:
+				assert.Nil(t, stub.submitted)
+				assert.Empty(t, stub.canceled)
+			}
+		})
+	}
+}
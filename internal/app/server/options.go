@@ -17,8 +17,11 @@ package server
 import (
 	"net/http"
 
+	"github.com/rs/zerolog"
 	config "github.com/samwang0723/stock-crawler/configs"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
 	"github.com/samwang0723/stock-crawler/internal/app/handlers"
+	"github.com/samwang0723/stock-crawler/internal/cache"
 )
 
 type Options struct {
@@ -28,6 +31,14 @@ type Options struct {
 	Config      *config.SystemConfig
 	HealthCheck *http.Server
 
+	// Schedules are the cronjobs registered at startup
+	Schedules []*dto.StartCronjobRequest
+
+	// Election starts the cronjobs on the leader only
+	Election *cache.Election
+
+	Logger *zerolog.Logger
+
 	// Before funcs
 	BeforeStart []func() error
 	BeforeStop  []func() error
@@ -72,3 +83,21 @@ func HealthCheck(healthCheck *http.Server) Option {
 		o.HealthCheck = healthCheck
 	}
 }
+
+func Schedules(schedules []*dto.StartCronjobRequest) Option {
+	return func(o *Options) {
+		o.Schedules = schedules
+	}
+}
+
+func Election(election *cache.Election) Option {
+	return func(o *Options) {
+		o.Election = election
+	}
+}
+
+func Logger(logger *zerolog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	config "github.com/samwang0723/stock-crawler/configs"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/handlers"
+	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+)
+
+var errArchivePathMissing = errors.New("archive path is not configured")
+
+// Replay re-parses the archived raw responses without touching the network and
+// publishes the results to kafka, or into the output json lines file if specified.
+func Replay(ctx context.Context, logger *zerolog.Logger, req *dto.StartCronjobRequest, output string) error {
+	config.Load()
+	cfg := config.GetCurrentConfig()
+
+	if cfg.Archive.Path == "" {
+		return fmt.Errorf("server.replay: failed, reason: %w", errArchivePathMissing)
+	}
+
+	sink := services.WithKafka(services.KafkaConfig{
+		Controller: cfg.Kafka.Controller,
+		Topics:     cfg.Kafka.Topics,
+		GroupID:    cfg.Kafka.GroupID,
+		Brokers:    cfg.Kafka.Brokers,
+		Logger:     logger,
+	})
+	if output != "" {
+		var err error
+
+		sink, err = services.WithFileSink(services.FileSinkConfig{
+			Path:   output,
+			Logger: logger,
+		})
+		if err != nil {
+			return fmt.Errorf("server.replay: failed, reason: %w", err)
+		}
+	}
+
+	dataService := services.New(
+		sink,
+		services.WithCrawler(services.CrawlerConfig{
+			FetchWorkers: cfg.Crawler.FetchWorkers,
+			Archive: archive.NewLocal(archive.Config{
+				Root:   cfg.Archive.Path,
+				Logger: logger,
+			}),
+			Replay: true,
+			Logger: logger,
+		}),
+	)
+	handler := handlers.New(dataService, logger)
+
+	err := handler.Replay(ctx, req)
+
+	if stopErr := dataService.StopKafka(); stopErr != nil {
+		logger.Error().Err(stopErr).Msg("server.replay: stop_kafka failed")
+	}
+
+	if err != nil {
+		return fmt.Errorf("server.replay: failed, reason: %w", err)
+	}
+
+	return nil
+}
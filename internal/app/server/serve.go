@@ -16,26 +16,43 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/heptiolabs/healthcheck"
+	cron "github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
 	config "github.com/samwang0723/stock-crawler/configs"
+	"github.com/samwang0723/stock-crawler/internal/app/crawler"
 	"github.com/samwang0723/stock-crawler/internal/app/dto"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/handlers"
 	"github.com/samwang0723/stock-crawler/internal/app/services"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+	"github.com/samwang0723/stock-crawler/internal/cache"
 	"github.com/samwang0723/stock-crawler/internal/helper"
 )
 
 const (
 	gracefulShutdownPeriod = 5 * time.Second
 	readHeaderTimeout      = 10 * time.Second
+	// schedules managed at runtime are reloaded from Redis every interval
+	scheduleSyncInterval = time.Minute
+	// the stake concentrations timed out are published partially every interval
+	aggregationExpireInterval = time.Minute
+	// instances look for the work queues of other instances every interval
+	workQueueJoinInterval = 10 * time.Second
+	// another instance takes over the scheduling once the leader failed to renew its lease
+	leaderLease = 15 * time.Second
 )
 
+var errScheduleTypesMissing = errors.New("schedule types are not configured")
+
 type IServer interface {
 	Name() string
 	Handler() handlers.IHandler
@@ -52,6 +69,28 @@ type server struct {
 func Serve(ctx context.Context, logger *zerolog.Logger) error {
 	config.Load()
 	cfg := config.GetCurrentConfig()
+
+	schedules, err := cronSchedules(cfg)
+	if err != nil {
+		return fmt.Errorf("server.serve: failed, reason: %w", err)
+	}
+
+	// raw responses are archived only if the archive folder is configured
+	var rawArchive archive.Archive
+	if cfg.Archive.Path != "" {
+		rawArchive = archive.NewLocal(archive.Config{
+			Root:   cfg.Archive.Path,
+			Logger: logger,
+		})
+	}
+
+	rateLimits, proxySources := sourceSettings(cfg, logger)
+
+	proxyPool, err := newProxyPool(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("server.serve: failed, reason: %w", err)
+	}
+
 	// bind DAL layer with service
 	dataService := services.New(
 		services.WithCronJob(services.CronjobConfig{
@@ -71,14 +110,45 @@ func Serve(ctx context.Context, logger *zerolog.Logger) error {
 			Password:      cfg.RedisCache.Password,
 		}),
 		services.WithCrawler(services.CrawlerConfig{
-			FetchWorkers:      cfg.Crawler.FetchWorkers,
-			RateLimitInterval: cfg.Crawler.RateLimit,
-			Proxy:             nil,
-			Logger:            logger,
+			FetchWorkers:       cfg.Crawler.FetchWorkers,
+			RateLimitInterval:  cfg.Crawler.RateLimit,
+			RateLimits:         rateLimits,
+			CircuitThreshold:   cfg.Crawler.Circuit.Threshold,
+			CircuitCoolDown:    time.Duration(cfg.Crawler.Circuit.CoolDown) * time.Second,
+			Proxy:              proxyPool,
+			ProxySources:       proxySources,
+			Archive:            rawArchive,
+			Aggregation:        cfg.Crawler.Aggregation.Store,
+			AggregationTimeout: time.Duration(cfg.Crawler.Aggregation.Timeout) * time.Second,
+			RetryMissingPages:  cfg.Crawler.Aggregation.RetryMissingPages,
+			Logger:             logger,
 		}),
 	)
+	// only the leader among the instances runs the cronjobs, a single instance
+	// without redis runs them once started
+	election, err := dataService.NewElection(cache.ElectionConfig{
+		Key:       cache.LeaderKey,
+		ID:        instanceID(),
+		Expire:    leaderLease,
+		OnElected: dataService.StartCron,
+		OnDemoted: dataService.StopCron,
+		Logger:    logger,
+	})
+	if err != nil {
+		logger.Warn().Err(err).Msg("server.serve: leader election disabled")
+	}
+
 	// associate service with handler
-	handler := handlers.New(dataService, logger)
+	handler := handlers.New(
+		dataService,
+		logger,
+		handlers.WithPublishRetry(publishRetry(cfg, logger)),
+		handlers.WithElection(election),
+		handlers.WithWorkQueue(handlers.WorkQueue{
+			Batch:      cfg.Crawler.WorkQueue.Batch,
+			Visibility: time.Duration(cfg.Crawler.WorkQueue.Visibility) * time.Second,
+		}),
+	)
 
 	// health check
 	health := healthcheck.NewHandler()
@@ -95,9 +165,13 @@ func Serve(ctx context.Context, logger *zerolog.Logger) error {
 		"upstream-kafka-dns",
 		healthcheck.DNSResolveCheck(cfg.Kafka.Controller, time.Duration(cfg.Server.DNSLatency)))
 
+	if cfg.Server.AdminToken == "" {
+		logger.Warn().Msg("server.serve: admin token not set, submitting and canceling jobs is refused")
+	}
+
 	healthServer := &http.Server{
 		Addr:              fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:           health,
+		Handler:           newHTTPHandler(handler, healthHandler(health, election), cfg.Server.AdminToken, logger),
 		ReadHeaderTimeout: readHeaderTimeout,
 	}
 
@@ -106,8 +180,13 @@ func Serve(ctx context.Context, logger *zerolog.Logger) error {
 		Config(cfg),
 		Handler(handler),
 		HealthCheck(healthServer),
+		Schedules(schedules),
+		Election(election),
+		Logger(logger),
 		BeforeStart(func() error {
-			dataService.StartCron()
+			if election == nil {
+				dataService.StartCron()
+			}
 
 			return nil
 		}),
@@ -138,12 +217,160 @@ func Serve(ctx context.Context, logger *zerolog.Logger) error {
 	return nil
 }
 
+// sourceSettings maps the configured source names onto the crawler pacing settings
+// and the sources requested through the proxy pool.
+func sourceSettings(
+	cfg *config.SystemConfig,
+	logger *zerolog.Logger,
+) (map[convert.Source]crawler.RateLimit, []convert.Source) {
+	limits := make(map[convert.Source]crawler.RateLimit, len(cfg.Crawler.Sources))
+	proxied := []convert.Source{}
+
+	for name, sourceCfg := range cfg.Crawler.Sources {
+		source, err := convert.ParseSource(name)
+		if err != nil {
+			logger.Warn().Err(err).Msgf("server.sourceSettings: skipped, reason: unknown source; name=%s;", name)
+
+			continue
+		}
+
+		limits[source] = crawler.RateLimit{
+			Rate:  sourceCfg.Rate,
+			Burst: sourceCfg.Burst,
+		}
+
+		if sourceCfg.Proxy {
+			proxied = append(proxied, source)
+		}
+	}
+
+	return limits, proxied
+}
+
+// healthHandler reports the leader on the health checks when elected.
+func healthHandler(health http.Handler, election *cache.Election) http.Handler {
+	if election == nil {
+		return health
+	}
+
+	return withLeader(health, election)
+}
+
+// instanceID identifies the instance in the leader election, the pod name in the cluster.
+func instanceID() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return strings.ReplaceAll(hostname, " ", "-")
+	}
+
+	return fmt.Sprintf("stock-crawler-%d", os.Getpid())
+}
+
+// publishRetry maps the deadlines of the configured sources, invalid deadlines are skipped.
+func publishRetry(cfg *config.SystemConfig, logger *zerolog.Logger) handlers.PublishRetry {
+	deadlines := make(map[convert.Source]time.Duration)
+
+	for name, sourceCfg := range cfg.Crawler.Sources {
+		if sourceCfg.Deadline == "" {
+			continue
+		}
+
+		source, err := convert.ParseSource(name)
+		if err != nil {
+			continue
+		}
+
+		deadline, err := time.Parse("15:04", sourceCfg.Deadline)
+		if err != nil {
+			logger.Warn().Err(err).Msgf("server.publishRetry: skipped, reason: invalid deadline; name=%s;", name)
+
+			continue
+		}
+
+		deadlines[source] = time.Duration(deadline.Hour())*time.Hour + time.Duration(deadline.Minute())*time.Minute
+	}
+
+	return handlers.PublishRetry{
+		Deadlines:  deadlines,
+		Backoff:    time.Duration(cfg.Crawler.PublishRetry.Backoff) * time.Second,
+		MaxBackoff: time.Duration(cfg.Crawler.PublishRetry.MaxBackoff) * time.Second,
+	}
+}
+
+// cronSchedules maps the configured schedules onto the cronjob requests, an invalid
+// spec or an unknown source fails the startup rather than silently never running.
+func cronSchedules(cfg *config.SystemConfig) ([]*dto.StartCronjobRequest, error) {
+	schedules := make([]*dto.StartCronjobRequest, 0, len(cfg.Schedules))
+
+	for _, scheduleCfg := range cfg.Schedules {
+		if _, err := cron.ParseStandard(scheduleCfg.Spec); err != nil {
+			return nil, fmt.Errorf("server.cronSchedules: failed, spec=%s; reason: %w", scheduleCfg.Spec, err)
+		}
+
+		if len(scheduleCfg.Types) == 0 {
+			return nil, fmt.Errorf("server.cronSchedules: failed, spec=%s; reason: %w", scheduleCfg.Spec, errScheduleTypesMissing)
+		}
+
+		req := &dto.StartCronjobRequest{
+			Schedule: scheduleCfg.Spec,
+			Rewind:   scheduleCfg.Rewind,
+		}
+
+		for _, name := range scheduleCfg.Types {
+			source, err := convert.ParseSource(name)
+			if err != nil {
+				return nil, fmt.Errorf("server.cronSchedules: failed, spec=%s; reason: %w", scheduleCfg.Spec, err)
+			}
+
+			req.Types = append(req.Types, source)
+		}
+
+		schedules = append(schedules, req)
+	}
+
+	return schedules, nil
+}
+
+// newProxyPool returns nil if no proxy is configured.
+func newProxyPool(cfg *config.SystemConfig, logger *zerolog.Logger) (*crawler.ProxyPool, error) {
+	if len(cfg.Proxy.Proxies) == 0 {
+		return nil, nil //nolint:nolintlint, nilnil
+	}
+
+	proxies := make([]crawler.Proxy, 0, len(cfg.Proxy.Proxies))
+	for _, proxyCfg := range cfg.Proxy.Proxies {
+		proxies = append(proxies, crawler.Proxy{
+			Type:    proxyCfg.Type,
+			Address: proxyCfg.Address,
+			Weight:  proxyCfg.Weight,
+		})
+	}
+
+	pool, err := crawler.NewProxyPool(crawler.ProxyPoolConfig{
+		Logger:       logger,
+		Selection:    cfg.Proxy.Selection,
+		Proxies:      proxies,
+		MinRequests:  cfg.Proxy.MinRequests,
+		MaxErrorRate: cfg.Proxy.MaxErrorRate,
+		EvictPeriod:  time.Duration(cfg.Proxy.EvictPeriod) * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("server.newProxyPool: failed, reason: %w", err)
+	}
+
+	return pool, nil
+}
+
 func newServer(opts ...Option) IServer {
 	option := Options{}
 	for _, opt := range opts {
 		opt(&option)
 	}
 
+	if option.Logger == nil {
+		nop := zerolog.Nop()
+		option.Logger = &nop
+	}
+
 	return &server{
 		opts: option,
 	}
@@ -198,36 +425,121 @@ func (s *server) Run(ctx context.Context) error {
 	// Execute logics
 	var waitGroup sync.WaitGroup
 
+	// the cronjobs are started once elected, the leader resigns when stopped
+	if s.opts.Election != nil {
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+
+			s.opts.Election.Run(ctx)
+		}()
+	}
+
 	waitGroup.Add(1)
 
 	go func(ctx context.Context, svc *server) {
 		defer waitGroup.Done()
 
-		// by default starting cronjob for regular daily updates pulling
-		// cronjob using redis distrubted lock to prevent multiple instances
-		// pulling same content
+		// cronjobs of the configured schedules, using redis distrubted lock to
+		// prevent multiple instances pulling same content
+		for _, schedule := range svc.opts.Schedules {
+			//nolint:nolintlint, errcheck
+			svc.Handler().CronDownload(ctx, schedule)
+		}
 
+		// schedules managed at runtime, possibly through another instance
 		//nolint:nolintlint, errcheck
-		svc.Handler().CronDownload(ctx, &dto.StartCronjobRequest{
-			Schedule: "30 08 * * 1-5",
-			Types: []convert.Source{
-				convert.TpexStockList,
-				convert.TwseStockList,
-			},
-		})
+		svc.Handler().SyncSchedules(ctx)
+
+		go func() {
+			ticker := time.NewTicker(scheduleSyncInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					//nolint:nolintlint, errcheck
+					svc.Handler().SyncSchedules(ctx)
+				}
+			}
+		}()
+
+		// publish the stake concentrations timed out apart from the jobs
+		go func() {
+			ticker := time.NewTicker(aggregationExpireInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					//nolint:nolintlint, errcheck
+					svc.Handler().ExpireAggregations(ctx)
+				}
+			}
+		}()
+
+		// help with the per-stock downloads of the jobs run by other instances
+		go func() {
+			ticker := time.NewTicker(workQueueJoinInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					//nolint:nolintlint, errcheck
+					svc.Handler().JoinWorkQueues(ctx)
+				}
+			}
+		}()
+
+		// continue the date range downloads interrupted by a stopped instance
+		if err := svc.Handler().ResumeBackfills(ctx); err != nil {
+			svc.opts.Logger.Error().Err(err).Msg("server.run: failed, reason: resume backfills failed")
+		}
 
 		requestChan := make(chan *dto.StartCronjobRequest)
 		svc.Handler().ListeningDownloadRequest(ctx, requestChan)
 
+		// counted until the downloads it started are counted themselves
+		waitGroup.Add(1)
+
 		go func() {
+			defer waitGroup.Done()
+
 			for {
 				select {
 				case <-ctx.Done():
 					return
 				case req, ok := <-requestChan:
-					if ok {
-						svc.Handler().Download(ctx, req)
+					if !ok {
+						continue
+					}
+
+					if req.Action != dto.ActionDownload {
+						if _, err := svc.Handler().ManageSchedule(ctx, req); err != nil {
+							svc.opts.Logger.Error().Err(err).Msgf("server.run: failed, action=%s;", req.Action)
+						}
+
+						continue
 					}
+
+					// run in background like the jobs submitted through the admin api, a download
+					// awaiting the publication of its sources must not hold back the next requests,
+					// while the shutdown still waits for the canceled downloads to record their jobs
+					waitGroup.Add(1)
+
+					go func(req *dto.StartCronjobRequest) {
+						defer waitGroup.Done()
+
+						svc.Handler().Download(ctx, req)
+					}(req)
 				}
 			}
 		}()
@@ -0,0 +1,104 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	config "github.com/samwang0723/stock-crawler/configs"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/app/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCronSchedules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		schedules []config.ScheduleConfig
+		want      []*dto.StartCronjobRequest
+		wantErr   bool
+	}{
+		{
+			name: "valid schedules",
+			schedules: []config.ScheduleConfig{
+				{Spec: "00 15 * * 1-5", Types: []string{"TwseDailyClose", "TpexDailyClose"}},
+				{Spec: "00 07 1 * *", Types: []string{"TwseHolidaySchedule"}, Rewind: 1},
+			},
+			want: []*dto.StartCronjobRequest{
+				{Schedule: "00 15 * * 1-5", Types: []convert.Source{convert.TwseDailyClose, convert.TpexDailyClose}},
+				{Schedule: "00 07 1 * *", Types: []convert.Source{convert.TwseHolidaySchedule}, Rewind: 1},
+			},
+		},
+		{
+			name:      "invalid spec",
+			schedules: []config.ScheduleConfig{{Spec: "invalid", Types: []string{"TwseDailyClose"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown source",
+			schedules: []config.ScheduleConfig{{Spec: "00 15 * * 1-5", Types: []string{"TwseDailyClose", "Unknown"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "without source",
+			schedules: []config.ScheduleConfig{{Spec: "00 15 * * 1-5"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schedules, err := cronSchedules(&config.SystemConfig{Schedules: tt.schedules})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cronSchedules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.want, schedules)
+		})
+	}
+}
+
+func TestPublishRetry(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "server").Logger()
+
+	cfg := &config.SystemConfig{}
+	cfg.Crawler.Sources = map[string]config.SourceConfig{
+		"TwseThreePrimary":   {Deadline: "20:00"},
+		"TwseMarginTrade":    {Deadline: "23:30"},
+		"TwseDailyClose":     {Deadline: "25:00"},
+		"StakeConcentration": {Rate: 1},
+		"Unknown":            {Deadline: "20:00"},
+	}
+	cfg.Crawler.PublishRetry = config.PublishRetryConfig{Backoff: 300, MaxBackoff: 1800}
+
+	assert.Equal(t, handlers.PublishRetry{
+		Deadlines: map[convert.Source]time.Duration{
+			convert.TwseThreePrimary: 20 * time.Hour,
+			convert.TwseMarginTrade:  23*time.Hour + 30*time.Minute,
+		},
+		Backoff:    5 * time.Minute,
+		MaxBackoff: 30 * time.Minute,
+	}, publishRetry(cfg, &logger))
+}
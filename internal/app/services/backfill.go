@@ -0,0 +1,106 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"golang.org/x/xerrors"
+)
+
+const (
+	backfillKeyPrefix = "backfill:"
+	// backfillActiveKey is the set of the unfinished backfills
+	backfillActiveKey = "backfills"
+	backfillExpire    = 30 * 24 * time.Hour
+)
+
+// SaveBackfill persists the checkpoint, finished and abandoned backfills are no longer
+// listed as active.
+func (s *serviceImpl) SaveBackfill(ctx context.Context, backfill *dto.Backfill) error {
+	if s.cache == nil {
+		return nil
+	}
+
+	data, err := jsoni.Marshal(backfill)
+	if err != nil {
+		return xerrors.Errorf("service.saveBackfill: failed, reason: json marshal error %w", err)
+	}
+
+	if err = s.cache.Set(ctx, backfillKeyPrefix+backfill.Key, string(data), backfillExpire); err != nil {
+		return xerrors.Errorf("service.saveBackfill: failed, reason: %w", err)
+	}
+
+	if backfill.Finished || backfill.Abandoned {
+		err = s.cache.SRem(ctx, backfillActiveKey, backfill.Key)
+	} else {
+		err = s.cache.SAdd(ctx, backfillActiveKey, backfill.Key)
+	}
+
+	if err != nil {
+		return xerrors.Errorf("service.saveBackfill: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// GetBackfill returns the checkpoint, wrapping cache.ErrCacheMiss if unknown.
+func (s *serviceImpl) GetBackfill(ctx context.Context, key string) (*dto.Backfill, error) {
+	if s.cache == nil {
+		return nil, xerrors.Errorf("service.getBackfill: failed, key=%s; reason: %w", key, cache.ErrCacheMiss)
+	}
+
+	data, err := s.cache.Get(ctx, backfillKeyPrefix+key)
+	if err != nil {
+		return nil, xerrors.Errorf("service.getBackfill: failed, key=%s; reason: %w", key, err)
+	}
+
+	backfill := &dto.Backfill{}
+	if err = jsoni.UnmarshalFromString(data, backfill); err != nil {
+		return nil, xerrors.Errorf("service.getBackfill: failed, reason: json unmarshal error %w", err)
+	}
+
+	return backfill, nil
+}
+
+// ListBackfills returns the checkpoints of the unfinished backfills.
+func (s *serviceImpl) ListBackfills(ctx context.Context) ([]*dto.Backfill, error) {
+	if s.cache == nil {
+		return []*dto.Backfill{}, nil
+	}
+
+	keys, err := s.cache.SMembers(ctx, backfillActiveKey)
+	if err != nil {
+		return nil, xerrors.Errorf("service.listBackfills: failed, reason: %w", err)
+	}
+
+	backfills := make([]*dto.Backfill, 0, len(keys))
+
+	for _, key := range keys {
+		backfill, err := s.GetBackfill(ctx, key)
+		if errors.Is(err, cache.ErrCacheMiss) {
+			continue
+		} else if err != nil {
+			return nil, xerrors.Errorf("service.listBackfills: failed, reason: %w", err)
+		}
+
+		backfills = append(backfills, backfill)
+	}
+
+	return backfills, nil
+}
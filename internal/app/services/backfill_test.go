@@ -0,0 +1,77 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
+)
+
+func TestSaveBackfill(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		finished  bool
+		abandoned bool
+	}{
+		{
+			name:     "running backfill is listed as active",
+			finished: false,
+		},
+		{
+			name:     "finished backfill is removed from the active ones",
+			finished: true,
+		},
+		{
+			name:      "abandoned backfill is removed from the active ones",
+			abandoned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			mockCtl := gomock.NewController(t)
+			defer mockCtl.Finish()
+
+			mockRedis := cache.NewMockRedis(mockCtl)
+			mockRedis.EXPECT().Set(ctx, "backfill:key", gomock.Any(), backfillExpire).Return(nil).Times(1)
+
+			if tt.finished || tt.abandoned {
+				mockRedis.EXPECT().SRem(ctx, backfillActiveKey, "key").Return(nil).Times(1)
+			} else {
+				mockRedis.EXPECT().SAdd(ctx, backfillActiveKey, "key").Return(nil).Times(1)
+			}
+
+			svc := &serviceImpl{
+				cache: mockRedis,
+			}
+
+			err := svc.SaveBackfill(ctx, &dto.Backfill{Key: "key", Finished: tt.finished, Abandoned: tt.abandoned})
+			if err != nil {
+				t.Errorf("service SaveBackfill() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"golang.org/x/xerrors"
+)
+
+const (
+	checkpointKeyPrefix = "checkpoint:"
+	// the units are downloaded again once the checkpoint expires
+	checkpointExpire = 24 * time.Hour
+)
+
+// checkpointKey identifies the units done of the source on the date, the date is
+// unified as 20220107 whatever the format of the source.
+func checkpointKey(source convert.Source, date string) string {
+	return checkpointKeyPrefix + source.String() + ":" + strings.NewReplacer("-", "", "/", "").Replace(date)
+}
+
+// Checkpoint returns the units of work of the source done on the date, the stocks of
+// the per-stock sources and the links of the others.
+func (s *serviceImpl) Checkpoint(ctx context.Context, source convert.Source, date string) (map[string]bool, error) {
+	// offline replays run without redis
+	if s.cache == nil {
+		return map[string]bool{}, nil
+	}
+
+	units, err := s.cache.SMembers(ctx, checkpointKey(source, date))
+	if err != nil {
+		return nil, xerrors.Errorf("service.checkpoint: failed, reason: %w", err)
+	}
+
+	done := make(map[string]bool, len(units))
+	for _, unit := range units {
+		done[unit] = true
+	}
+
+	return done, nil
+}
+
+// MarkCheckpoint records the units of work of the source done on the date.
+func (s *serviceImpl) MarkCheckpoint(ctx context.Context, source convert.Source, date string, units []string) error {
+	if s.cache == nil || len(units) == 0 {
+		return nil
+	}
+
+	key := checkpointKey(source, date)
+
+	for _, unit := range units {
+		if err := s.cache.SAdd(ctx, key, unit); err != nil {
+			return xerrors.Errorf("service.markCheckpoint: failed, reason: %w", err)
+		}
+	}
+
+	if err := s.cache.SetExpire(ctx, key, time.Now().Add(checkpointExpire)); err != nil {
+		return xerrors.Errorf("service.markCheckpoint: failed, reason: %w", err)
+	}
+
+	return nil
+}
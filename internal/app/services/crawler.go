@@ -16,11 +16,20 @@ package services
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/samwang0723/stock-crawler/internal/app/crawler"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/archive"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
+	"golang.org/x/xerrors"
+)
+
+const (
+	AggregationMemory = "memory"
+	AggregationRedis  = "redis"
 )
 
 // Config encapsulates the settings for configuring the web-crawler service.
@@ -35,8 +44,31 @@ type CrawlerConfig struct {
 	// The time between subsequent crawler passes.
 	RateLimitInterval int64
 
-	// Proxy for preventing remote site's rate limiting
-	Proxy *crawler.Proxy
+	// Pace of the hosts serving the sources, overriding RateLimitInterval.
+	RateLimits map[convert.Source]crawler.RateLimit
+
+	// Consecutive failures of a host opening its circuit breaker, and how long
+	// the host fails fast before probing. Disabled if the threshold is not set.
+	CircuitThreshold int
+	CircuitCoolDown  time.Duration
+
+	// Proxy for preventing remote site's rate limiting, only used by ProxySources
+	Proxy        *crawler.ProxyPool
+	ProxySources []convert.Source
+
+	// Archive for keeping raw responses, archiving is disabled if not specified.
+	Archive archive.Archive
+
+	// Replay serves the archived responses instead of fetching from the network.
+	Replay bool
+
+	// Aggregation keeps the period pages of the stake concentrations, either in
+	// AggregationMemory or in AggregationRedis, which requires redis. Incomplete stake
+	// concentrations are published partially after AggregationTimeout, once their
+	// missing pages were downloaded again if RetryMissingPages is set.
+	Aggregation        string
+	AggregationTimeout time.Duration
+	RetryMissingPages  bool
 
 	// The logger to use. If not defined an output-discarding logger will
 	// be used instead.
@@ -48,6 +80,14 @@ func (cfg *CrawlerConfig) validate() error {
 		cfg.URLGetter = crawler.DefaultHTTPClient
 	}
 
+	if cfg.Replay {
+		if cfg.Archive == nil {
+			return ErrArchiveMissing
+		}
+
+		cfg.URLGetter = crawler.NewReplayGetter(cfg.Archive)
+	}
+
 	if cfg.FetchWorkers <= 0 {
 		return ErrWorkerCountInvalid
 	}
@@ -56,9 +96,48 @@ func (cfg *CrawlerConfig) validate() error {
 		return ErrRateLimitIntervalInvalid
 	}
 
+	if cfg.CircuitThreshold < 0 || cfg.CircuitCoolDown < 0 {
+		return ErrCircuitInvalid
+	}
+
+	switch cfg.Aggregation {
+	case "":
+		cfg.Aggregation = AggregationMemory
+	case AggregationMemory, AggregationRedis:
+	default:
+		return ErrAggregationInvalid
+	}
+
+	for _, limit := range cfg.RateLimits {
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return ErrRateLimitInvalid
+		}
+	}
+
 	return nil
 }
 
+// ListArchivedURLs returns the urls archived for the source and date.
+func (s *serviceImpl) ListArchivedURLs(ctx context.Context, source convert.Source, date string) ([]string, error) {
+	if s.archive == nil {
+		return nil, xerrors.Errorf("service.listArchivedURLs: failed, reason: %w", ErrArchiveMissing)
+	}
+
+	key, unifiedDate := crawler.ArchiveKey(source, date)
+
+	entries, err := s.archive.List(ctx, key, unifiedDate)
+	if err != nil {
+		return nil, xerrors.Errorf("service.listArchivedURLs: failed, reason: %w", err)
+	}
+
+	urls := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		urls = append(urls, entry.URL)
+	}
+
+	return urls, nil
+}
+
 func (s *serviceImpl) Crawl(
 	ctx context.Context,
 	linkIt graph.LinkIterator,
@@ -71,3 +150,21 @@ func (s *serviceImpl) Crawl(
 
 	return count, nil
 }
+
+// ExpireAggregations sends the stake concentrations timed out into the intercept channel.
+func (s *serviceImpl) ExpireAggregations(ctx context.Context, interceptChan chan convert.InterceptData) error {
+	if err := s.crawler.ExpireAggregations(ctx, interceptChan); err != nil {
+		return fmt.Errorf("service.expireAggregations: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// Circuits returns the circuit breaker state of the remote hosts.
+func (s *serviceImpl) Circuits() []circuit.Snapshot {
+	if s.crawler == nil {
+		return nil
+	}
+
+	return s.crawler.Circuits()
+}
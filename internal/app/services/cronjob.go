@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 
+	cron "github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
 )
 
@@ -36,11 +37,20 @@ func (s *serviceImpl) StopCron() {
 	s.cronjob.Stop()
 }
 
-func (s *serviceImpl) AddJob(ctx context.Context, spec string, job func()) error {
-	err := s.cronjob.AddJob(ctx, spec, job)
+func (s *serviceImpl) AddJob(ctx context.Context, spec string, job func()) (cron.EntryID, error) {
+	id, err := s.cronjob.AddJob(ctx, spec, job)
 	if err != nil {
-		return fmt.Errorf("service.cron: add_job_failed, reason: %w", err)
+		return 0, fmt.Errorf("service.cron: add_job_failed, reason: %w", err)
 	}
 
-	return nil
+	return id, nil
+}
+
+func (s *serviceImpl) RemoveJob(id cron.EntryID) {
+	s.cronjob.Remove(id)
+}
+
+// JobEntry returns the next and previous run times of the cronjob.
+func (s *serviceImpl) JobEntry(id cron.EntryID) cron.Entry {
+	return s.cronjob.Entry(id)
 }
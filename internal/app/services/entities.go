@@ -15,216 +15,100 @@ package services
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"os"
 	"reflect"
-	"strings"
-	"time"
 
 	jsoniter "github.com/json-iterator/go"
-	"github.com/samwang0723/stock-crawler/internal/app/crawler"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
 	"github.com/samwang0723/stock-crawler/internal/app/entity"
-	"github.com/samwang0723/stock-crawler/internal/helper"
-	"github.com/samwang0723/stock-crawler/internal/kafka"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"golang.org/x/xerrors"
 )
 
 const (
-	sourceStockList    = "./configs/stock_ids.json"
-	defaultCacheExpire = 6 * time.Hour
+	sourceStockList = "./configs/stock_ids.json"
 )
 
 //nolint:nolintlint, gochecknoglobals
 var jsoni = jsoniter.ConfigCompatibleWithStandardLibrary
 
-func (s *serviceImpl) DailyCloseThroughKafka(ctx context.Context, objs *[]any) error {
-	for _, val := range *objs {
-		if res, ok := val.(*entity.DailyClose); ok {
-			b, err := jsoni.Marshal(res)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.dailyCloseThroughKafka: failed, reason: json marshal error %w",
-					err,
-				)
-			}
+func (s *serviceImpl) SendThroughKafka(ctx context.Context, source convert.Source, objs *[]any) error {
+	def, err := convert.Lookup(source)
+	if err != nil {
+		return xerrors.Errorf("service.sendThroughKafka: failed, reason: %w", err)
+	}
 
-			err = s.sendKafka(ctx, kafka.DailyClosesV1, b)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.dailyCloseThroughKafka: failed, reason: send kafka error %w",
-					err,
-				)
-			}
-		} else {
+	for _, val := range *objs {
+		if reflect.TypeOf(val) != def.Entity {
 			return xerrors.Errorf(
-				"service.dailyCloseThroughKafka: failed, reason: interface casting error %v",
+				"service.sendThroughKafka: failed, reason: interface casting error %v, source=%v;",
 				reflect.TypeOf(val).Elem(),
+				source,
 			)
 		}
-	}
-
-	return nil
-}
-
-func (s *serviceImpl) StockThroughKafka(ctx context.Context, objs *[]any) error {
-	for _, val := range *objs {
-		if res, ok := val.(*entity.Stock); ok {
-			b, err := jsoni.Marshal(res)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.stockThroughKafka: failed, reason: json marshal error %w",
-					err,
-				)
-			}
 
-			err = s.sendKafka(ctx, kafka.StocksV1, b)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.stockThroughKafka: failed, reason: send kafka error %w",
-					err,
-				)
-			}
-		} else {
+		b, err := jsoni.Marshal(val)
+		if err != nil {
 			return xerrors.Errorf(
-				"service.stockThroughKafka: failed, reason: interface casting error %v",
-				reflect.TypeOf(val).Elem(),
+				"service.sendThroughKafka: failed, reason: json marshal error %w",
+				err,
 			)
 		}
-	}
 
-	return nil
-}
-
-func (s *serviceImpl) ThreePrimaryThroughKafka(ctx context.Context, objs *[]any) error {
-	for _, val := range *objs {
-		if res, ok := val.(*entity.ThreePrimary); ok {
-			b, err := jsoni.Marshal(res)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.threePrimaryThroughKafka: failed, reason: json marshal error %w",
-					err,
-				)
-			}
-
-			err = s.sendKafka(ctx, kafka.ThreePrimaryV1, b)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.threePrimaryThroughKafka: failed, send kafka error %w",
-					err,
-				)
-			}
-		} else {
+		err = s.sendKafka(ctx, def.Topic, b)
+		if err != nil {
 			return xerrors.Errorf(
-				"service.threePrimaryThroughKafka: failed, reason: interface casting error %v",
-				reflect.TypeOf(val).Elem(),
+				"service.sendThroughKafka: failed, reason: send kafka error %w",
+				err,
 			)
 		}
-	}
-
-	return nil
-}
 
-func (s *serviceImpl) StakeConcentrationThroughKafka(
-	ctx context.Context,
-	objs *[]any,
-) error {
-	for _, val := range *objs {
 		if res, ok := val.(*entity.StakeConcentration); ok {
-			b, err := jsoni.Marshal(res)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.stakeConcentrationThroughKafka: failed, reason: json marshal error %w",
-					err,
-				)
-			}
-
-			err = s.sendKafka(ctx, kafka.StakeConcentrationV1, b)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.stakeConcentrationThroughKafka: failed, reason: send kafka error %w",
-					err,
-				)
-			}
-
-			// record parsed records to prevent duplicate parsing, default expire the key after 6 hours
-			err = s.cacheParsedConcentration(ctx, res.Date, res.StockID)
-			if err != nil {
-				return xerrors.Errorf(
-					"service.stakeConcentrationThroughKafka: failed, reason: cache parsed stock_id error %w",
-					err,
-				)
-			}
-
 			res.Recycle()
-		} else {
-			return xerrors.Errorf(
-				"service.stakeConcentrationThroughKafka: failed, reason: interface casting error: %v",
-				reflect.TypeOf(val).Elem(),
-			)
 		}
 	}
 
 	return nil
 }
 
-func (s *serviceImpl) cacheParsedConcentration(ctx context.Context, date, stockID string) error {
-	key := strings.ReplaceAll(date, "-", "")
-
-	err := s.cache.SAdd(ctx, key, stockID)
-	if err != nil {
-		return xerrors.Errorf(
-			"service.cacheParsedConcentration: failed, reason: cache sadd error %w",
-			err,
-		)
+// ListStocks returns the stocks to download by the per-stock sources, the given stocks
+// or the listed stocks of the universe matching the filter. The static stock list is
+// taken until the stock lists are crawled, the filters can not apply to it and are
+// rejected with ErrUniverseMissing.
+func (s *serviceImpl) ListStocks(ctx context.Context, filter *dto.StockFilter) ([]string, error) {
+	if len(filter.StockIDs) > 0 {
+		return filter.StockIDs, nil
 	}
 
-	err = s.cache.SetExpire(ctx, key, time.Now().Add(defaultCacheExpire))
-	if err != nil {
-		return xerrors.Errorf(
-			"service.cacheParsedConcentration: failed, reason: cache set_expire error %w",
-			err,
-		)
-	}
+	if s.cache != nil {
+		universe, err := s.universe(ctx)
+		if err != nil {
+			return nil, xerrors.Errorf("service.listStocks: failed, reason: %w", err)
+		}
 
-	return nil
-}
+		if len(universe) > 0 {
+			stocks := listed(universe, filter)
+			stockIDs := make([]string, 0, len(stocks))
 
-func (s *serviceImpl) ListCrawlingConcentrationURLs(
-	ctx context.Context,
-	date string,
-) ([]string, error) {
-	defaultList, err := listStocks()
-	if err != nil {
-		return nil, xerrors.Errorf(
-			"service.listCrawlingConcentrationURLs: failed, reason: list_stocks error %w",
-			err,
-		)
-	}
+			for _, stock := range stocks {
+				stockIDs = append(stockIDs, stock.StockID)
+			}
 
-	res, err := s.cache.SMembers(ctx, date)
-	if err != nil {
-		return nil, xerrors.Errorf(
-			"service.listCrawlingConcentrationURLs: failed, reason: redis smembers listing cached stock ids error %w",
-			err,
-		)
+			return stockIDs, nil
+		}
 	}
 
-	var urls []string
-
-	stockIDs := helper.Diff(res, defaultList)
+	if len(filter.Markets) > 0 || len(filter.Categories) > 0 || len(filter.SecurityTypes) > 0 {
+		return nil, xerrors.Errorf("service.listStocks: failed, reason: %w", ErrUniverseMissing)
+	}
 
-	for _, sid := range stockIDs {
-		// in order to get accurate data, we must query each page
-		// https://stockchannelnew.sinotrade.com.tw/z/zc/zco/zco_6598_6.djhtm
-		// as the top 15 brokers may different from day to day and not possible to store all detailed daily data
-		indexes := []int{1, 2, 3, 4, 6}
-		for _, idx := range indexes {
-			urls = append(urls, fmt.Sprintf(crawler.ConcentrationDays, sid, idx))
-		}
+	list, err := listStocks()
+	if err != nil {
+		return nil, xerrors.Errorf("service.listStocks: failed, reason: %w", err)
 	}
 
-	return urls, nil
+	return list, nil
 }
 
 func listStocks() ([]string, error) {
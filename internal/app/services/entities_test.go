@@ -5,12 +5,14 @@ import (
 	"errors"
 	"flag"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
 	"github.com/samwang0723/stock-crawler/internal/kafka"
 	kafkamock "github.com/samwang0723/stock-crawler/internal/kafka/mocks"
@@ -124,7 +126,7 @@ func TestDailyCloseThroughKafka(t *testing.T) {
 				producer: mockKafka,
 			}
 
-			err := svc.DailyCloseThroughKafka(ctx, tt.args.data)
+			err := svc.SendThroughKafka(ctx, convert.TwseDailyClose, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("service DailyCloseThroughKafka() error = %v", err)
 			}
@@ -215,7 +217,7 @@ func TestStockThroughKafka(t *testing.T) {
 				producer: mockKafka,
 			}
 
-			err := svc.StockThroughKafka(ctx, tt.args.data)
+			err := svc.SendThroughKafka(ctx, convert.TwseStockList, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("service StockThroughKafka() error = %v", err)
 			}
@@ -307,7 +309,7 @@ func TestThreePrimaryThroughKafka(t *testing.T) {
 				producer: mockKafka,
 			}
 
-			err := svc.ThreePrimaryThroughKafka(ctx, tt.args.data)
+			err := svc.SendThroughKafka(ctx, convert.TwseThreePrimary, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("service ThreePrimaryThroughKafka() error = %v", err)
 			}
@@ -384,7 +386,6 @@ func TestStakeConcentrationThroughKafka(t *testing.T) {
 			defer mockCtl.Finish()
 
 			mockKafka := kafkamock.NewMockKafka(mockCtl)
-			mockRedis := cache.NewMockRedis(mockCtl)
 
 			for _, val := range *tt.args.data {
 				if res, ok := val.(*entity.StakeConcentration); ok {
@@ -399,23 +400,57 @@ func TestStakeConcentrationThroughKafka(t *testing.T) {
 						WriteMessages(ctx, kafka.StakeConcentrationV1, b).
 						Return(tt.args.expectReturn).
 						Times(1)
-					mockRedis.EXPECT().SAdd(ctx, res.Date, res.StockID).Return(nil).AnyTimes()
-					mockRedis.EXPECT().
-						SetExpire(ctx, res.Date, gomock.AssignableToTypeOf(time.Now())).
-						Return(nil).
-						AnyTimes()
 				}
 			}
 
 			svc := &serviceImpl{
 				producer: mockKafka,
-				cache:    mockRedis,
 			}
 
-			err := svc.StakeConcentrationThroughKafka(ctx, tt.args.data)
+			err := svc.SendThroughKafka(ctx, convert.StakeConcentration, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("service StakeConcentrationThroughKafka() error = %v", err)
 			}
 		})
 	}
 }
+
+func TestCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	mockRedis.EXPECT().SAdd(ctx, "checkpoint:StakeConcentration:20220801", "2330").Return(nil).Times(1)
+	mockRedis.EXPECT().SAdd(ctx, "checkpoint:StakeConcentration:20220801", "2317").Return(nil).Times(1)
+	mockRedis.EXPECT().
+		SetExpire(ctx, "checkpoint:StakeConcentration:20220801", gomock.AssignableToTypeOf(time.Now())).
+		Return(nil).
+		Times(1)
+	mockRedis.EXPECT().
+		SMembers(ctx, "checkpoint:StakeConcentration:20220801").
+		Return([]string{"2330", "2317"}, nil).
+		Times(1)
+
+	svc := &serviceImpl{
+		cache: mockRedis,
+	}
+
+	// the query date of the source and the unified date share the checkpoint
+	err := svc.MarkCheckpoint(ctx, convert.StakeConcentration, "2022-08-01", []string{"2330", "2317"})
+	if err != nil {
+		t.Fatalf("service MarkCheckpoint() error = %v", err)
+	}
+
+	done, err := svc.Checkpoint(ctx, convert.StakeConcentration, "20220801")
+	if err != nil {
+		t.Fatalf("service Checkpoint() error = %v", err)
+	}
+
+	if want := map[string]bool{"2330": true, "2317": true}; !reflect.DeepEqual(done, want) {
+		t.Errorf("service Checkpoint() = %v, want %v", done, want)
+	}
+}
@@ -5,4 +5,12 @@ import "errors"
 var (
 	ErrWorkerCountInvalid       = errors.New("invalid value for fetch workers")
 	ErrRateLimitIntervalInvalid = errors.New("invalid value for rate limit interval")
+	ErrRateLimitInvalid         = errors.New("invalid value for source rate limit")
+	ErrCircuitInvalid           = errors.New("invalid value for circuit breaker")
+	ErrArchiveMissing           = errors.New("raw response archive is not configured")
+	ErrAggregationInvalid       = errors.New("invalid value for aggregation store")
+	// ErrUniverseShrunk rejects a stock list missing half of its stocks, most likely truncated.
+	ErrUniverseShrunk = errors.New("stock list shrunk by half")
+	// ErrUniverseMissing rejects the stock filters until the stock lists are crawled.
+	ErrUniverseMissing = errors.New("stock universe not crawled yet")
 )
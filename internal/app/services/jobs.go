@@ -0,0 +1,106 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"golang.org/x/xerrors"
+)
+
+const (
+	jobKeyPrefix = "job:"
+	jobIndexKey  = "jobs"
+	jobExpire    = 7 * 24 * time.Hour
+	maxIndexJobs = 1000
+)
+
+// SaveJob persists the job record, records expire after a week.
+func (s *serviceImpl) SaveJob(ctx context.Context, job *dto.Job) error {
+	// offline replays run without redis
+	if s.cache == nil {
+		return nil
+	}
+
+	data, err := jsoni.Marshal(job)
+	if err != nil {
+		return xerrors.Errorf("service.saveJob: failed, reason: json marshal error %w", err)
+	}
+
+	if err = s.cache.Set(ctx, jobKeyPrefix+job.ID, string(data), jobExpire); err != nil {
+		return xerrors.Errorf("service.saveJob: failed, reason: %w", err)
+	}
+
+	if err = s.cache.ZAdd(ctx, jobIndexKey, float64(job.CreatedAt.UnixNano()), job.ID); err != nil {
+		return xerrors.Errorf("service.saveJob: failed, reason: %w", err)
+	}
+
+	// keep the index bounded, the oldest ids are dropped first
+	if err = s.cache.ZRemRangeByRank(ctx, jobIndexKey, 0, -maxIndexJobs-1); err != nil {
+		return xerrors.Errorf("service.saveJob: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// GetJob returns the persisted job record, wrapping cache.ErrCacheMiss if unknown.
+func (s *serviceImpl) GetJob(ctx context.Context, id string) (*dto.Job, error) {
+	if s.cache == nil {
+		return nil, xerrors.Errorf("service.getJob: failed, id=%s; reason: %w", id, cache.ErrCacheMiss)
+	}
+
+	data, err := s.cache.Get(ctx, jobKeyPrefix+id)
+	if err != nil {
+		return nil, xerrors.Errorf("service.getJob: failed, id=%s; reason: %w", id, err)
+	}
+
+	job := &dto.Job{}
+	if err = jsoni.UnmarshalFromString(data, job); err != nil {
+		return nil, xerrors.Errorf("service.getJob: failed, reason: json unmarshal error %w", err)
+	}
+
+	return job, nil
+}
+
+// ListJobs returns up to limit persisted jobs from the newest.
+func (s *serviceImpl) ListJobs(ctx context.Context, limit int) ([]*dto.Job, error) {
+	if s.cache == nil {
+		return []*dto.Job{}, nil
+	}
+
+	ids, err := s.cache.ZRevRange(ctx, jobIndexKey, 0, int64(limit-1))
+	if err != nil {
+		return nil, xerrors.Errorf("service.listJobs: failed, reason: %w", err)
+	}
+
+	jobs := make([]*dto.Job, 0, len(ids))
+
+	for _, id := range ids {
+		job, err := s.GetJob(ctx, id)
+		if errors.Is(err, cache.ErrCacheMiss) {
+			// expired record
+			continue
+		} else if err != nil {
+			return nil, xerrors.Errorf("service.listJobs: failed, reason: %w", err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
@@ -46,6 +46,23 @@ func (cfg *KafkaConfig) validate() error {
 	return nil
 }
 
+type FileSinkConfig struct {
+	// Path of the json lines file the messages are written into
+	Path string
+
+	// The logger to use. If not defined an output-discarding logger will
+	// be used instead.
+	Logger *zerolog.Logger
+}
+
+func (cfg *FileSinkConfig) validate() error {
+	if cfg.Path == "" {
+		return xerrors.Errorf("service.fileSink.validate: failed, reason: invalid file sink path")
+	}
+
+	return nil
+}
+
 //
 //nolint:nolintlint, cyclop
 func (s *serviceImpl) ListeningDownloadRequest(
@@ -19,6 +19,7 @@ import (
 	"github.com/samwang0723/stock-crawler/internal/cache"
 	"github.com/samwang0723/stock-crawler/internal/cronjob"
 	"github.com/samwang0723/stock-crawler/internal/kafka"
+	"golang.org/x/xerrors"
 )
 
 type Option func(o *serviceImpl)
@@ -48,6 +49,26 @@ func WithKafka(cfg KafkaConfig) Option {
 	}
 }
 
+// WithFileSink writes the messages into a json lines file instead of kafka. The file
+// is opened right away so an invalid path fails before anything is crawled.
+func WithFileSink(cfg FileSinkConfig) (Option, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	sink, err := kafka.NewFileSink(&kafka.FileConfig{
+		Path:   cfg.Path,
+		Logger: cfg.Logger,
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("service.WithFileSink: failed, reason: %w", err)
+	}
+
+	return func(i *serviceImpl) {
+		i.producer = sink
+	}, nil
+}
+
 func WithRedis(cfg RedisConfig) Option {
 	return func(i *serviceImpl) {
 		if err := cfg.validate(); err != nil {
@@ -69,12 +90,33 @@ func WithCrawler(cfg CrawlerConfig) Option {
 			return
 		}
 
+		rawArchive := cfg.Archive
+		if cfg.Replay {
+			// replayed responses are already archived
+			rawArchive = nil
+		}
+
+		// the redis store is only available once redis is configured
+		aggregation := crawler.NewMemoryAggregation()
+		if cfg.Aggregation == AggregationRedis && i.cache != nil {
+			aggregation = crawler.NewRedisAggregation(i.cache, cfg.Logger)
+		}
+
+		i.archive = cfg.Archive
 		i.crawler = crawler.New(crawler.Config{
-			URLGetter:         cfg.URLGetter,
-			FetchWorkers:      cfg.FetchWorkers,
-			RateLimitInterval: cfg.RateLimitInterval,
-			Proxy:             cfg.Proxy,
-			Logger:            cfg.Logger,
+			URLGetter:          cfg.URLGetter,
+			FetchWorkers:       cfg.FetchWorkers,
+			RateLimitInterval:  cfg.RateLimitInterval,
+			RateLimits:         cfg.RateLimits,
+			CircuitThreshold:   cfg.CircuitThreshold,
+			CircuitCoolDown:    cfg.CircuitCoolDown,
+			Proxy:              cfg.Proxy,
+			ProxySources:       cfg.ProxySources,
+			Archive:            rawArchive,
+			Aggregation:        aggregation,
+			AggregationTimeout: cfg.AggregationTimeout,
+			RetryMissingPages:  cfg.RetryMissingPages,
+			Logger:             cfg.Logger,
 		})
 	}
 }
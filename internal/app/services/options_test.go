@@ -0,0 +1,39 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFileSink(t *testing.T) {
+	t.Parallel()
+
+	_, err := WithFileSink(FileSinkConfig{})
+	assert.Error(t, err)
+
+	_, err = WithFileSink(FileSinkConfig{Path: filepath.Join(t.TempDir(), "missing", "replay.jsonl")})
+	assert.Error(t, err)
+
+	opt, err := WithFileSink(FileSinkConfig{Path: filepath.Join(t.TempDir(), "replay.jsonl")})
+	assert.NoError(t, err)
+
+	svc, ok := New(opt).(*serviceImpl)
+	assert.True(t, ok)
+	assert.NotNil(t, svc.producer)
+	assert.NoError(t, svc.StopKafka())
+}
@@ -17,8 +17,8 @@ import (
 	"context"
 	"time"
 
-	"github.com/bsm/redislock"
 	"github.com/rs/zerolog"
+	"github.com/samwang0723/stock-crawler/internal/cache"
 	"golang.org/x/xerrors"
 )
 
@@ -53,12 +53,26 @@ func (s *serviceImpl) ObtainLock(
 	ctx context.Context,
 	key string,
 	expire time.Duration,
-) *redislock.Lock {
+) (*cache.Lock, error) {
 	if s.cache == nil {
-		return nil
+		return nil, xerrors.Errorf("service.obtainLock: failed, reason: redis is not running")
 	}
 
-	return s.cache.ObtainLock(ctx, key, expire)
+	lock, err := s.cache.ObtainLock(ctx, key, expire)
+	if err != nil {
+		return nil, xerrors.Errorf("service.obtainLock: failed, reason: %w", err)
+	}
+
+	return lock, nil
+}
+
+// NewElection returns the leader election of the instance, run by the caller.
+func (s *serviceImpl) NewElection(cfg cache.ElectionConfig) (*cache.Election, error) {
+	if s.cache == nil {
+		return nil, xerrors.Errorf("service.newElection: failed, reason: redis is not running")
+	}
+
+	return cache.NewElection(s.cache, cfg), nil
 }
 
 func (s *serviceImpl) StopRedis() error {
@@ -0,0 +1,89 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/cache"
+	"golang.org/x/xerrors"
+)
+
+const (
+	scheduleKeyPrefix = "schedule:"
+	scheduleIndexKey  = "schedules"
+)
+
+// SaveSchedule persists the schedule without expiry, the run times are not persisted.
+func (s *serviceImpl) SaveSchedule(ctx context.Context, schedule *dto.Schedule) error {
+	record := *schedule
+	record.Next, record.Prev = nil, nil
+
+	data, err := jsoni.Marshal(&record)
+	if err != nil {
+		return xerrors.Errorf("service.saveSchedule: failed, reason: json marshal error %w", err)
+	}
+
+	if err = s.cache.Set(ctx, scheduleKeyPrefix+schedule.ID, string(data), 0); err != nil {
+		return xerrors.Errorf("service.saveSchedule: failed, reason: %w", err)
+	}
+
+	if err = s.cache.SAdd(ctx, scheduleIndexKey, schedule.ID); err != nil {
+		return xerrors.Errorf("service.saveSchedule: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+func (s *serviceImpl) DeleteSchedule(ctx context.Context, id string) error {
+	if err := s.cache.SRem(ctx, scheduleIndexKey, id); err != nil {
+		return xerrors.Errorf("service.deleteSchedule: failed, reason: %w", err)
+	}
+
+	if err := s.cache.Del(ctx, scheduleKeyPrefix+id); err != nil {
+		return xerrors.Errorf("service.deleteSchedule: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// ListSchedules returns the persisted schedules.
+func (s *serviceImpl) ListSchedules(ctx context.Context) ([]*dto.Schedule, error) {
+	ids, err := s.cache.SMembers(ctx, scheduleIndexKey)
+	if err != nil {
+		return nil, xerrors.Errorf("service.listSchedules: failed, reason: %w", err)
+	}
+
+	schedules := make([]*dto.Schedule, 0, len(ids))
+
+	for _, id := range ids {
+		data, err := s.cache.Get(ctx, scheduleKeyPrefix+id)
+		if errors.Is(err, cache.ErrCacheMiss) {
+			continue
+		} else if err != nil {
+			return nil, xerrors.Errorf("service.listSchedules: failed, reason: %w", err)
+		}
+
+		schedule := &dto.Schedule{}
+		if err = jsoni.UnmarshalFromString(data, schedule); err != nil {
+			return nil, xerrors.Errorf("service.listSchedules: failed, reason: json unmarshal error %w", err)
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	rediscache "github.com/samwang0723/stock-crawler/internal/cache"
+	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSchedules(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	mockRedis.EXPECT().SMembers(ctx, scheduleIndexKey).Return([]string{"a", "b"}, nil).Times(1)
+	mockRedis.EXPECT().Get(ctx, "schedule:a").
+		Return(`{"id":"a","request":{"schedule":"00 15 * * 1-6","types":["TwseDailyClose"]},"paused":true}`, nil).Times(1)
+	// deleted in between, the dangling id is skipped
+	mockRedis.EXPECT().Get(ctx, "schedule:b").Return("", rediscache.ErrCacheMiss).Times(1)
+
+	svc := &serviceImpl{
+		cache: mockRedis,
+	}
+
+	schedules, err := svc.ListSchedules(ctx)
+	if err != nil {
+		t.Fatalf("service ListSchedules() error = %v", err)
+	}
+
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, "a", schedules[0].ID)
+	assert.True(t, schedules[0].Paused)
+	assert.Equal(t, "00 15 * * 1-6", schedules[0].Request.Schedule)
+}
@@ -18,31 +18,58 @@ import (
 	"context"
 	"time"
 
-	"github.com/bsm/redislock"
+	cron "github.com/robfig/cron/v3"
 	"github.com/samwang0723/stock-crawler/internal/app/crawler"
 	"github.com/samwang0723/stock-crawler/internal/app/dto"
 	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
 	"github.com/samwang0723/stock-crawler/internal/app/graph"
+	"github.com/samwang0723/stock-crawler/internal/archive"
 	"github.com/samwang0723/stock-crawler/internal/cache"
+	"github.com/samwang0723/stock-crawler/internal/circuit"
 	"github.com/samwang0723/stock-crawler/internal/cronjob"
+	"github.com/samwang0723/stock-crawler/internal/helper"
 	"github.com/samwang0723/stock-crawler/internal/kafka"
 )
 
 type IService interface {
 	StartCron()
 	StopCron()
-	AddJob(ctx context.Context, spec string, job func()) error
-	DailyCloseThroughKafka(ctx context.Context, objs *[]any) error
-	StockThroughKafka(ctx context.Context, objs *[]any) error
-	ThreePrimaryThroughKafka(ctx context.Context, objs *[]any) error
-	StakeConcentrationThroughKafka(ctx context.Context, objs *[]any) error
-	ObtainLock(ctx context.Context, key string, expire time.Duration) *redislock.Lock
+	AddJob(ctx context.Context, spec string, job func()) (cron.EntryID, error)
+	RemoveJob(id cron.EntryID)
+	JobEntry(id cron.EntryID) cron.Entry
+	SendThroughKafka(ctx context.Context, source convert.Source, objs *[]any) error
+	ObtainLock(ctx context.Context, key string, expire time.Duration) (*cache.Lock, error)
+	NewElection(cfg cache.ElectionConfig) (*cache.Election, error)
 	StopRedis() error
 	StopKafka() error
-	ListCrawlingConcentrationURLs(ctx context.Context, date string) ([]string, error)
+	ListStocks(ctx context.Context, filter *dto.StockFilter) ([]string, error)
+	UpdateUniverse(ctx context.Context, source convert.Source, objs *[]any) error
+	ListUniverse(ctx context.Context, filter *dto.StockFilter) ([]*dto.ListedStock, error)
+	Checkpoint(ctx context.Context, source convert.Source, date string) (map[string]bool, error)
+	MarkCheckpoint(ctx context.Context, source convert.Source, date string, units []string) error
+	ListArchivedURLs(ctx context.Context, source convert.Source, date string) ([]string, error)
 	Crawl(ctx context.Context, linkIt graph.LinkIterator, interceptChan ...chan convert.InterceptData) (int, error)
-	IsHoliday(ctx context.Context, date string) bool
+	ExpireAggregations(ctx context.Context, interceptChan chan convert.InterceptData) error
+	Circuits() []circuit.Snapshot
+	TradingCalendar(ctx context.Context) (*helper.Calendar, error)
+	UpdateCalendar(ctx context.Context, objs *[]any) error
 	ListeningDownloadRequest(ctx context.Context, downloadChan chan *dto.StartCronjobRequest)
+	SaveJob(ctx context.Context, job *dto.Job) error
+	GetJob(ctx context.Context, id string) (*dto.Job, error)
+	ListJobs(ctx context.Context, limit int) ([]*dto.Job, error)
+	SaveBackfill(ctx context.Context, backfill *dto.Backfill) error
+	GetBackfill(ctx context.Context, key string) (*dto.Backfill, error)
+	ListBackfills(ctx context.Context) ([]*dto.Backfill, error)
+	SaveSchedule(ctx context.Context, schedule *dto.Schedule) error
+	DeleteSchedule(ctx context.Context, id string) error
+	ListSchedules(ctx context.Context) ([]*dto.Schedule, error)
+	EnqueueWork(ctx context.Context, queue string, items []string) error
+	ClaimWork(ctx context.Context, queue string, visibility time.Duration) (string, error)
+	AckWork(ctx context.Context, queue, item string) error
+	RequeueWork(ctx context.Context, queue, claimed, item string) error
+	WorkQueueLen(ctx context.Context, queue string) (int64, int64, error)
+	ListWorkQueues(ctx context.Context) ([]string, error)
+	DeleteWorkQueue(ctx context.Context, queue string) error
 }
 
 type serviceImpl struct {
@@ -50,6 +77,7 @@ type serviceImpl struct {
 	producer kafka.Kafka
 	cache    cache.Redis
 	crawler  crawler.Crawler
+	archive  archive.Archive
 }
 
 func New(opts ...Option) IService {
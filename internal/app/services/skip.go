@@ -16,19 +16,95 @@ package services
 import (
 	"context"
 
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
 	"github.com/samwang0723/stock-crawler/internal/helper"
-	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
 )
 
-const skipHeader = "skip_dates"
+const (
+	skipHeader = "skip_dates"
+	// makeUpHeader keeps the make-up trading Saturdays
+	makeUpHeader = "makeup_dates"
+)
+
+// UpdateCalendar replaces the market holidays and make-up trading days of the crawled
+// years, so the days no longer closed or traded are dropped, the other years are kept.
+func (s *serviceImpl) UpdateCalendar(ctx context.Context, objs *[]any) error {
+	if s.cache == nil {
+		return xerrors.Errorf("service.updateCalendar: failed, reason: redis is not running")
+	}
+
+	crawled := map[string][]string{}
+	years := map[string]bool{}
+
+	for _, val := range *objs {
+		holiday, ok := val.(*entity.Holiday)
+		if !ok {
+			return xerrors.Errorf("service.updateCalendar: failed, reason: interface casting error %T", val)
+		}
+
+		key := skipHeader
+		if holiday.Trading {
+			key = makeUpHeader
+		}
+
+		crawled[key] = append(crawled[key], holiday.Date)
+		years[calendarYear(holiday.Date)] = true
+	}
+
+	// an empty schedule tells no year to replace
+	if len(years) == 0 {
+		return nil
+	}
+
+	for _, key := range []string{skipHeader, makeUpHeader} {
+		dates, err := s.cache.SMembers(ctx, key)
+		if err != nil {
+			return xerrors.Errorf("service.updateCalendar: failed, reason: %w", err)
+		}
+
+		kept := crawled[key]
+
+		for _, date := range dates {
+			if !years[calendarYear(date)] {
+				kept = append(kept, date)
+			}
+		}
 
-func (s *serviceImpl) IsHoliday(ctx context.Context, date string) bool {
-	res, err := s.cache.SMembers(ctx, skipHeader)
+		if err = s.cache.ReplaceSet(ctx, key, kept); err != nil {
+			return xerrors.Errorf("service.updateCalendar: failed, reason: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// calendarYear returns the year of the date whatever its format.
+func calendarYear(date string) string {
+	unified := helper.UnifiedDateFormatToTwse(date)
+	if len(unified) < len("2006") {
+		return unified
+	}
+
+	return unified[:len("2006")]
+}
+
+// TradingCalendar returns the calendar with the holidays and make-up trading days
+// kept in redis, only weekends are closed if redis is not configured.
+func (s *serviceImpl) TradingCalendar(ctx context.Context) (*helper.Calendar, error) {
+	if s.cache == nil {
+		return helper.NewCalendar(nil, nil), nil
+	}
+
+	holidays, err := s.cache.SMembers(ctx, skipHeader)
 	if err != nil {
-		return false
+		return nil, xerrors.Errorf("service.tradingCalendar: failed, reason: %w", err)
 	}
 
-	unifiedDate := helper.UnifiedDateFormatToTwse(date)
+	makeUps, err := s.cache.SMembers(ctx, makeUpHeader)
+	if err != nil {
+		return nil, xerrors.Errorf("service.tradingCalendar: failed, reason: %w", err)
+	}
 
-	return slices.Contains(res, unifiedDate)
+	return helper.NewCalendar(holidays, makeUps), nil
 }
@@ -0,0 +1,51 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
+)
+
+func TestUpdateCalendar(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	// 20230102 of the previous schedule is kept, 20240410 is no longer closed
+	mockRedis.EXPECT().SMembers(ctx, skipHeader).Return([]string{"20230102", "20240410", "20240208"}, nil).Times(1)
+	mockRedis.EXPECT().ReplaceSet(ctx, skipHeader, []string{"20240208", "20240228", "20230102"}).Return(nil).Times(1)
+	mockRedis.EXPECT().SMembers(ctx, makeUpHeader).Return([]string{"20240217"}, nil).Times(1)
+	mockRedis.EXPECT().ReplaceSet(ctx, makeUpHeader, nil).Return(nil).Times(1)
+
+	svc := &serviceImpl{
+		cache: mockRedis,
+	}
+
+	err := svc.UpdateCalendar(ctx, &[]any{
+		&entity.Holiday{Date: "20240208", Name: "農曆春節"},
+		&entity.Holiday{Date: "20240228", Name: "和平紀念日"},
+	})
+	if err != nil {
+		t.Errorf("service UpdateCalendar() error = %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	"github.com/samwang0723/stock-crawler/internal/helper"
+	"github.com/samwang0723/stock-crawler/internal/kafka"
+	"golang.org/x/xerrors"
+)
+
+const (
+	universeKey = "stock-universe"
+	// universeCrawledKey keeps the last crawl time of each stock list
+	universeCrawledKey = "stock-universe:crawled"
+)
+
+// UpdateUniverse reconciles the stock universe with the stocks crawled from the list.
+// New stocks are listed and the changed ones updated, while the stocks missing from
+// their list are delisted once every list was crawled without them, so a stock moving
+// between the markets is not delisted whatever the order of the lists. The changes are
+// published before the universe is saved, so they are published again if saving fails,
+// the first crawl of a list does not publish its stocks as listed.
+func (s *serviceImpl) UpdateUniverse(ctx context.Context, source convert.Source, objs *[]any) error {
+	// offline replays run without redis
+	if s.cache == nil || objs == nil || len(*objs) == 0 {
+		return nil
+	}
+
+	universe, err := s.universe(ctx)
+	if err != nil {
+		return xerrors.Errorf("service.updateUniverse: failed, reason: %w", err)
+	}
+
+	crawled, err := s.universeCrawled(ctx)
+	if err != nil {
+		return xerrors.Errorf("service.updateUniverse: failed, reason: %w", err)
+	}
+
+	if onList := countListed(universe, source); 2*len(*objs) < onList {
+		return xerrors.Errorf(
+			"service.updateUniverse: failed, source=%v; crawled=%d; listed=%d; reason: %w",
+			source, len(*objs), onList, ErrUniverseShrunk,
+		)
+	}
+
+	now := time.Now()
+	date := now.Format(helper.TwseDateFormat)
+	bootstrap := crawled[source].IsZero()
+	crawled[source] = now
+	seen := make(map[string]bool, len(*objs))
+	changed := make(map[string]*dto.ListedStock)
+
+	var changes []*entity.StockChange
+
+	for _, val := range *objs {
+		stock, ok := val.(*entity.Stock)
+		if !ok {
+			return xerrors.Errorf("service.updateUniverse: failed, reason: interface casting error %T", val)
+		}
+
+		seen[stock.StockID] = true
+		listed, ok := universe[stock.StockID]
+
+		switch {
+		case !ok || listed.DelistedAt != nil:
+			listed = &dto.ListedStock{Stock: *stock, ListedAt: now, UpdatedAt: now}
+
+			if !bootstrap {
+				changes = append(changes, &entity.StockChange{Type: entity.StockListed, Date: date, Stock: *stock})
+			}
+		case listed.Stock != *stock:
+			changes = append(changes, stockChanges(&listed.Stock, stock, date)...)
+			listed.Stock = *stock
+			listed.UpdatedAt = now
+		case listed.List == source && listed.MissingSince == nil:
+			// unchanged
+			continue
+		}
+
+		listed.List = source
+		listed.MissingSince = nil
+		changed[stock.StockID] = listed
+	}
+
+	for stockID, listed := range universe {
+		if listed.DelistedAt != nil || seen[stockID] {
+			continue
+		}
+
+		if listed.List == source && listed.MissingSince == nil {
+			listed.MissingSince = &now
+			changed[stockID] = listed
+		}
+
+		if listed.MissingSince != nil && crawledSince(crawled, *listed.MissingSince) {
+			listed.DelistedAt = &now
+			changed[stockID] = listed
+			changes = append(changes, &entity.StockChange{Type: entity.StockDelisted, Date: date, Stock: listed.Stock})
+		}
+	}
+
+	if err = s.sendStockChanges(ctx, changes); err != nil {
+		return xerrors.Errorf("service.updateUniverse: failed, reason: %w", err)
+	}
+
+	if err = s.saveUniverse(ctx, changed, source, now); err != nil {
+		return xerrors.Errorf("service.updateUniverse: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// stockChanges returns the name, category and market changes of the stock.
+func stockChanges(prev, stock *entity.Stock, date string) []*entity.StockChange {
+	var changes []*entity.StockChange
+
+	for _, field := range []struct {
+		kind     entity.StockChangeType
+		from, to string
+	}{
+		{kind: entity.StockRenamed, from: prev.Name, to: stock.Name},
+		{kind: entity.StockRecategorized, from: prev.Category, to: stock.Category},
+		{kind: entity.StockMarketTransfer, from: prev.Market, to: stock.Market},
+	} {
+		if field.from != field.to {
+			changes = append(changes, &entity.StockChange{
+				Type:  field.kind,
+				Date:  date,
+				From:  field.from,
+				To:    field.to,
+				Stock: *stock,
+			})
+		}
+	}
+
+	return changes
+}
+
+// sendStockChanges publishes the changes ordered by stock.
+func (s *serviceImpl) sendStockChanges(ctx context.Context, changes []*entity.StockChange) error {
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Stock.StockID < changes[j].Stock.StockID })
+
+	for _, change := range changes {
+		b, err := jsoni.Marshal(change)
+		if err != nil {
+			return xerrors.Errorf("service.sendStockChanges: failed, reason: json marshal error %w", err)
+		}
+
+		if err = s.sendKafka(ctx, kafka.StockChangesV1, b); err != nil {
+			return xerrors.Errorf("service.sendStockChanges: failed, reason: send kafka error %w", err)
+		}
+	}
+
+	return nil
+}
+
+// countListed returns the count of listed stocks last seen on the list.
+func countListed(universe map[string]*dto.ListedStock, source convert.Source) int {
+	count := 0
+
+	for _, listed := range universe {
+		if listed.DelistedAt == nil && listed.List == source {
+			count++
+		}
+	}
+
+	return count
+}
+
+// crawledSince tells whether every stock list was crawled since the time.
+func crawledSince(crawled map[convert.Source]time.Time, since time.Time) bool {
+	for _, source := range convert.UniverseSources() {
+		if crawled[source].Before(since) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *serviceImpl) saveUniverse(
+	ctx context.Context,
+	changed map[string]*dto.ListedStock,
+	source convert.Source,
+	now time.Time,
+) error {
+	values := make(map[string]string, len(changed))
+
+	for stockID, listed := range changed {
+		value, err := jsoni.MarshalToString(listed)
+		if err != nil {
+			return xerrors.Errorf("service.saveUniverse: failed, stock_id=%s; reason: %w", stockID, err)
+		}
+
+		values[stockID] = value
+	}
+
+	if err := s.cache.HSet(ctx, universeKey, values); err != nil {
+		return xerrors.Errorf("service.saveUniverse: failed, reason: %w", err)
+	}
+
+	// recorded last, so the lists are only taken as crawled once their stocks are saved
+	err := s.cache.HSet(ctx, universeCrawledKey, map[string]string{source.String(): now.Format(time.RFC3339Nano)})
+	if err != nil {
+		return xerrors.Errorf("service.saveUniverse: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// universe returns every stock of the universe by id, delisted ones included.
+func (s *serviceImpl) universe(ctx context.Context) (map[string]*dto.ListedStock, error) {
+	values, err := s.cache.HGetAll(ctx, universeKey)
+	if err != nil {
+		return nil, xerrors.Errorf("service.universe: failed, reason: %w", err)
+	}
+
+	universe := make(map[string]*dto.ListedStock, len(values))
+
+	for stockID, value := range values {
+		listed := &dto.ListedStock{}
+		if err := jsoni.UnmarshalFromString(value, listed); err != nil {
+			return nil, xerrors.Errorf("service.universe: failed, stock_id=%s; reason: %w", stockID, err)
+		}
+
+		universe[stockID] = listed
+	}
+
+	return universe, nil
+}
+
+func (s *serviceImpl) universeCrawled(ctx context.Context) (map[convert.Source]time.Time, error) {
+	values, err := s.cache.HGetAll(ctx, universeCrawledKey)
+	if err != nil {
+		return nil, xerrors.Errorf("service.universeCrawled: failed, reason: %w", err)
+	}
+
+	crawled := make(map[convert.Source]time.Time, len(values))
+
+	for name, value := range values {
+		source, err := convert.ParseSource(name)
+		if err != nil {
+			continue
+		}
+
+		if crawled[source], err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, xerrors.Errorf("service.universeCrawled: failed, source=%s; reason: %w", name, err)
+		}
+	}
+
+	return crawled, nil
+}
+
+// ListUniverse returns the listed stocks of the universe matching the filter, ordered
+// by stock id.
+func (s *serviceImpl) ListUniverse(ctx context.Context, filter *dto.StockFilter) ([]*dto.ListedStock, error) {
+	if s.cache == nil {
+		return nil, nil
+	}
+
+	universe, err := s.universe(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("service.listUniverse: failed, reason: %w", err)
+	}
+
+	return listed(universe, filter), nil
+}
+
+// listed returns the listed stocks matching the filter, ordered by stock id.
+func listed(universe map[string]*dto.ListedStock, filter *dto.StockFilter) []*dto.ListedStock {
+	stocks := make([]*dto.ListedStock, 0, len(universe))
+
+	for _, listed := range universe {
+		if listed.DelistedAt == nil && (filter == nil || filter.Match(&listed.Stock)) {
+			stocks = append(stocks, listed)
+		}
+	}
+
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].StockID < stocks[j].StockID })
+
+	return stocks
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/samwang0723/stock-crawler/internal/app/dto"
+	"github.com/samwang0723/stock-crawler/internal/app/entity"
+	"github.com/samwang0723/stock-crawler/internal/app/entity/convert"
+	cache "github.com/samwang0723/stock-crawler/internal/cache/mocks"
+	"github.com/samwang0723/stock-crawler/internal/kafka"
+	kafkamock "github.com/samwang0723/stock-crawler/internal/kafka/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockHashes backs the hash commands of the mock with maps.
+func mockHashes(mockRedis *cache.MockRedis) {
+	hashes := make(map[string]map[string]string)
+
+	mockRedis.EXPECT().HGetAll(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, key string) (map[string]string, error) {
+			values := make(map[string]string, len(hashes[key]))
+			for field, value := range hashes[key] {
+				values[field] = value
+			}
+
+			return values, nil
+		}).
+		AnyTimes()
+	mockRedis.EXPECT().HSet(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, key string, values map[string]string) error {
+			if hashes[key] == nil {
+				hashes[key] = make(map[string]string)
+			}
+
+			for field, value := range values {
+				hashes[key][field] = value
+			}
+
+			return nil
+		}).
+		AnyTimes()
+}
+
+func stocks(stocks ...*entity.Stock) *[]any {
+	objs := make([]any, 0, len(stocks))
+	for _, stock := range stocks {
+		objs = append(objs, stock)
+	}
+
+	return &objs
+}
+
+func tse(stockID, name string) *entity.Stock {
+	return &entity.Stock{StockID: stockID, Name: name, Market: "tse", SecurityType: entity.SecurityStock}
+}
+
+func otc(stockID, name string) *entity.Stock {
+	return &entity.Stock{StockID: stockID, Name: name, Market: "otc", SecurityType: entity.SecurityStock}
+}
+
+func TestUpdateUniverse(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	mockHashes(mockRedis)
+
+	var published []string
+
+	mockKafka := kafkamock.NewMockKafka(mockCtl)
+	mockKafka.EXPECT().WriteMessages(ctx, kafka.StockChangesV1, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ string, message []byte) error {
+			change := &entity.StockChange{}
+			if err := jsonTest.Unmarshal(message, change); err != nil {
+				return err
+			}
+
+			published = append(published, string(change.Type)+":"+change.Stock.StockID+":"+change.To)
+
+			return nil
+		}).
+		AnyTimes()
+
+	svc := &serviceImpl{
+		cache:    mockRedis,
+		producer: mockKafka,
+	}
+
+	changes := func() []string {
+		res := published
+		published = nil
+
+		return res
+	}
+
+	update := func(source convert.Source, objs *[]any) {
+		t.Helper()
+
+		if err := svc.UpdateUniverse(ctx, source, objs); err != nil {
+			t.Fatalf("service UpdateUniverse() error = %v", err)
+		}
+	}
+
+	universe := func(filter *dto.StockFilter) map[string]*dto.ListedStock {
+		t.Helper()
+
+		listed, err := svc.ListUniverse(ctx, filter)
+		if err != nil {
+			t.Fatalf("service ListUniverse() error = %v", err)
+		}
+
+		res := make(map[string]*dto.ListedStock, len(listed))
+		for _, stock := range listed {
+			res[stock.StockID] = stock
+		}
+
+		return res
+	}
+
+	update(convert.TwseStockList, stocks(tse("2330", "台積電"), tse("2317", "鴻海"), tse("1101", "台泥")))
+	update(convert.TpexStockList, stocks(otc("6488", "環球晶")))
+	assert.Len(t, universe(nil), 4)
+	// the first crawl of a list is the initial snapshot
+	assert.Empty(t, changes())
+
+	// 6488 moves to TSE, 1101 is renamed and 2317 left the TSE list
+	update(convert.TwseStockList, stocks(tse("2330", "台積電"), tse("1101", "台泥新"), tse("6488", "環球晶")))
+
+	listed := universe(nil)
+	assert.Equal(t, "tse", listed["6488"].Market)
+	assert.Equal(t, "台泥新", listed["1101"].Name)
+	// not delisted until the OTC list is crawled without it
+	assert.NotNil(t, listed["2317"].MissingSince)
+	assert.Equal(t, []string{"name_change:1101:台泥新", "market_transfer:6488:tse"}, changes())
+
+	update(convert.TpexStockList, stocks(otc("8069", "元太")))
+
+	listed = universe(nil)
+	assert.NotContains(t, listed, "2317")
+	assert.Equal(t, convert.TwseStockList, listed["6488"].List)
+	assert.Len(t, listed, 4)
+	assert.Equal(t, []string{"delisting:2317:", "listing:8069:"}, changes())
+
+	// 2330 moves to OTC, the TSE list is crawled first
+	update(convert.TwseStockList, stocks(tse("1101", "台泥新"), tse("6488", "環球晶")))
+	update(convert.TpexStockList, stocks(otc("8069", "元太"), otc("2330", "台積電")))
+
+	listed = universe(&dto.StockFilter{Markets: []string{"otc"}})
+	assert.Len(t, listed, 2)
+	assert.Equal(t, "otc", listed["2330"].Market)
+	assert.Nil(t, listed["2330"].MissingSince)
+	assert.Nil(t, listed["2330"].DelistedAt)
+	assert.Equal(t, []string{"market_transfer:2330:otc"}, changes())
+
+	stockIDs, err := svc.ListStocks(ctx, &dto.StockFilter{Markets: []string{"tse"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1101", "6488"}, stockIDs)
+
+	// a truncated list is rejected
+	err = svc.UpdateUniverse(ctx, convert.TpexStockList, stocks(otc("8069", "元太"), otc("2330", "台積電"), otc("3105", "穩懋")))
+	assert.NoError(t, err)
+	err = svc.UpdateUniverse(ctx, convert.TpexStockList, stocks(otc("3105", "穩懋")))
+	assert.True(t, errors.Is(err, ErrUniverseShrunk))
+	assert.Len(t, universe(&dto.StockFilter{Markets: []string{"otc"}}), 3)
+	assert.Equal(t, []string{"listing:3105:"}, changes())
+}
+
+func TestListStocks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockRedis := cache.NewMockRedis(mockCtl)
+	mockHashes(mockRedis)
+
+	svc := &serviceImpl{cache: mockRedis}
+
+	// the given stocks are taken as is
+	stockIDs, err := svc.ListStocks(ctx, &dto.StockFilter{StockIDs: []string{"2330"}, Markets: []string{"otc"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2330"}, stockIDs)
+
+	// the static stock list can not be filtered
+	_, err = svc.ListStocks(ctx, &dto.StockFilter{Markets: []string{"tse"}})
+	assert.ErrorIs(t, err, ErrUniverseMissing)
+
+	_, err = (&serviceImpl{}).ListStocks(ctx, &dto.StockFilter{SecurityTypes: []string{entity.SecurityStock}})
+	assert.ErrorIs(t, err, ErrUniverseMissing)
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package services
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	workQueueKeyPrefix = "crawl-queue:"
+	workQueueIndexKey  = "crawl-queues"
+	// abandoned queues are dropped after a day
+	workQueueExpire = 24 * time.Hour
+)
+
+// EnqueueWork appends the items to the work queue and announces the queue to the
+// other instances.
+func (s *serviceImpl) EnqueueWork(ctx context.Context, queue string, items []string) error {
+	if err := s.cache.Enqueue(ctx, workQueueKeyPrefix+queue, workQueueExpire, items); err != nil {
+		return xerrors.Errorf("service.enqueueWork: failed, reason: %w", err)
+	}
+
+	if err := s.cache.SAdd(ctx, workQueueIndexKey, queue); err != nil {
+		return xerrors.Errorf("service.enqueueWork: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimWork takes the next pending item of the work queue for the visibility timeout,
+// cache.ErrCacheMiss if nothing is pending.
+func (s *serviceImpl) ClaimWork(ctx context.Context, queue string, visibility time.Duration) (string, error) {
+	item, err := s.cache.Claim(ctx, workQueueKeyPrefix+queue, visibility)
+	if err != nil {
+		return "", xerrors.Errorf("service.claimWork: failed, reason: %w", err)
+	}
+
+	return item, nil
+}
+
+func (s *serviceImpl) AckWork(ctx context.Context, queue, item string) error {
+	if err := s.cache.Ack(ctx, workQueueKeyPrefix+queue, item); err != nil {
+		return xerrors.Errorf("service.ackWork: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+func (s *serviceImpl) RequeueWork(ctx context.Context, queue, claimed, item string) error {
+	if err := s.cache.Requeue(ctx, workQueueKeyPrefix+queue, claimed, item); err != nil {
+		return xerrors.Errorf("service.requeueWork: failed, reason: %w", err)
+	}
+
+	return nil
+}
+
+// WorkQueueLen returns the count of pending and claimed items of the work queue.
+func (s *serviceImpl) WorkQueueLen(ctx context.Context, queue string) (int64, int64, error) {
+	pending, claimed, err := s.cache.QueueLen(ctx, workQueueKeyPrefix+queue)
+	if err != nil {
+		return 0, 0, xerrors.Errorf("service.workQueueLen: failed, reason: %w", err)
+	}
+
+	return pending, claimed, nil
+}
+
+// ListWorkQueues returns the announced work queues, finished ones included until deleted.
+func (s *serviceImpl) ListWorkQueues(ctx context.Context) ([]string, error) {
+	queues, err := s.cache.SMembers(ctx, workQueueIndexKey)
+	if err != nil {
+		return nil, xerrors.Errorf("service.listWorkQueues: failed, reason: %w", err)
+	}
+
+	return queues, nil
+}
+
+func (s *serviceImpl) DeleteWorkQueue(ctx context.Context, queue string) error {
+	if err := s.cache.SRem(ctx, workQueueIndexKey, queue); err != nil {
+		return xerrors.Errorf("service.deleteWorkQueue: failed, reason: %w", err)
+	}
+
+	if err := s.cache.DeleteQueue(ctx, workQueueKeyPrefix+queue); err != nil {
+		return xerrors.Errorf("service.deleteWorkQueue: failed, reason: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("archive entry not found")
+
+// Metadata describes an archived raw response.
+type Metadata struct {
+	RetrievedAt time.Time `json:"retrievedAt"`
+	Source      string    `json:"source"`
+	Date        string    `json:"date"`
+	URL         string    `json:"url"`
+	Hash        string    `json:"hash"` // sha256 of the raw content
+	StatusCode  int       `json:"statusCode"`
+	Size        int       `json:"size"`
+}
+
+// Archive keeps the raw responses of every crawl so history can be re-parsed
+// after upstream format changes or converter fixes.
+type Archive interface {
+	// Put stores the content keyed by source, date and content hash, the
+	// hash and size of the metadata are filled in by the archive.
+	Put(ctx context.Context, meta *Metadata, content []byte) error
+	// Get returns the latest archived response of the url.
+	Get(ctx context.Context, source, date, url string) (*Metadata, []byte, error)
+	// List returns the metadata of all archived responses of the source and date.
+	List(ctx context.Context, source, date string) ([]*Metadata, error)
+}
+
+// Hash returns the content address of the raw bytes.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
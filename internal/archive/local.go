@@ -0,0 +1,201 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archive
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+const (
+	objectsDir   = "objects"
+	metadataExt  = ".json"
+	dirPerm      = 0o755
+	filePerm     = 0o644
+	undatedEntry = "undated"
+)
+
+var jsoni = jsoniter.ConfigCompatibleWithStandardLibrary
+
+type Config struct {
+	// Root folder of the archive.
+	Root string
+
+	// The logger to use. If not defined an output-discarding logger will
+	// be used instead.
+	Logger *zerolog.Logger
+}
+
+// localImpl lays out the archive on the filesystem as
+//
+//	{root}/{source}/{date}/objects/{content hash}
+//	{root}/{source}/{date}/{url hash}.json
+//
+// so identical responses are stored once while every url keeps its own metadata.
+type localImpl struct {
+	cfg Config
+}
+
+func NewLocal(cfg Config) Archive {
+	if cfg.Logger == nil {
+		nop := zerolog.Nop()
+		cfg.Logger = &nop
+	}
+
+	return &localImpl{cfg: cfg}
+}
+
+func (l *localImpl) Put(_ context.Context, meta *Metadata, content []byte) error {
+	meta.Hash = Hash(content)
+	meta.Size = len(content)
+
+	dir := l.dir(meta.Source, meta.Date)
+
+	object := filepath.Join(dir, objectsDir, meta.Hash)
+	if _, err := os.Stat(object); errors.Is(err, fs.ErrNotExist) {
+		if err := writeFile(object, content); err != nil {
+			return xerrors.Errorf("archive.Put: failed, url=%s; err=%w;", meta.URL, err)
+		}
+	}
+
+	b, err := jsoni.Marshal(meta)
+	if err != nil {
+		return xerrors.Errorf("archive.Put: failed, url=%s; err=%w;", meta.URL, err)
+	}
+
+	if err := writeFile(filepath.Join(dir, Hash([]byte(meta.URL))+metadataExt), b); err != nil {
+		return xerrors.Errorf("archive.Put: failed, url=%s; err=%w;", meta.URL, err)
+	}
+
+	l.cfg.Logger.Debug().Msgf("archive.Put: success, url=%s; hash=%s;", meta.URL, meta.Hash)
+
+	return nil
+}
+
+func (l *localImpl) Get(_ context.Context, source, date, url string) (*Metadata, []byte, error) {
+	dir := l.dir(source, date)
+
+	meta, err := readMetadata(filepath.Join(dir, Hash([]byte(url))+metadataExt))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("archive.Get: failed, url=%s; err=%w;", url, err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, objectsDir, meta.Hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		err = ErrNotFound
+	}
+
+	if err != nil {
+		return nil, nil, xerrors.Errorf("archive.Get: failed, url=%s; err=%w;", url, err)
+	}
+
+	return meta, content, nil
+}
+
+func (l *localImpl) List(_ context.Context, source, date string) ([]*Metadata, error) {
+	entries, err := os.ReadDir(l.dir(source, date))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, xerrors.Errorf("archive.List: failed, source=%s; date=%s; err=%w;", source, date, err)
+	}
+
+	var output []*Metadata
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), metadataExt) {
+			continue
+		}
+
+		meta, err := readMetadata(filepath.Join(l.dir(source, date), entry.Name()))
+		if err != nil {
+			return nil, xerrors.Errorf("archive.List: failed, source=%s; date=%s; err=%w;", source, date, err)
+		}
+
+		output = append(output, meta)
+	}
+
+	return output, nil
+}
+
+func (l *localImpl) dir(source, date string) string {
+	if date == "" {
+		date = undatedEntry
+	}
+
+	return filepath.Join(l.cfg.Root, source, date)
+}
+
+func readMetadata(path string) (*Metadata, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, xerrors.Errorf("readMetadata: failed, err=%w;", err)
+	}
+
+	var meta Metadata
+	if err := jsoni.Unmarshal(b, &meta); err != nil {
+		return nil, xerrors.Errorf("readMetadata: failed, err=%w;", err)
+	}
+
+	return &meta, nil
+}
+
+// writeFile writes into a temporary file first and renames it, so readers
+// never observe partially written content.
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	if err := os.Chmod(tmp.Name(), filePerm); err != nil {
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return xerrors.Errorf("writeFile: failed, err=%w;", err)
+	}
+
+	return nil
+}
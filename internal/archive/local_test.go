@@ -0,0 +1,90 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package archive
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	leak := flag.Bool("leak", false, "use leak detector")
+
+	if *leak {
+		goleak.VerifyTestMain(m)
+
+		return
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestLocalArchive(t *testing.T) {
+	t.Parallel()
+
+	logger := log.With().Str("test", "archive").Logger()
+	root := t.TempDir()
+	ctx := context.TODO()
+	impl := NewLocal(Config{Root: root, Logger: &logger})
+
+	content := []byte("2330,1000,1000")
+	urls := []string{"https://www.twse.com.tw/a", "https://www.twse.com.tw/b"}
+
+	for _, url := range urls {
+		err := impl.Put(ctx, &Metadata{
+			Source:      "TwseDailyClose",
+			Date:        "20220525",
+			URL:         url,
+			StatusCode:  200,
+			RetrievedAt: time.Now(),
+		}, content)
+		assert.NoError(t, err)
+	}
+
+	// the logger is optional
+	err := NewLocal(Config{Root: root}).Put(ctx, &Metadata{Source: "TwseDailyClose", URL: urls[0]}, content)
+	assert.NoError(t, err)
+
+	// identical content is stored only once
+	objects, err := os.ReadDir(filepath.Join(root, "TwseDailyClose", "20220525", objectsDir))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	meta, got, err := impl.Get(ctx, "TwseDailyClose", "20220525", urls[1])
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+	assert.Equal(t, Hash(content), meta.Hash)
+	assert.Equal(t, urls[1], meta.URL)
+	assert.Equal(t, len(content), meta.Size)
+
+	list, err := impl.List(ctx, "TwseDailyClose", "20220525")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	_, _, err = impl.Get(ctx, "TwseDailyClose", "20220526", urls[0])
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	list, err = impl.List(ctx, "TwseDailyClose", "20220526")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"golang.org/x/xerrors"
+)
+
+// collectScript sets the field of the hash and returns every field once the hash has
+// the expected count of fields, the hash is removed then. Incomplete hashes are indexed
+// by the time of their first field.
+//
+//nolint:nolintlint, gochecknoglobals
+var collectScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if redis.call('HLEN', KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[5])
+	redis.call('ZADD', KEYS[2], 'NX', ARGV[4], KEYS[1])
+	return false
+end
+local fields = redis.call('HGETALL', KEYS[1])
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], KEYS[1])
+return fields
+`)
+
+// takeExpiredScript removes the hashes indexed before the given time and returns them
+// as key and fields pairs, expired hashes are skipped.
+//
+//nolint:nolintlint, gochecknoglobals
+var takeExpiredScript = redis.NewScript(`
+local keys = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+local res = {}
+for _, key in ipairs(keys) do
+	redis.call('ZREM', KEYS[1], key)
+	local fields = redis.call('HGETALL', key)
+	redis.call('DEL', key)
+	if #fields > 0 then
+		table.insert(res, key)
+		table.insert(res, fields)
+	end
+end
+return res
+`)
+
+// Collect sets the field of the hash key and returns every field once the hash has total
+// fields, nil otherwise. The incomplete hash expires after the period and is indexed in
+// the index key until completed or taken by TakeExpired.
+func (r *redisImpl) Collect(
+	ctx context.Context,
+	index, key, field, value string,
+	total int,
+	expire time.Duration,
+) (map[string]string, error) {
+	res, err := collectScript.Run(
+		ctx,
+		r.instance,
+		[]string{key, index},
+		field,
+		value,
+		total,
+		strconv.FormatInt(time.Now().UnixMilli(), 10),
+		expire.Milliseconds(),
+	).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("cache.Collect: failed, key=%s; err=%w;", key, err)
+	}
+
+	return pairs(res), nil
+}
+
+// TakeExpired removes the incomplete hashes of the index collected before the time,
+// and returns their fields by key.
+func (r *redisImpl) TakeExpired(ctx context.Context, index string, before time.Time) (map[string]map[string]string, error) {
+	res, err := takeExpiredScript.Run(
+		ctx,
+		r.instance,
+		[]string{index},
+		strconv.FormatInt(before.UnixMilli(), 10),
+	).Slice()
+	if err != nil {
+		return nil, xerrors.Errorf("cache.TakeExpired: failed, index=%s; err=%w;", index, err)
+	}
+
+	hashes := make(map[string]map[string]string, len(res)/2)
+
+	for i := 0; i+1 < len(res); i += 2 {
+		key, ok := res[i].(string)
+		if !ok {
+			continue
+		}
+
+		fields, ok := res[i+1].([]any)
+		if !ok {
+			continue
+		}
+
+		hashes[key] = pairs(fields)
+	}
+
+	return hashes, nil
+}
+
+// pairs maps the field and value pairs of a HGETALL reply.
+func pairs(values []any) map[string]string {
+	fields := make(map[string]string, len(values)/2)
+
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		fields[field] = value
+	}
+
+	return fields
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	redismock "github.com/go-redis/redismock/v8"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	logger := log.With().Str("test", "redis").Logger()
+	index, key := "concentrations", "concentration:20220801:2330"
+	expire := time.Hour
+
+	client, mock := redismock.NewClientMock()
+	impl := &redisImpl{
+		instance: client,
+		cfg: Config{
+			Logger: &logger,
+		},
+	}
+
+	// the collecting time is not matched
+	anyTime := func(expected, actual []any) error {
+		assert.Equal(t, expected[:8], actual[:8])
+		assert.Equal(t, expected[9:], actual[9:])
+
+		return nil
+	}
+
+	mock.CustomMatch(anyTime).
+		ExpectEvalSha(collectScript.Hash(), []string{key, index}, "0", "{}", 5, "", expire.Milliseconds()).
+		RedisNil()
+
+	fields, err := impl.Collect(ctx, index, key, "0", "{}", 5, expire)
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	mock.CustomMatch(anyTime).
+		ExpectEvalSha(collectScript.Hash(), []string{key, index}, "4", "{}", 5, "", expire.Milliseconds()).
+		SetVal([]any{"0", "{}", "4", "{}"})
+
+	fields, err = impl.Collect(ctx, index, key, "4", "{}", 5, expire)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"0": "{}", "4": "{}"}, fields)
+
+	before := time.Now()
+	mock.ExpectEvalSha(takeExpiredScript.Hash(), []string{index}, strconv.FormatInt(before.UnixMilli(), 10)).
+		SetVal([]any{key, []any{"1", "{}"}})
+
+	hashes, err := impl.TakeExpired(ctx, index, before)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{key: {"1": "{}"}}, hashes)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// fencedSetScript sets the key if missing, unless a leader of a later term was elected.
+// It returns -1 when refused, 1 when set and 0 when the key exists.
+//
+//nolint:nolintlint, gochecknoglobals
+var fencedSetScript = redis.NewScript(`
+local term = tonumber(redis.call('GET', KEYS[2]) or '0')
+if term > tonumber(ARGV[3]) then
+	return -1
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// fencedEnqueueScript appends the items to the list and sets its expiry, unless a leader
+// of a later term was elected. It returns -1 when refused.
+//
+//nolint:nolintlint, gochecknoglobals
+var fencedEnqueueScript = redis.NewScript(`
+local term = tonumber(redis.call('GET', KEYS[2]) or '0')
+if term > tonumber(ARGV[1]) then
+	return -1
+end
+for i = 3, #ARGV, 1000 do
+	redis.call('RPUSH', KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Fence is the term of the leader a write is made for. The fenced writes compare it
+// with the term counter in Redis, in the same script, and are refused with ErrNotLeader
+// once a later leader was elected, so a former leader paused past its lease can not
+// overlap the new one.
+type Fence struct {
+	// Key counting the terms of the election.
+	Key  string
+	Term int64
+}
+
+type fenceContextKey struct{}
+
+// ContextWithFence fences the locks obtained and the items enqueued with the context.
+func ContextWithFence(ctx context.Context, fence *Fence) context.Context {
+	return context.WithValue(ctx, fenceContextKey{}, fence)
+}
+
+// fenceFromContext returns nil if the writes of the context are not fenced.
+func fenceFromContext(ctx context.Context) *Fence {
+	if fence, ok := ctx.Value(fenceContextKey{}).(*Fence); ok {
+		return fence
+	}
+
+	return nil
+}
+
+// fencedClient obtains the locks only for the term of the fence, the lock client sets
+// its keys with SetNX.
+type fencedClient struct {
+	*redis.Client
+	fence *Fence
+}
+
+func (c *fencedClient) SetNX(ctx context.Context, key string, value any, expiration time.Duration) *redis.BoolCmd {
+	cmd := redis.NewBoolCmd(ctx)
+
+	res, err := fencedSetScript.Run(
+		ctx,
+		c.Client,
+		[]string{key, c.fence.Key},
+		value,
+		expiration.Milliseconds(),
+		c.fence.Term,
+	).Int()
+
+	switch {
+	case err != nil:
+		cmd.SetErr(err)
+	case res < 0:
+		cmd.SetErr(ErrNotLeader)
+	default:
+		cmd.SetVal(res == 1)
+	}
+
+	return cmd
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	logger := log.With().Str("test", "redis").Logger()
+	queue := "crawl-queue:20220801"
+
+	server := miniredis.RunT(t)
+	impl := &redisImpl{
+		instance: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		cfg: Config{
+			Logger: &logger,
+		},
+	}
+
+	newElection := func(id string) *Election {
+		return NewElection(impl, ElectionConfig{Key: LeaderKey, ID: id, Expire: time.Minute, Logger: &logger})
+	}
+
+	former := newElection("pod-a")
+	former.campaign(ctx)
+
+	formerCtx, err := former.Fence(ctx)
+	assert.NoError(t, err)
+
+	lock, err := impl.ObtainLock(formerCtx, CronjobLock, time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Release(ctx))
+	assert.NoError(t, impl.Enqueue(formerCtx, queue, time.Hour, []string{"2330"}))
+
+	// the former leader paused past its lease while another pod was elected
+	server.FastForward(time.Minute)
+
+	current := newElection("pod-b")
+	current.campaign(ctx)
+	assert.True(t, current.IsLeader())
+
+	_, err = impl.ObtainLock(formerCtx, CronjobLock, time.Minute)
+	assert.ErrorIs(t, err, ErrNotLeader)
+	assert.ErrorIs(t, impl.Enqueue(formerCtx, queue, time.Hour, []string{"2317"}), ErrNotLeader)
+
+	// the writes of the current term are applied
+	currentCtx, err := current.Fence(ctx)
+	assert.NoError(t, err)
+
+	lock, err = impl.ObtainLock(currentCtx, CronjobLock, time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Release(ctx))
+	assert.NoError(t, impl.Enqueue(currentCtx, queue, time.Hour, []string{"2454"}))
+
+	items, err := impl.instance.LRange(ctx, queue+pendingSuffix, 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2330", "2454"}, items)
+	assert.Equal(t, time.Hour, server.TTL(queue+pendingSuffix))
+
+	// the demoted leader no longer fences
+	former.campaign(ctx)
+
+	_, err = former.Fence(ctx)
+	assert.ErrorIs(t, err, ErrNotLeader)
+}
@@ -0,0 +1,287 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+const (
+	LeaderKey = "crawler-leader"
+	// the lease of the leader is renewed every third of its expiry
+	leaderRenewRatio = 3
+	// termSuffix names the counter of the terms
+	termSuffix = ":term"
+)
+
+var ErrNotLeader = errors.New("not the leader")
+
+// campaignScript elects the candidate if nobody leads, for the next term,
+// and renews the lease if the candidate leads. It returns the leader as "id term".
+//
+//nolint:nolintlint, gochecknoglobals
+var campaignScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if not value then
+	local term = redis.call('INCR', KEYS[2])
+	value = ARGV[1] .. ' ' .. term
+	redis.call('SET', KEYS[1], value, 'PX', ARGV[2])
+	return value
+end
+if string.sub(value, 1, string.len(ARGV[1]) + 1) == ARGV[1] .. ' ' then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// resignScript deletes the lease only if still held by the leader of the term.
+//
+//nolint:nolintlint, gochecknoglobals
+var resignScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Leader is the instance owning the scheduling. Term increases with every elected
+// leader, so a former leader can be told apart from the current one even if they
+// share the id.
+type Leader struct {
+	ID   string `json:"id"`
+	Term int64  `json:"term"`
+}
+
+func (l *Leader) String() string {
+	return l.ID + " " + strconv.FormatInt(l.Term, 10)
+}
+
+func parseLeader(value string) (*Leader, error) {
+	idx := strings.LastIndex(value, " ")
+	if idx < 0 {
+		return nil, xerrors.Errorf("cache.parseLeader: failed, value=%s; err=%w;", value, ErrNotLeader)
+	}
+
+	term, err := strconv.ParseInt(value[idx+1:], 10, 64)
+	if err != nil {
+		return nil, xerrors.Errorf("cache.parseLeader: failed, value=%s; err=%w;", value, err)
+	}
+
+	return &Leader{ID: value[:idx], Term: term}, nil
+}
+
+// Campaign elects the candidate or renews its lease, and returns the current leader.
+func (r *redisImpl) Campaign(ctx context.Context, key, id string, expire time.Duration) (*Leader, error) {
+	value, err := campaignScript.Run(ctx, r.instance, []string{key, key + termSuffix}, id, expire.Milliseconds()).Text()
+	if err != nil {
+		return nil, xerrors.Errorf("cache.Campaign: failed, key=%s; id=%s; err=%w;", key, id, err)
+	}
+
+	return parseLeader(value)
+}
+
+// Resign ends the term of the leader, a lease taken over in between is kept.
+func (r *redisImpl) Resign(ctx context.Context, key string, leader *Leader) error {
+	err := resignScript.Run(ctx, r.instance, []string{key}, leader.String()).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.Resign: failed, key=%s; leader=%s; err=%w;", key, leader, err)
+	}
+
+	return nil
+}
+
+// CurrentLeader returns the leader holding the lease, ErrCacheMiss if nobody leads.
+func (r *redisImpl) CurrentLeader(ctx context.Context, key string) (*Leader, error) {
+	value, err := r.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLeader(value)
+}
+
+// ElectionConfig of the election of an instance.
+type ElectionConfig struct {
+	// Key holding the lease of the leader.
+	Key string
+
+	// ID identifies the instance, it must not contain spaces.
+	ID string
+
+	// Expire of the lease, another instance is elected after the leader
+	// failed to renew it for this period.
+	Expire time.Duration
+
+	// OnElected and OnDemoted are called when the instance becomes and stops being the leader.
+	OnElected func()
+	OnDemoted func()
+
+	Logger *zerolog.Logger
+}
+
+// Election campaigns for the instance until the context is done, with a single
+// leader among the instances sharing the key. A leader failing to renew its lease
+// steps down at once, before the lease expires and another instance is elected.
+type Election struct {
+	cache  Redis
+	leader *Leader
+	cfg    ElectionConfig
+	mu     sync.RWMutex
+}
+
+func NewElection(cache Redis, cfg ElectionConfig) *Election {
+	return &Election{cache: cache, cfg: cfg}
+}
+
+// Run campaigns until the context is done, then resigns if leading.
+func (e *Election) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Expire / leaderRenewRatio)
+	defer ticker.Stop()
+
+	for {
+		e.campaign(ctx)
+
+		select {
+		case <-ctx.Done():
+			e.resign(context.WithoutCancel(ctx))
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Election) campaign(ctx context.Context) {
+	leader, err := e.cache.Campaign(ctx, e.cfg.Key, e.cfg.ID, e.cfg.Expire)
+	if err != nil {
+		e.cfg.Logger.Error().Err(err).Msg("cache.Election.campaign: failed")
+	}
+
+	e.set(leader)
+}
+
+func (e *Election) resign(ctx context.Context) {
+	if leader := e.Leader(); e.IsLeader() {
+		if err := e.cache.Resign(ctx, e.cfg.Key, leader); err != nil {
+			e.cfg.Logger.Error().Err(err).Msg("cache.Election.resign: failed")
+		}
+	}
+
+	e.set(nil)
+}
+
+// set records the leader, nil if unknown, and notifies the changes of leadership.
+func (e *Election) set(leader *Leader) {
+	e.mu.Lock()
+	was := e.leading()
+	e.leader = leader
+	is := e.leading()
+	e.mu.Unlock()
+
+	switch {
+	case is && !was:
+		e.cfg.Logger.Info().Msgf("cache.Election: elected, leader=%s;", leader)
+
+		if e.cfg.OnElected != nil {
+			e.cfg.OnElected()
+		}
+	case was && !is:
+		e.cfg.Logger.Info().Msgf("cache.Election: demoted, id=%s;", e.cfg.ID)
+
+		if e.cfg.OnDemoted != nil {
+			e.cfg.OnDemoted()
+		}
+	}
+}
+
+func (e *Election) leading() bool {
+	return e.leader != nil && e.leader.ID == e.cfg.ID
+}
+
+// ID identifies the instance.
+func (e *Election) ID() string {
+	return e.cfg.ID
+}
+
+// Leader returns the last known leader, nil if unknown.
+func (e *Election) Leader() *Leader {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.leader == nil {
+		return nil
+	}
+
+	leader := *e.leader
+
+	return &leader
+}
+
+// IsLeader tells whether the instance leads as of the last campaign.
+func (e *Election) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.leading()
+}
+
+// VerifyLeader returns ErrNotLeader unless the term of the instance is still the
+// current one in Redis. It is a check at the time of the call only, the writes
+// that must not overlap the next leader are fenced with Fence.
+func (e *Election) VerifyLeader(ctx context.Context) error {
+	_, err := e.verify(ctx)
+
+	return err
+}
+
+// Fence verifies the instance leads and returns a copy of ctx fencing the locks and
+// the work queues written with it by the term of the instance, ErrNotLeader otherwise.
+func (e *Election) Fence(ctx context.Context) (context.Context, error) {
+	leader, err := e.verify(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	return ContextWithFence(ctx, &Fence{Key: e.cfg.Key + termSuffix, Term: leader.Term}), nil
+}
+
+// verify returns the leader of the instance if still current.
+func (e *Election) verify(ctx context.Context) (*Leader, error) {
+	leader := e.Leader()
+	if leader == nil || leader.ID != e.cfg.ID {
+		return nil, xerrors.Errorf("cache.Election.VerifyLeader: failed, id=%s; err=%w;", e.cfg.ID, ErrNotLeader)
+	}
+
+	current, err := e.cache.CurrentLeader(ctx, e.cfg.Key)
+	if errors.Is(err, ErrCacheMiss) {
+		return nil, xerrors.Errorf("cache.Election.VerifyLeader: failed, id=%s; err=%w;", e.cfg.ID, ErrNotLeader)
+	} else if err != nil {
+		return nil, xerrors.Errorf("cache.Election.VerifyLeader: failed, err=%w;", err)
+	}
+
+	if *current != *leader {
+		return nil, xerrors.Errorf("cache.Election.VerifyLeader: failed, leader=%s; err=%w;", current, ErrNotLeader)
+	}
+
+	return leader, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redismock "github.com/go-redis/redismock/v8"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElection(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	logger := log.With().Str("test", "redis").Logger()
+	expire := 15 * time.Second
+	keys := []string{LeaderKey, LeaderKey + termSuffix}
+
+	client, mock := redismock.NewClientMock()
+	impl := &redisImpl{
+		instance: client,
+		cfg: Config{
+			Logger: &logger,
+		},
+	}
+
+	var elected, demoted int
+
+	election := NewElection(impl, ElectionConfig{
+		Key:       LeaderKey,
+		ID:        "pod-a",
+		Expire:    expire,
+		OnElected: func() { elected++ },
+		OnDemoted: func() { demoted++ },
+		Logger:    &logger,
+	})
+
+	// elected with the first token, then renewed
+	mock.ExpectEvalSha(campaignScript.Hash(), keys, "pod-a", expire.Milliseconds()).SetVal("pod-a 1")
+	mock.ExpectEvalSha(campaignScript.Hash(), keys, "pod-a", expire.Milliseconds()).SetVal("pod-a 1")
+	election.campaign(ctx)
+	election.campaign(ctx)
+
+	assert.True(t, election.IsLeader())
+	assert.Equal(t, &Leader{ID: "pod-a", Term: 1}, election.Leader())
+	assert.Equal(t, 1, elected)
+
+	mock.ExpectGet(LeaderKey).SetVal("pod-a 1")
+	assert.NoError(t, election.VerifyLeader(ctx))
+
+	// the lease expired while paused and another pod took over
+	mock.ExpectGet(LeaderKey).SetVal("pod-b 2")
+	assert.ErrorIs(t, election.VerifyLeader(ctx), ErrNotLeader)
+
+	mock.ExpectEvalSha(campaignScript.Hash(), keys, "pod-a", expire.Milliseconds()).SetVal("pod-b 2")
+	election.campaign(ctx)
+
+	assert.False(t, election.IsLeader())
+	assert.Equal(t, &Leader{ID: "pod-b", Term: 2}, election.Leader())
+	assert.Equal(t, 1, demoted)
+	assert.ErrorIs(t, election.VerifyLeader(ctx), ErrNotLeader)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
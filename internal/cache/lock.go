@@ -0,0 +1,162 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bsm/redislock"
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+// a held lock is refreshed every third of its expiry
+const lockRefreshRatio = 3
+
+var (
+	// ErrLockNotObtained is returned when the lock is held by another owner.
+	ErrLockNotObtained = errors.New("lock not obtained")
+	// ErrLockLost is the cause of the contexts canceled once their lock is lost.
+	ErrLockLost = errors.New("lock lost")
+)
+
+// Lock is a held distributed lock. It is refreshed in background until released,
+// or until the context given when obtaining it is done, so it outlives its expiry
+// as long as the holder runs. Lost tells the holder when the lock could not be
+// refreshed in time and may be obtained by another owner.
+type Lock struct {
+	lock   *redislock.Lock
+	logger *zerolog.Logger
+	stop   chan struct{}
+	done   chan struct{}
+	lost   chan struct{}
+	expire time.Duration
+	once   sync.Once
+}
+
+func newLock(ctx context.Context, lock *redislock.Lock, expire time.Duration, logger *zerolog.Logger) *Lock {
+	res := &Lock{
+		lock:   lock,
+		logger: logger,
+		expire: expire,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+
+	go res.heartbeat(ctx)
+
+	return res
+}
+
+func (l *Lock) Key() string {
+	return l.lock.Key()
+}
+
+// Refresh extends the lock by its expiry.
+func (l *Lock) Refresh(ctx context.Context) error {
+	if err := l.lock.Refresh(ctx, l.expire, nil); err != nil {
+		return xerrors.Errorf("cache.Lock.Refresh: failed, key=%s; err=%w;", l.Key(), err)
+	}
+
+	return nil
+}
+
+func (l *Lock) heartbeat(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.expire / lockRefreshRatio)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			err := l.Refresh(ctx)
+			if err == nil {
+				continue
+			}
+
+			l.logger.Error().Err(err).Msg("cache.Lock.heartbeat: failed")
+
+			// expired and possibly obtained by another owner
+			if errors.Is(err, redislock.ErrNotObtained) {
+				close(l.lost)
+
+				return
+			}
+		}
+	}
+}
+
+// Lost is closed once the lock is lost, the holder must stop the work it guards.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Context returns a copy of ctx canceled with ErrLockLost once the lock is lost.
+func (l *Lock) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+
+	go func() {
+		select {
+		case <-l.lost:
+			cancel(ErrLockLost)
+		case <-lockCtx.Done():
+		}
+	}()
+
+	return lockCtx, func() { cancel(context.Canceled) }
+}
+
+// halt stops the heartbeat and waits for it to exit.
+func (l *Lock) halt() {
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+// Release stops refreshing and releases the lock, releasing an expired lock is not an error.
+func (l *Lock) Release(ctx context.Context) error {
+	l.halt()
+
+	err := l.lock.Release(ctx)
+	if err != nil && !errors.Is(err, redislock.ErrLockNotHeld) {
+		return xerrors.Errorf("cache.Lock.Release: failed, key=%s; err=%w;", l.Key(), err)
+	}
+
+	return nil
+}
+
+// ReleaseAfter stops refreshing and lets the lock expire after the period instead
+// of releasing it at once, which it does if the period is not positive.
+func (l *Lock) ReleaseAfter(ctx context.Context, period time.Duration) error {
+	if period <= 0 {
+		return l.Release(ctx)
+	}
+
+	l.halt()
+
+	err := l.lock.Refresh(ctx, period, nil)
+	if err != nil && !errors.Is(err, redislock.ErrNotObtained) {
+		return xerrors.Errorf("cache.Lock.ReleaseAfter: failed, key=%s; err=%w;", l.Key(), err)
+	}
+
+	return nil
+}
@@ -9,8 +9,8 @@ import (
 	reflect "reflect"
 	time "time"
 
-	redislock "github.com/bsm/redislock"
 	gomock "github.com/golang/mock/gomock"
+	cache "github.com/samwang0723/stock-crawler/internal/cache"
 )
 
 // MockRedis is a mock of Redis interface.
@@ -36,6 +36,50 @@ func (m *MockRedis) EXPECT() *MockRedisMockRecorder {
 	return m.recorder
 }
 
+// Ack mocks base method.
+func (m *MockRedis) Ack(ctx context.Context, key, item string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ack", ctx, key, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ack indicates an expected call of Ack.
+func (mr *MockRedisMockRecorder) Ack(ctx, key, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ack", reflect.TypeOf((*MockRedis)(nil).Ack), ctx, key, item)
+}
+
+// Campaign mocks base method.
+func (m *MockRedis) Campaign(ctx context.Context, key, id string, expire time.Duration) (*cache.Leader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Campaign", ctx, key, id, expire)
+	ret0, _ := ret[0].(*cache.Leader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Campaign indicates an expected call of Campaign.
+func (mr *MockRedisMockRecorder) Campaign(ctx, key, id, expire any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Campaign", reflect.TypeOf((*MockRedis)(nil).Campaign), ctx, key, id, expire)
+}
+
+// Claim mocks base method.
+func (m *MockRedis) Claim(ctx context.Context, key string, visibility time.Duration) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Claim", ctx, key, visibility)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Claim indicates an expected call of Claim.
+func (mr *MockRedisMockRecorder) Claim(ctx, key, visibility any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Claim", reflect.TypeOf((*MockRedis)(nil).Claim), ctx, key, visibility)
+}
+
 // Close mocks base method.
 func (m *MockRedis) Close() error {
 	m.ctrl.T.Helper()
@@ -50,12 +94,129 @@ func (mr *MockRedisMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRedis)(nil).Close))
 }
 
+// Collect mocks base method.
+func (m *MockRedis) Collect(ctx context.Context, index, key, field, value string, total int, expire time.Duration) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Collect", ctx, index, key, field, value, total, expire)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Collect indicates an expected call of Collect.
+func (mr *MockRedisMockRecorder) Collect(ctx, index, key, field, value, total, expire any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Collect", reflect.TypeOf((*MockRedis)(nil).Collect), ctx, index, key, field, value, total, expire)
+}
+
+// CurrentLeader mocks base method.
+func (m *MockRedis) CurrentLeader(ctx context.Context, key string) (*cache.Leader, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentLeader", ctx, key)
+	ret0, _ := ret[0].(*cache.Leader)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentLeader indicates an expected call of CurrentLeader.
+func (mr *MockRedisMockRecorder) CurrentLeader(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentLeader", reflect.TypeOf((*MockRedis)(nil).CurrentLeader), ctx, key)
+}
+
+// Del mocks base method.
+func (m *MockRedis) Del(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Del", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockRedisMockRecorder) Del(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockRedis)(nil).Del), ctx, key)
+}
+
+// DeleteQueue mocks base method.
+func (m *MockRedis) DeleteQueue(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteQueue", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteQueue indicates an expected call of DeleteQueue.
+func (mr *MockRedisMockRecorder) DeleteQueue(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteQueue", reflect.TypeOf((*MockRedis)(nil).DeleteQueue), ctx, key)
+}
+
+// Enqueue mocks base method.
+func (m *MockRedis) Enqueue(ctx context.Context, key string, expire time.Duration, items []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, key, expire, items)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockRedisMockRecorder) Enqueue(ctx, key, expire, items any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockRedis)(nil).Enqueue), ctx, key, expire, items)
+}
+
+// Get mocks base method.
+func (m *MockRedis) Get(ctx context.Context, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockRedisMockRecorder) Get(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRedis)(nil).Get), ctx, key)
+}
+
+// HGetAll mocks base method.
+func (m *MockRedis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGetAll", ctx, key)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HGetAll indicates an expected call of HGetAll.
+func (mr *MockRedisMockRecorder) HGetAll(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGetAll", reflect.TypeOf((*MockRedis)(nil).HGetAll), ctx, key)
+}
+
+// HSet mocks base method.
+func (m *MockRedis) HSet(ctx context.Context, key string, values map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", ctx, key, values)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockRedisMockRecorder) HSet(ctx, key, values any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockRedis)(nil).HSet), ctx, key, values)
+}
+
 // ObtainLock mocks base method.
-func (m *MockRedis) ObtainLock(ctx context.Context, key string, expire time.Duration) *redislock.Lock {
+func (m *MockRedis) ObtainLock(ctx context.Context, key string, expire time.Duration) (*cache.Lock, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ObtainLock", ctx, key, expire)
-	ret0, _ := ret[0].(*redislock.Lock)
-	return ret0
+	ret0, _ := ret[0].(*cache.Lock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // ObtainLock indicates an expected call of ObtainLock.
@@ -64,6 +225,64 @@ func (mr *MockRedisMockRecorder) ObtainLock(ctx, key, expire any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ObtainLock", reflect.TypeOf((*MockRedis)(nil).ObtainLock), ctx, key, expire)
 }
 
+// QueueLen mocks base method.
+func (m *MockRedis) QueueLen(ctx context.Context, key string) (int64, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueLen", ctx, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// QueueLen indicates an expected call of QueueLen.
+func (mr *MockRedisMockRecorder) QueueLen(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLen", reflect.TypeOf((*MockRedis)(nil).QueueLen), ctx, key)
+}
+
+// ReplaceSet mocks base method.
+func (m *MockRedis) ReplaceSet(ctx context.Context, key string, members []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceSet", ctx, key, members)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceSet indicates an expected call of ReplaceSet.
+func (mr *MockRedisMockRecorder) ReplaceSet(ctx, key, members any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceSet", reflect.TypeOf((*MockRedis)(nil).ReplaceSet), ctx, key, members)
+}
+
+// Requeue mocks base method.
+func (m *MockRedis) Requeue(ctx context.Context, key, claimed, item string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Requeue", ctx, key, claimed, item)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Requeue indicates an expected call of Requeue.
+func (mr *MockRedisMockRecorder) Requeue(ctx, key, claimed, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Requeue", reflect.TypeOf((*MockRedis)(nil).Requeue), ctx, key, claimed, item)
+}
+
+// Resign mocks base method.
+func (m *MockRedis) Resign(ctx context.Context, key string, leader *cache.Leader) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resign", ctx, key, leader)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Resign indicates an expected call of Resign.
+func (mr *MockRedisMockRecorder) Resign(ctx, key, leader any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resign", reflect.TypeOf((*MockRedis)(nil).Resign), ctx, key, leader)
+}
+
 // SAdd mocks base method.
 func (m *MockRedis) SAdd(ctx context.Context, key, value string) error {
 	m.ctrl.T.Helper()
@@ -93,6 +312,34 @@ func (mr *MockRedisMockRecorder) SMembers(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SMembers", reflect.TypeOf((*MockRedis)(nil).SMembers), ctx, key)
 }
 
+// SRem mocks base method.
+func (m *MockRedis) SRem(ctx context.Context, key, value string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SRem", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SRem indicates an expected call of SRem.
+func (mr *MockRedisMockRecorder) SRem(ctx, key, value any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SRem", reflect.TypeOf((*MockRedis)(nil).SRem), ctx, key, value)
+}
+
+// Set mocks base method.
+func (m *MockRedis) Set(ctx context.Context, key, value string, expire time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value, expire)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockRedisMockRecorder) Set(ctx, key, value, expire any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockRedis)(nil).Set), ctx, key, value, expire)
+}
+
 // SetExpire mocks base method.
 func (m *MockRedis) SetExpire(ctx context.Context, key string, expired time.Time) error {
 	m.ctrl.T.Helper()
@@ -106,3 +353,61 @@ func (mr *MockRedisMockRecorder) SetExpire(ctx, key, expired any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetExpire", reflect.TypeOf((*MockRedis)(nil).SetExpire), ctx, key, expired)
 }
+
+// TakeExpired mocks base method.
+func (m *MockRedis) TakeExpired(ctx context.Context, index string, before time.Time) (map[string]map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeExpired", ctx, index, before)
+	ret0, _ := ret[0].(map[string]map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeExpired indicates an expected call of TakeExpired.
+func (mr *MockRedisMockRecorder) TakeExpired(ctx, index, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeExpired", reflect.TypeOf((*MockRedis)(nil).TakeExpired), ctx, index, before)
+}
+
+// ZAdd mocks base method.
+func (m *MockRedis) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZAdd", ctx, key, score, member)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ZAdd indicates an expected call of ZAdd.
+func (mr *MockRedisMockRecorder) ZAdd(ctx, key, score, member any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZAdd", reflect.TypeOf((*MockRedis)(nil).ZAdd), ctx, key, score, member)
+}
+
+// ZRemRangeByRank mocks base method.
+func (m *MockRedis) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRemRangeByRank", ctx, key, start, stop)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ZRemRangeByRank indicates an expected call of ZRemRangeByRank.
+func (mr *MockRedisMockRecorder) ZRemRangeByRank(ctx, key, start, stop any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRemRangeByRank", reflect.TypeOf((*MockRedis)(nil).ZRemRangeByRank), ctx, key, start, stop)
+}
+
+// ZRevRange mocks base method.
+func (m *MockRedis) ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRevRange", ctx, key, start, stop)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRevRange indicates an expected call of ZRevRange.
+func (mr *MockRedisMockRecorder) ZRevRange(ctx, key, start, stop any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRevRange", reflect.TypeOf((*MockRedis)(nil).ZRevRange), ctx, key, start, stop)
+}
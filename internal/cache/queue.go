@@ -0,0 +1,199 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// the items waiting to be claimed, in order
+	pendingSuffix = ":pending"
+	// the claimed items scored by the end of their visibility timeout
+	claimedSuffix = ":claimed"
+)
+
+// claimScript puts the claims past their visibility timeout back in the pending items,
+// then claims the first pending item until the given deadline. The claimed items expire
+// along the pending ones, the pending list emptied and created again keeps the expiry
+// of the claimed items.
+//
+//nolint:nolintlint, gochecknoglobals
+var claimScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	ttl = redis.call('PTTL', KEYS[2])
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, item in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], item)
+	redis.call('RPUSH', KEYS[1], item)
+end
+local item = redis.call('LPOP', KEYS[1])
+if ttl > 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+if not item then
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], item)
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return item
+`)
+
+// ackScript removes the item, also if put back in the pending items after its claim expired.
+//
+//nolint:nolintlint, gochecknoglobals
+var ackScript = redis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('LREM', KEYS[1], 0, ARGV[1])
+return 1
+`)
+
+// requeueScript replaces the claimed item by the given one at the end of the pending items,
+// unless the claim expired and the item is pending already, or the queue was deleted. The
+// pending list emptied and created again keeps the expiry of the claimed items.
+//
+//nolint:nolintlint, gochecknoglobals
+var requeueScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[2])
+if redis.call('ZREM', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[1], ARGV[2])
+if ttl > 0 and redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// Enqueue appends the items to the queue, the queue expires after the period. The items
+// of a fenced context are refused with ErrNotLeader once a later leader was elected.
+func (r *redisImpl) Enqueue(ctx context.Context, key string, expire time.Duration, items []string) error {
+	if fence := fenceFromContext(ctx); fence != nil {
+		return r.fencedEnqueue(ctx, key, expire, items, fence)
+	}
+
+	values := make([]any, 0, len(items))
+	for _, item := range items {
+		values = append(values, item)
+	}
+
+	pipe := r.instance.TxPipeline()
+	pipe.RPush(ctx, key+pendingSuffix, values...)
+	pipe.PExpire(ctx, key+pendingSuffix, expire)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return xerrors.Errorf("cache.Enqueue: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
+func (r *redisImpl) fencedEnqueue(
+	ctx context.Context,
+	key string,
+	expire time.Duration,
+	items []string,
+	fence *Fence,
+) error {
+	args := make([]any, 0, len(items)+2)
+	args = append(args, fence.Term, expire.Milliseconds())
+
+	for _, item := range items {
+		args = append(args, item)
+	}
+
+	res, err := fencedEnqueueScript.Run(ctx, r.instance, []string{key + pendingSuffix, fence.Key}, args...).Int()
+	if err != nil {
+		return xerrors.Errorf("cache.Enqueue: failed, key=%s; err=%w;", key, err)
+	} else if res < 0 {
+		return xerrors.Errorf("cache.Enqueue: failed, key=%s; term=%d; err=%w;", key, fence.Term, ErrNotLeader)
+	}
+
+	return nil
+}
+
+// Claim takes the next pending item of the queue, hidden from the other consumers for
+// the visibility timeout unless acked or requeued, ErrCacheMiss if nothing is pending.
+func (r *redisImpl) Claim(ctx context.Context, key string, visibility time.Duration) (string, error) {
+	now := time.Now()
+
+	item, err := claimScript.Run(
+		ctx,
+		r.instance,
+		[]string{key + pendingSuffix, key + claimedSuffix},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(now.Add(visibility).UnixMilli(), 10),
+	).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrCacheMiss
+	} else if err != nil {
+		return "", xerrors.Errorf("cache.Claim: failed, key=%s; err=%w;", key, err)
+	}
+
+	return item, nil
+}
+
+// Ack removes the claimed item from the queue once processed.
+func (r *redisImpl) Ack(ctx context.Context, key, item string) error {
+	err := ackScript.Run(ctx, r.instance, []string{key + pendingSuffix, key + claimedSuffix}, item).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.Ack: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
+// Requeue gives up the claimed item and appends its replacement to the pending items,
+// so the replacement may record the failed attempts.
+func (r *redisImpl) Requeue(ctx context.Context, key, claimed, item string) error {
+	err := requeueScript.Run(ctx, r.instance, []string{key + pendingSuffix, key + claimedSuffix}, claimed, item).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.Requeue: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
+// QueueLen returns the count of pending and claimed items of the queue.
+func (r *redisImpl) QueueLen(ctx context.Context, key string) (pending, claimed int64, err error) {
+	pipe := r.instance.Pipeline()
+	pendingCmd := pipe.LLen(ctx, key+pendingSuffix)
+	claimedCmd := pipe.ZCard(ctx, key+claimedSuffix)
+
+	if _, err = pipe.Exec(ctx); err != nil {
+		return 0, 0, xerrors.Errorf("cache.QueueLen: failed, key=%s; err=%w;", key, err)
+	}
+
+	return pendingCmd.Val(), claimedCmd.Val(), nil
+}
+
+// DeleteQueue drops the pending and claimed items of the queue.
+func (r *redisImpl) DeleteQueue(ctx context.Context, key string) error {
+	err := r.instance.Del(ctx, key+pendingSuffix, key+claimedSuffix).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.DeleteQueue: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkQueue(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.TODO()
+	logger := log.With().Str("test", "redis").Logger()
+	key := "crawl-queue:20220801"
+	expire := time.Hour
+
+	server := miniredis.RunT(t)
+	impl := &redisImpl{
+		instance: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		cfg: Config{
+			Logger: &logger,
+		},
+	}
+
+	queueLen := func(wantPending, wantClaimed int64) {
+		t.Helper()
+
+		pending, claimed, err := impl.QueueLen(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, wantPending, pending, "pending")
+		assert.Equal(t, wantClaimed, claimed, "claimed")
+	}
+
+	assert.NoError(t, impl.Enqueue(ctx, key, expire, []string{"2330", "2317"}))
+
+	// claimed in order, hidden from the next claims
+	item, err := impl.Claim(ctx, key, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "2330", item)
+
+	item, err = impl.Claim(ctx, key, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "2317", item)
+	queueLen(0, 2)
+	assert.Equal(t, expire, server.TTL(key+claimedSuffix))
+
+	_, err = impl.Claim(ctx, key, time.Minute)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	// the emptied pending list is created again with the expiry of the queue
+	assert.NoError(t, impl.Requeue(ctx, key, "2317", "2317#1"))
+	queueLen(1, 1)
+	assert.Equal(t, expire, server.TTL(key+pendingSuffix))
+
+	// a requeued claim is not claimed twice
+	assert.NoError(t, impl.Requeue(ctx, key, "2317", "2317#2"))
+	queueLen(1, 1)
+
+	assert.NoError(t, impl.Ack(ctx, key, "2330"))
+	queueLen(1, 0)
+
+	// a claim past its visibility timeout is claimed again, acked once processed
+	item, err = impl.Claim(ctx, key, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "2317#1", item)
+	queueLen(0, 1)
+
+	time.Sleep(20 * time.Millisecond)
+
+	item, err = impl.Claim(ctx, key, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "2317#1", item)
+	assert.Equal(t, expire, server.TTL(key+claimedSuffix))
+
+	assert.NoError(t, impl.Ack(ctx, key, "2317#1"))
+	queueLen(0, 0)
+
+	// the claims settled after the queue was deleted do not create it again
+	assert.NoError(t, impl.Enqueue(ctx, key, expire, []string{"2454"}))
+
+	item, err = impl.Claim(ctx, key, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "2454", item)
+
+	assert.NoError(t, impl.DeleteQueue(ctx, key))
+	assert.NoError(t, impl.Requeue(ctx, key, "2454", "2454#1"))
+	queueLen(0, 0)
+	assert.False(t, server.Exists(key+pendingSuffix))
+}
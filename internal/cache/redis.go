@@ -28,13 +28,36 @@ const (
 	CronjobLock = "cronjob-lock"
 )
 
+var ErrCacheMiss = errors.New("cache miss")
+
 //go:generate mockgen -source=redis.go -destination=mocks/redis.go -package=cache
 type Redis interface {
 	SetExpire(ctx context.Context, key string, expired time.Time) error
 	SAdd(ctx context.Context, key, value string) error
 	SMembers(ctx context.Context, key string) ([]string, error)
+	SRem(ctx context.Context, key, value string) error
+	ReplaceSet(ctx context.Context, key string, members []string) error
+	Set(ctx context.Context, key, value string, expire time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	Del(ctx context.Context, key string) error
+	HSet(ctx context.Context, key string, values map[string]string) error
+	HGetAll(ctx context.Context, key string) (map[string]string, error)
+	ZAdd(ctx context.Context, key string, score float64, member string) error
+	ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+	ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error
 	Close() error
-	ObtainLock(ctx context.Context, key string, expire time.Duration) *redislock.Lock
+	ObtainLock(ctx context.Context, key string, expire time.Duration) (*Lock, error)
+	Campaign(ctx context.Context, key, id string, expire time.Duration) (*Leader, error)
+	Resign(ctx context.Context, key string, leader *Leader) error
+	CurrentLeader(ctx context.Context, key string) (*Leader, error)
+	Enqueue(ctx context.Context, key string, expire time.Duration, items []string) error
+	Claim(ctx context.Context, key string, visibility time.Duration) (string, error)
+	Ack(ctx context.Context, key, item string) error
+	Requeue(ctx context.Context, key, claimed, item string) error
+	QueueLen(ctx context.Context, key string) (int64, int64, error)
+	DeleteQueue(ctx context.Context, key string) error
+	Collect(ctx context.Context, index, key, field, value string, total int, expire time.Duration) (map[string]string, error)
+	TakeExpired(ctx context.Context, index string, before time.Time) (map[string]map[string]string, error)
 }
 
 // Config encapsulates the settings for configuring the redis service.
@@ -109,6 +132,130 @@ func (r *redisImpl) SMembers(ctx context.Context, key string) ([]string, error)
 	return res, nil
 }
 
+func (r *redisImpl) SRem(ctx context.Context, key, value string) error {
+	err := r.instance.SRem(ctx, key, value).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.SRem: failed, key=%s; value=%s; err=%w;", key, value, err)
+	}
+
+	return nil
+}
+
+// ReplaceSet replaces every member of the set at once, the members are written into a
+// temporary key renamed onto the set within a transaction, so the set is never seen
+// partially written. The set is deleted if there is no member.
+func (r *redisImpl) ReplaceSet(ctx context.Context, key string, members []string) error {
+	if len(members) == 0 {
+		return r.Del(ctx, key)
+	}
+
+	tmp := key + ":tmp"
+	values := make([]any, 0, len(members))
+
+	for _, member := range members {
+		values = append(values, member)
+	}
+
+	_, err := r.instance.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, tmp)
+		pipe.SAdd(ctx, tmp, values...)
+		pipe.Rename(ctx, tmp, key)
+
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("cache.ReplaceSet: failed, key=%s; err=%w;", key, err)
+	}
+
+	r.cfg.Logger.Info().Msgf("cache.ReplaceSet: success, key=%s; members=%d;", key, len(members))
+
+	return nil
+}
+
+func (r *redisImpl) Set(ctx context.Context, key, value string, expire time.Duration) error {
+	err := r.instance.Set(ctx, key, value, expire).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.Set: failed, key=%s; err=%w;", key, err)
+	}
+
+	r.cfg.Logger.Debug().Msgf("cache.Set: success, key=%s;", key)
+
+	return nil
+}
+
+// Get returns ErrCacheMiss if the key does not exist.
+func (r *redisImpl) Get(ctx context.Context, key string) (string, error) {
+	res, err := r.instance.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", xerrors.Errorf("cache.Get: failed, key=%s; err=%w;", key, ErrCacheMiss)
+	} else if err != nil {
+		return "", xerrors.Errorf("cache.Get: failed, key=%s; err=%w;", key, err)
+	}
+
+	return res, nil
+}
+
+func (r *redisImpl) Del(ctx context.Context, key string) error {
+	err := r.instance.Del(ctx, key).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.Del: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
+// HSet sets the fields of the hash in a single command.
+func (r *redisImpl) HSet(ctx context.Context, key string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	err := r.instance.HSet(ctx, key, values).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.HSet: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
+// HGetAll returns every field of the hash, empty if the key does not exist.
+func (r *redisImpl) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	res, err := r.instance.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("cache.HGetAll: failed, key=%s; err=%w;", key, err)
+	}
+
+	return res, nil
+}
+
+func (r *redisImpl) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	err := r.instance.ZAdd(ctx, key, &redis.Z{Score: score, Member: member}).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.ZAdd: failed, key=%s; member=%s; err=%w;", key, member, err)
+	}
+
+	return nil
+}
+
+// ZRevRange returns the members from the highest score.
+func (r *redisImpl) ZRevRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	res, err := r.instance.ZRevRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, xerrors.Errorf("cache.ZRevRange: failed, key=%s; err=%w;", key, err)
+	}
+
+	return res, nil
+}
+
+func (r *redisImpl) ZRemRangeByRank(ctx context.Context, key string, start, stop int64) error {
+	err := r.instance.ZRemRangeByRank(ctx, key, start, stop).Err()
+	if err != nil {
+		return xerrors.Errorf("cache.ZRemRangeByRank: failed, key=%s; err=%w;", key, err)
+	}
+
+	return nil
+}
+
 func (r *redisImpl) Close() error {
 	if err := r.instance.Close(); err != nil {
 		return xerrors.Errorf("cache.Close: failed, err=%w;", err)
@@ -117,25 +264,29 @@ func (r *redisImpl) Close() error {
 	return nil
 }
 
+// ObtainLock returns the held lock, refreshed until released, or ErrLockNotObtained
+// if another owner holds it. The lock of a fenced context is refused with ErrNotLeader
+// once a later leader was elected.
 func (r *redisImpl) ObtainLock(
 	ctx context.Context,
 	key string,
 	expire time.Duration,
-) *redislock.Lock {
+) (*Lock, error) {
 	// Create a new lock client.
 	locker := redislock.New(r.instance)
+	if fence := fenceFromContext(ctx); fence != nil {
+		locker = redislock.New(&fencedClient{Client: r.instance, fence: fence})
+	}
 
 	// Try to obtain lock.
 	lock, err := locker.Obtain(ctx, key, expire, nil)
 	if errors.Is(err, redislock.ErrNotObtained) {
-		r.cfg.Logger.Error().Err(err).Msg("cache.ObtainLock: failed, could not obtain lock!")
-
-		return nil
+		return nil, xerrors.Errorf("cache.ObtainLock: failed, key=%s; err=%w;", key, ErrLockNotObtained)
 	} else if err != nil {
-		panic(err)
+		return nil, xerrors.Errorf("cache.ObtainLock: failed, key=%s; err=%w;", key, err)
 	}
 
-	r.cfg.Logger.Debug().Msgf("cache.ObtainLock: success, key=%s, lock:%+v;", key, lock)
+	r.cfg.Logger.Debug().Msgf("cache.ObtainLock: success, key=%s;", key)
 
-	return lock
+	return newLock(ctx, lock, expire, r.cfg.Logger), nil
 }
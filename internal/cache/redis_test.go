@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/bsm/redislock"
 	redis "github.com/go-redis/redis/v8"
 	redismock "github.com/go-redis/redismock/v8"
@@ -192,28 +193,127 @@ func TestSMembers(t *testing.T) {
 	}
 }
 
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		val     string
+		err     error
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Redis Get successfully",
+			val:  "value",
+			want: "value",
+		},
+		{
+			name:    "Redis Get missing key",
+			err:     redis.Nil,
+			wantErr: ErrCacheMiss,
+		},
+	}
+
+	logger := log.With().Str("test", "redis").Logger()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, mock := redismock.NewClientMock()
+
+			impl := &redisImpl{
+				instance: client,
+				cfg: Config{
+					Logger: &logger,
+				},
+			}
+
+			if tt.err != nil {
+				mock.ExpectGet("test").SetErr(tt.err)
+			} else {
+				mock.ExpectGet("test").SetVal(tt.val)
+			}
+
+			res, err := impl.Get(context.TODO(), "test")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, res)
+		})
+	}
+}
+
+func TestReplaceSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		members []string
+	}{
+		{
+			name:    "Redis ReplaceSet renames the written members onto the set",
+			members: []string{"20240208", "20240228"},
+		},
+		{
+			name: "Redis ReplaceSet deletes the set without members",
+		},
+	}
+
+	logger := log.With().Str("test", "redis").Logger()
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, mock := redismock.NewClientMock()
+
+			impl := &redisImpl{
+				instance: client,
+				cfg: Config{
+					Logger: &logger,
+				},
+			}
+
+			if len(tt.members) == 0 {
+				mock.ExpectDel("test").SetVal(1)
+			} else {
+				mock.ExpectTxPipeline()
+				mock.ExpectDel("test:tmp").SetVal(0)
+				mock.ExpectSAdd("test:tmp", "20240208", "20240228").SetVal(2)
+				mock.ExpectRename("test:tmp", "test").SetVal("OK")
+				mock.ExpectTxPipelineExec()
+			}
+
+			assert.NoError(t, impl.ReplaceSet(context.TODO(), "test", tt.members))
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestObtainLock(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name     string
-		err      error
-		obtained bool
+		name    string
+		err     error
+		wantErr error
 	}{
 		{
-			name:     "Redis distributed lock obtained successfully",
-			obtained: true,
-			err:      nil,
+			name: "Redis distributed lock obtained successfully",
 		},
 		{
-			name:     "Redis distributed lock obtain failed",
-			obtained: false,
-			err:      redislock.ErrNotObtained,
+			name:    "Redis distributed lock obtain failed",
+			err:     redislock.ErrNotObtained,
+			wantErr: ErrLockNotObtained,
 		},
 		{
-			name:     "Redis distributed lock panic",
-			obtained: false,
-			err:      redis.ErrClosed,
+			name:    "Redis distributed lock error",
+			err:     redis.ErrClosed,
+			wantErr: redis.ErrClosed,
 		},
 	}
 
@@ -225,7 +325,9 @@ func TestObtainLock(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			ctx := context.TODO()
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
 			duration := 10 * time.Second
 			client, mock := redismock.NewClientMock()
 			impl := &redisImpl{
@@ -235,20 +337,56 @@ func TestObtainLock(t *testing.T) {
 				},
 			}
 
-			//nolint:nolintlint, errorlint
-			switch tt.err {
-			case redis.ErrClosed:
-				mock.Regexp().ExpectSetNX(CronjobLock, `[a-z]+`, duration).SetErr(redis.ErrClosed)
-				assert.Panics(t, func() { impl.ObtainLock(ctx, CronjobLock, duration) }, "The code did not panic")
-			case redislock.ErrNotObtained:
+			if tt.err != nil {
 				mock.Regexp().ExpectSetNX(CronjobLock, `[a-z]+`, duration).SetErr(tt.err)
-				lock := impl.ObtainLock(ctx, CronjobLock, duration)
-				assert.Equal(t, tt.obtained, lock != nil)
-			default:
+			} else {
 				mock.Regexp().ExpectSetNX(CronjobLock, `[a-z]+`, duration).SetVal(true)
-				lock := impl.ObtainLock(ctx, CronjobLock, duration)
-				assert.Equal(t, tt.obtained, lock != nil)
+			}
+
+			lock, err := impl.ObtainLock(ctx, CronjobLock, duration)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantErr == nil, lock != nil)
+
+			if lock != nil {
+				assert.Equal(t, CronjobLock, lock.Key())
+
+				// the heartbeat stops with the context
+				cancel()
+				<-lock.done
 			}
 		})
 	}
 }
+
+func TestLockLost(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	logger := log.With().Str("test", "redis").Logger()
+	impl := &redisImpl{
+		instance: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+		cfg: Config{
+			Logger: &logger,
+		},
+	}
+
+	lock, err := impl.ObtainLock(context.TODO(), CronjobLock, 300*time.Millisecond)
+	assert.NoError(t, err)
+
+	ctx, cancel := lock.Context(context.TODO())
+	defer cancel()
+
+	// the lock expired and was obtained by another owner before its refresh
+	server.Del(CronjobLock)
+	assert.NoError(t, server.Set(CronjobLock, "other"))
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock loss not reported")
+	}
+
+	<-ctx.Done()
+	assert.ErrorIs(t, context.Cause(ctx), ErrLockLost)
+	assert.NoError(t, lock.Release(context.TODO()))
+}
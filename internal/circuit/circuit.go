@@ -0,0 +1,193 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package circuit
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/xerrors"
+)
+
+type State int
+
+const (
+	// Closed lets every request through.
+	Closed State = iota
+	// Open fails every request fast until the cool-down ends.
+	Open
+	// HalfOpen lets a single probe through, its outcome closes or reopens the circuit.
+	HalfOpen
+)
+
+var ErrOpen = errors.New("circuit is open")
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+type Config struct {
+	Logger *zerolog.Logger
+	// Threshold of consecutive failures opening the circuit.
+	Threshold int
+	// CoolDown is how long an open circuit fails fast before probing.
+	CoolDown time.Duration
+}
+
+// Snapshot is the state of the circuit of a key at a point in time.
+type Snapshot struct {
+	OpenedAt time.Time `json:"openedAt,omitempty"`
+	Key      string    `json:"key"`
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+}
+
+type breaker struct {
+	openedAt time.Time
+	probedAt time.Time
+	state    State
+	failures int
+}
+
+// Group keeps one circuit breaker per key, e.g. per remote host.
+type Group struct {
+	breakers map[string]*breaker
+	cfg      Config
+	mu       sync.Mutex
+}
+
+func NewGroup(cfg Config) *Group {
+	return &Group{
+		cfg:      cfg,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Allow returns ErrOpen if the request of the key should fail fast.
+func (g *Group) Allow(key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	brk := g.get(key)
+	now := time.Now()
+
+	switch brk.state {
+	case Closed:
+		return nil
+	case Open:
+		if now.Before(brk.openedAt.Add(g.cfg.CoolDown)) {
+			return xerrors.Errorf("circuit.Allow: failed, key=%s; err=%w;", key, ErrOpen)
+		}
+
+		g.transit(key, brk, HalfOpen)
+		brk.probedAt = now
+
+		return nil
+	case HalfOpen:
+		// the probe is in flight, another one is only sent if it never reported back
+		if now.Before(brk.probedAt.Add(g.cfg.CoolDown)) {
+			return xerrors.Errorf("circuit.Allow: failed, key=%s; err=%w;", key, ErrOpen)
+		}
+
+		brk.probedAt = now
+
+		return nil
+	}
+
+	return nil
+}
+
+// Success closes the circuit of the key.
+func (g *Group) Success(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	brk := g.get(key)
+	brk.failures = 0
+
+	if brk.state != Closed {
+		g.transit(key, brk, Closed)
+	}
+}
+
+// Failure counts a failure of the key, opening the circuit once the
+// threshold is reached or if the half-open probe failed.
+func (g *Group) Failure(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	brk := g.get(key)
+	brk.failures++
+
+	if brk.state == HalfOpen || (brk.state == Closed && brk.failures >= g.cfg.Threshold) {
+		brk.openedAt = time.Now()
+		g.transit(key, brk, Open)
+	}
+}
+
+// Snapshots returns the state of every known key sorted by key.
+func (g *Group) Snapshots() []Snapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	snapshots := make([]Snapshot, 0, len(g.breakers))
+	for key, brk := range g.breakers {
+		snapshot := Snapshot{
+			Key:      key,
+			State:    brk.state.String(),
+			Failures: brk.failures,
+		}
+
+		if brk.state != Closed {
+			snapshot.OpenedAt = brk.openedAt
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Key < snapshots[j].Key })
+
+	return snapshots
+}
+
+func (g *Group) get(key string) *breaker {
+	brk, ok := g.breakers[key]
+	if !ok {
+		brk = &breaker{}
+		g.breakers[key] = brk
+	}
+
+	return brk
+}
+
+func (g *Group) transit(key string, brk *breaker, state State) {
+	if g.cfg.Logger != nil {
+		g.cfg.Logger.Warn().Msgf("circuit.transit: %s -> %s, key=%s; failures=%d;",
+			brk.state, state, key, brk.failures)
+	}
+
+	brk.state = state
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package circuit
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	leak := flag.Bool("leak", false, "use leak detector")
+
+	if *leak {
+		goleak.VerifyTestMain(m)
+
+		return
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		coolDown  time.Duration
+		failures  int
+		probeOK   bool
+		wantAllow bool
+		wantState string
+	}{
+		{
+			name:      "below threshold",
+			coolDown:  time.Hour,
+			failures:  2,
+			wantAllow: true,
+			wantState: "closed",
+		},
+		{
+			name:      "open fails fast",
+			coolDown:  time.Hour,
+			failures:  3,
+			wantAllow: false,
+			wantState: "open",
+		},
+		{
+			name:      "probe after cool-down closes",
+			failures:  3,
+			probeOK:   true,
+			wantAllow: true,
+			wantState: "closed",
+		},
+		{
+			name:      "failed probe reopens",
+			coolDown:  time.Millisecond,
+			failures:  3,
+			wantAllow: true,
+			wantState: "open",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			group := NewGroup(Config{Threshold: 3, CoolDown: tt.coolDown})
+			for i := 0; i < tt.failures; i++ {
+				group.Failure("www.twse.com.tw")
+			}
+
+			// let the short cool-downs pass
+			if tt.coolDown < time.Second {
+				time.Sleep(2 * tt.coolDown)
+			}
+
+			err := group.Allow("www.twse.com.tw")
+			assert.Equal(t, tt.wantAllow, err == nil)
+
+			if tt.wantAllow && tt.failures >= 3 {
+				if tt.probeOK {
+					group.Success("www.twse.com.tw")
+				} else {
+					group.Failure("www.twse.com.tw")
+				}
+			}
+
+			assert.Equal(t, tt.wantState, group.Snapshots()[0].State)
+		})
+	}
+}
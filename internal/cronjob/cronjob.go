@@ -28,7 +28,10 @@ import (
 type Cronjob interface {
 	Start()
 	Stop()
-	AddJob(ctx context.Context, spec string, job func()) error
+	AddJob(ctx context.Context, spec string, job func()) (cron.EntryID, error)
+	Remove(id cron.EntryID)
+	// Entry returns the scheduling of the job, with its next and previous run times.
+	Entry(id cron.EntryID) cron.Entry
 }
 
 type Config struct {
@@ -70,13 +73,21 @@ func (c Config) Error(_ error, _ string, keysAndValues ...any) {
 	}
 }
 
-func (c *cronjobImpl) AddJob(_ context.Context, spec string, job func()) error {
-	_, err := c.instance.AddFunc(spec, job)
+func (c *cronjobImpl) AddJob(_ context.Context, spec string, job func()) (cron.EntryID, error) {
+	id, err := c.instance.AddFunc(spec, job)
 	if err != nil {
-		return xerrors.Errorf("cronjob.AddJob: failed, spec=%s, err=%w", spec, err)
+		return 0, xerrors.Errorf("cronjob.AddJob: failed, spec=%s, err=%w", spec, err)
 	}
 
-	return nil
+	return id, nil
+}
+
+func (c *cronjobImpl) Remove(id cron.EntryID) {
+	c.instance.Remove(id)
+}
+
+func (c *cronjobImpl) Entry(id cron.EntryID) cron.Entry {
+	return c.instance.Entry(id)
 }
 
 func (c *cronjobImpl) Start() {
@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	cron "github.com/robfig/cron/v3"
 )
 
 // MockCronjob is a mock of Cronjob interface.
@@ -35,11 +36,12 @@ func (m *MockCronjob) EXPECT() *MockCronjobMockRecorder {
 }
 
 // AddJob mocks base method.
-func (m *MockCronjob) AddJob(ctx context.Context, spec string, job func()) error {
+func (m *MockCronjob) AddJob(ctx context.Context, spec string, job func()) (cron.EntryID, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AddJob", ctx, spec, job)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(cron.EntryID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddJob indicates an expected call of AddJob.
@@ -48,6 +50,32 @@ func (mr *MockCronjobMockRecorder) AddJob(ctx, spec, job any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddJob", reflect.TypeOf((*MockCronjob)(nil).AddJob), ctx, spec, job)
 }
 
+// Entry mocks base method.
+func (m *MockCronjob) Entry(id cron.EntryID) cron.Entry {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Entry", id)
+	ret0, _ := ret[0].(cron.Entry)
+	return ret0
+}
+
+// Entry indicates an expected call of Entry.
+func (mr *MockCronjobMockRecorder) Entry(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Entry", reflect.TypeOf((*MockCronjob)(nil).Entry), id)
+}
+
+// Remove mocks base method.
+func (m *MockCronjob) Remove(id cron.EntryID) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Remove", id)
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockCronjobMockRecorder) Remove(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockCronjob)(nil).Remove), id)
+}
+
 // Start mocks base method.
 func (m *MockCronjob) Start() {
 	m.ctrl.T.Helper()
@@ -0,0 +1,124 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package helper
+
+import (
+	"time"
+)
+
+// maxCalendarSearch bounds the walk looking for each trading day to a year, far
+// beyond any market closure, so a calendar without trading days can not loop forever.
+const maxCalendarSearch = 366
+
+// Calendar tells the trading days of the Taiwan market: weekdays except the
+// holidays, plus the make-up trading Saturdays. Dates are given as 20060102.
+type Calendar struct {
+	loc      *time.Location
+	holidays map[string]bool
+	makeUps  map[string]bool
+}
+
+func NewCalendar(holidays, makeUps []string) *Calendar {
+	loc, err := time.LoadLocation(TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	cal := &Calendar{
+		loc:      loc,
+		holidays: make(map[string]bool, len(holidays)),
+		makeUps:  make(map[string]bool, len(makeUps)),
+	}
+
+	for _, date := range holidays {
+		cal.holidays[UnifiedDateFormatToTwse(date)] = true
+	}
+
+	for _, date := range makeUps {
+		cal.makeUps[UnifiedDateFormatToTwse(date)] = true
+	}
+
+	return cal
+}
+
+// Day returns the midnight of the date in the market time zone.
+func (c *Calendar) Day(date time.Time) time.Time {
+	local := date.In(c.loc)
+
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc)
+}
+
+// IsTradingDay tells whether the market opens on the date.
+func (c *Calendar) IsTradingDay(date time.Time) bool {
+	day := c.Day(date)
+	key := day.Format(TwseDateFormat)
+
+	if c.makeUps[key] {
+		return true
+	}
+
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+
+	return !c.holidays[key]
+}
+
+// TradingDaysBefore returns the n-th trading day before the date, the date itself
+// is returned as is for n = 0 whether trading or not.
+func (c *Calendar) TradingDaysBefore(date time.Time, n int) time.Time {
+	day := c.Day(date)
+
+	for walked := 0; n > 0 && walked < maxCalendarSearch*n; walked++ {
+		day = day.AddDate(0, 0, -1)
+
+		if c.IsTradingDay(day) {
+			n--
+		}
+	}
+
+	return day
+}
+
+// TradingDaysBetween returns the trading days from the date to the other, both included.
+func (c *Calendar) TradingDaysBetween(from, to time.Time) []time.Time {
+	var days []time.Time
+
+	end := c.Day(to)
+
+	for day := c.Day(from); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+
+	return days
+}
+
+// FormatDate formats the date in the market time zone, TPEx dates use the ROC year.
+func FormatDate(date time.Time, format string) string {
+	loc, err := time.LoadLocation(TimeZone)
+	if err != nil {
+		return ""
+	}
+
+	res := date.In(loc).Format(format)
+
+	if format == TpexDateFormat {
+		// Tpex format: 108/02/06
+		res = UnifiedDateFormatToTpex(res)
+	}
+
+	return res
+}
@@ -39,6 +39,8 @@ const (
 	TwseDateFormat           = "20060102"
 	TpexDateFormat           = "2006/01/02"
 	StakeConcentrationFormat = "2006-01-02"
+	MonthlyFormat            = "200601"
+	YearlyFormat             = "2006"
 )
 
 func ReadFromFile(fileName string) (string, error) {
@@ -163,6 +165,48 @@ func GetDateFromOffset(offset int32, format string, input ...time.Time) string {
 	return GetDateFromUTC(timestamp, format)
 }
 
+// GetMonthFromOffset returns the month with offset months from the current month.
+func GetMonthFromOffset(offset int32, format string, input ...time.Time) string {
+	current := time.Now()
+
+	if len(input) > 0 {
+		current = input[0]
+	}
+
+	loc, err := time.LoadLocation(TimeZone)
+	if err != nil {
+		return ""
+	}
+
+	localTime := current.In(loc)
+	// always anchor on the first day to prevent overflowing into the next month
+	month := time.Date(localTime.Year(), localTime.Month()+time.Month(offset), 1, 0, 0, 0, 0, loc)
+
+	return month.Format(format)
+}
+
+// UnifiedMonthFormatToRoc converts 202210 into the ROC year and month 111_10.
+func UnifiedMonthFormatToRoc(input string) string {
+	month, err := time.Parse(MonthlyFormat, input)
+	if err != nil {
+		return ""
+	}
+
+	//nolint:nolintlint, gomnd
+	return fmt.Sprintf("%d_%d", month.Year()-1911, int(month.Month()))
+}
+
+// UnifiedYearFormatToRoc converts 2022 into the ROC year 111.
+func UnifiedYearFormatToRoc(input string) string {
+	year, err := strconv.Atoi(input)
+	if err != nil {
+		return ""
+	}
+
+	//nolint:nolintlint, gomnd
+	return strconv.Itoa(year - 1911)
+}
+
 func UnifiedDateFormatToTpex(input string) string {
 	if strings.Contains(input, "/") {
 		res := strings.Split(input, "/")
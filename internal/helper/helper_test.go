@@ -353,6 +353,54 @@ func Test_GetDateFromOffset(t *testing.T) {
 	}
 }
 
+func Test_GetMonthFromOffset(t *testing.T) {
+	t.Parallel()
+
+	l, _ := time.LoadLocation(TimeZone)
+	expTime := time.Date(2022, time.March, 31, 12, 0, 0, 0, l)
+
+	tests := []struct {
+		name   string
+		want   string
+		offset int32
+	}{
+		{
+			name:   "current month",
+			offset: 0,
+			want:   "202203",
+		},
+		{
+			name:   "previous month without day overflow",
+			offset: -1,
+			want:   "202202",
+		},
+		{
+			name:   "previous year",
+			offset: -3,
+			want:   "202112",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := GetMonthFromOffset(tt.offset, MonthlyFormat, expTime); got != tt.want {
+				t.Errorf("GetMonthFromOffset(tt.offset) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_UnifiedMonthFormatToRoc(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "111_1", UnifiedMonthFormatToRoc("202201"))
+	assert.Equal(t, "111_10", UnifiedMonthFormatToRoc("202210"))
+	assert.Equal(t, "", UnifiedMonthFormatToRoc("2022-10"))
+}
+
 func Test_GetReadableSize(t *testing.T) {
 	t.Parallel()
 
@@ -441,3 +489,74 @@ func Test_Diff(t *testing.T) {
 		})
 	}
 }
+
+func Test_Calendar(t *testing.T) {
+	t.Parallel()
+
+	l, _ := time.LoadLocation(TimeZone)
+	day := func(d int) time.Time { return time.Date(2023, time.January, d, 18, 0, 0, 0, l) }
+
+	// 2023/01/02 is a holiday and 2023/01/07 a make-up trading Saturday
+	cal := NewCalendar([]string{"20230102"}, []string{"2023-01-07"})
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{
+			name: "weekday is trading",
+			got:  cal.IsTradingDay(day(3)),
+			want: true,
+		},
+		{
+			name: "holiday is not trading",
+			got:  cal.IsTradingDay(day(2)),
+			want: false,
+		},
+		{
+			name: "sunday is not trading",
+			got:  cal.IsTradingDay(day(8)),
+			want: false,
+		},
+		{
+			name: "make-up saturday is trading",
+			got:  cal.IsTradingDay(day(7)),
+			want: true,
+		},
+		{
+			name: "zero trading days before is the day itself",
+			got:  FormatDate(cal.TradingDaysBefore(day(8), 0), TwseDateFormat),
+			want: "20230108",
+		},
+		{
+			name: "one trading day before monday skips sunday",
+			got:  FormatDate(cal.TradingDaysBefore(day(9), 1), TwseDateFormat),
+			want: "20230107",
+		},
+		{
+			name: "trading days before skip the holiday",
+			got:  FormatDate(cal.TradingDaysBefore(day(4), 2), TwseDateFormat),
+			want: "20221230",
+		},
+		{
+			name: "trading days between both ends",
+			got:  len(cal.TradingDaysBetween(day(1), day(9))),
+			want: 6,
+		},
+		{
+			name: "tpex date uses roc year",
+			got:  FormatDate(day(3), TpexDateFormat),
+			want: "112/01/03",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, tt.got)
+		})
+	}
+}
@@ -26,8 +26,11 @@ import (
 const (
 	DailyClosesV1        = "dailycloses-v1"
 	StocksV1             = "stocks-v1"
+	StockChangesV1       = "stockchanges-v1"
 	ThreePrimaryV1       = "threeprimary-v1"
 	StakeConcentrationV1 = "stakeconcentration-v1"
+	MarginTradeV1        = "margintrade-v1"
+	MonthlyRevenueV1     = "monthlyrevenue-v1"
 	DownloadV1           = "download-v1"
 	queueCapacity        = 1024
 	sessionTimeout       = 10 * time.Second
@@ -0,0 +1,184 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Config of a token bucket, Rate is the steady number of requests per second
+// and Burst the number of requests allowed at once after idling.
+type Config struct {
+	Rate  float64
+	Burst int
+}
+
+const maxSlowdown = 16
+
+// Limiter is a token bucket, waiting callers reserve tokens in advance so the
+// bucket may go negative which keeps the steady rate under contention.
+type Limiter struct {
+	last        time.Time
+	pausedUntil time.Time
+	slowUntil   time.Time
+	cfg         Config
+	tokens      float64
+	slowdown    float64
+	mu          sync.Mutex
+}
+
+func New(cfg Config) *Limiter {
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+
+	return &Limiter{
+		cfg:    cfg,
+		tokens: float64(cfg.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or the context is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return xerrors.Errorf("ratelimit.Wait: failed, err=%w;", ctx.Err())
+	}
+}
+
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// no token is handed out before the pause ends
+	start := now
+	if l.pausedUntil.After(start) {
+		start = l.pausedUntil
+	}
+
+	// unlimited bucket
+	if l.cfg.Rate <= 0 {
+		return start.Sub(now)
+	}
+
+	rate := l.cfg.Rate
+	if start.Before(l.slowUntil) {
+		rate /= l.slowdown
+	}
+
+	if start.After(l.last) {
+		elapsed := start.Sub(l.last).Seconds()
+		l.tokens = math.Min(float64(l.cfg.Burst), l.tokens+elapsed*rate)
+		l.last = start
+	}
+
+	l.tokens--
+
+	delay := start.Sub(now)
+	if l.tokens < 0 {
+		delay += time.Duration(-l.tokens / rate * float64(time.Second))
+	}
+
+	return delay
+}
+
+// Throttle pauses the bucket for the pause duration, then keeps the rate
+// slowed down until the cool-down ends. Throttling again before the cool-down
+// ends doubles the slowdown, up to 16 times slower than the configured rate.
+func (l *Limiter) Throttle(pause, coolDown time.Duration) {
+	l.throttle(time.Now(), pause, coolDown)
+}
+
+func (l *Limiter) throttle(now time.Time, pause, coolDown time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := now.Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+
+	if now.Before(l.slowUntil) {
+		l.slowdown = math.Min(l.slowdown*2, maxSlowdown)
+	} else {
+		l.slowdown = 2
+	}
+
+	l.slowUntil = l.pausedUntil.Add(coolDown)
+	// the burst is not allowed right after the pause
+	l.tokens = math.Min(l.tokens, 0)
+	if l.pausedUntil.After(l.last) {
+		l.last = l.pausedUntil
+	}
+}
+
+// Group keeps one limiter per key, keys without a dedicated config share the
+// default config but are still paced independently.
+type Group struct {
+	limiters map[string]*Limiter
+	configs  map[string]Config
+	fallback Config
+	mu       sync.Mutex
+}
+
+func NewGroup(fallback Config, configs map[string]Config) *Group {
+	return &Group{
+		limiters: make(map[string]*Limiter),
+		configs:  configs,
+		fallback: fallback,
+	}
+}
+
+// Wait blocks until the limiter of the key allows one more request.
+func (g *Group) Wait(ctx context.Context, key string) error {
+	return g.get(key).Wait(ctx)
+}
+
+// Throttle pauses and slows down the limiter of the key, see Limiter.Throttle.
+func (g *Group) Throttle(key string, pause, coolDown time.Duration) {
+	g.get(key).Throttle(pause, coolDown)
+}
+
+func (g *Group) get(key string) *Limiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	limiter, ok := g.limiters[key]
+	if !ok {
+		cfg, found := g.configs[key]
+		if !found {
+			cfg = g.fallback
+		}
+
+		limiter = New(cfg)
+		g.limiters[key] = limiter
+	}
+
+	return limiter
+}
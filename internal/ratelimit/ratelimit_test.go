@@ -0,0 +1,128 @@
+// Copyright 2021 Wei (Sam) Wang <sam.wang.0723@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ratelimit
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	leak := flag.Bool("leak", false, "use leak detector")
+
+	if *leak {
+		goleak.VerifyTestMain(m)
+
+		return
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestReserve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		calls int
+		want  time.Duration
+	}{
+		{
+			name:  "within burst",
+			cfg:   Config{Rate: 1, Burst: 3},
+			calls: 3,
+			want:  0,
+		},
+		{
+			name:  "exceed burst waits for steady rate",
+			cfg:   Config{Rate: 2, Burst: 1},
+			calls: 3,
+			want:  time.Second,
+		},
+		{
+			name:  "unlimited",
+			cfg:   Config{Rate: 0},
+			calls: 10,
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Now()
+			l := New(tt.cfg)
+			l.last = now
+
+			var got time.Duration
+			for i := 0; i < tt.calls; i++ {
+				got = l.reserve(now)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := New(Config{Rate: 1, Burst: 3})
+	l.last = now
+
+	l.throttle(now, 10*time.Second, time.Minute)
+
+	// paused, then the rate is halved
+	assert.Equal(t, 12*time.Second, l.reserve(now))
+	assert.Equal(t, 14*time.Second, l.reserve(now))
+
+	// throttled again within the cool-down slows it down further
+	l.throttle(now, 0, time.Minute)
+	assert.Equal(t, 4.0, l.slowdown)
+
+	// unlimited bucket still honors the pause
+	unlimited := New(Config{})
+	unlimited.throttle(now, 5*time.Second, time.Minute)
+	assert.Equal(t, 5*time.Second, unlimited.reserve(now))
+}
+
+func TestGroupWait(t *testing.T) {
+	t.Parallel()
+
+	g := NewGroup(Config{Rate: 1000, Burst: 1}, map[string]Config{
+		"slow": {Rate: 0.001, Burst: 1},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// first request of the slow key uses the burst, the next one has to wait
+	assert.NoError(t, g.Wait(ctx, "slow"))
+	assert.Error(t, g.Wait(ctx, "slow"))
+
+	// other keys are not held back by the slow key
+	assert.NoError(t, g.Wait(context.Background(), "fast"))
+	assert.NoError(t, g.Wait(context.Background(), "fast"))
+}
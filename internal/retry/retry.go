@@ -55,3 +55,8 @@ type Stop struct {
 func NoRetryError(err error) Stop {
 	return Stop{err}
 }
+
+// Unwrap keeps the wrapped error reachable by errors.Is and errors.As
+func (s Stop) Unwrap() error {
+	return s.error
+}
@@ -78,3 +78,13 @@ func TestRetry(t *testing.T) {
 		})
 	}
 }
+
+func TestNoRetryErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	err := Retry(3, 10*time.Millisecond, func() error {
+		return NoRetryError(ErrRetry)
+	})
+
+	assert.ErrorIs(t, err, ErrRetry)
+}